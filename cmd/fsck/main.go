@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/portbound/go-fs/internal/config"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database"
+	"github.com/portbound/go-fs/internal/platform/database/postgres"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+	"github.com/portbound/go-fs/internal/platform/storage"
+)
+
+const usage = `usage: fsck [-dry-run=false] [-stray-age d] [-max-purges n]
+
+Checks every user's bucket against the metadata table and reports what does
+not line up. With -dry-run=false it also repairs it: rows whose original is
+gone are removed, at most -max-purges of them, missing thumbnails are
+rendered again and objects no row points to are deleted. A bucket that lists
+no objects although rows point into it is never repaired. Exits 1 if
+anything is left unrepaired.`
+
+func main() {
+	dryRun := flag.Bool("dry-run", true, "report what is wrong without changing anything")
+	strayAge := flag.Duration("stray-age", fs.DefaultStrayAge, "how old an object without a row has to be to count as stray")
+	maxPurges := flag.Int("max-purges", fs.DefaultMaxPurges, "how many rows whose original is gone to remove")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dbCfg, err := config.LoadDatabase()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	storageCfg, err := config.LoadStorage()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	db, conn, err := openDatabase(*dbCfg)
+	if err != nil {
+		log.Fatalf("set up database: %v", err)
+	}
+	defer conn.Close()
+
+	media, err := storage.Open(*storageCfg)
+	if err != nil {
+		log.Fatalf("set up storage: %v", err)
+	}
+
+	r := fs.NewReconciler(fs.NewService(db, media), db)
+	report, err := r.Reconcile(context.Background(), fs.ReconcileOptions{DryRun: *dryRun, StrayAge: *strayAge, MaxPurges: *maxPurges})
+	if err != nil {
+		// Whatever was found before the failure is still worth printing.
+		log.Printf("reconcile: %v", err)
+	}
+
+	unresolved := 0
+	if report != nil {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tBUCKET\tOBJECT\tFILE\tSTATUS")
+		for _, issue := range report.Issues {
+			status := "found"
+			switch {
+			case issue.Err != nil:
+				status = "failed: " + issue.Err.Error()
+			case issue.Repaired:
+				status = "repaired"
+			}
+			if !issue.Repaired {
+				unresolved++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", issue.Kind, issue.Bucket, issue.Object, issue.FileId, status)
+		}
+		w.Flush()
+
+		fmt.Printf("checked %d buckets, %d files, %d objects: %d issues, %d unresolved\n",
+			report.Buckets, report.Files, report.Objects, len(report.Issues), unresolved)
+	}
+
+	if err != nil || unresolved > 0 {
+		os.Exit(1)
+	}
+}
+
+type store interface {
+	fs.MetaStore
+	fs.InventoryStore
+}
+
+func openDatabase(cfg config.Database) (store, *database.DBConnection, error) {
+	switch cfg.DBEngine {
+	case "sqlite3":
+		db, err := sqlite.NewSQLiteDB(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Conn, nil
+	case "postgres":
+		db, err := postgres.NewPostgresDB(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Conn, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported database engine %q", cfg.DBEngine)
+	}
+}
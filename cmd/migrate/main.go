@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/portbound/go-fs/internal/config"
+	"github.com/portbound/go-fs/internal/platform/database"
+	"github.com/portbound/go-fs/internal/platform/database/postgres"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up        apply all pending migrations
+  down [n]  roll back the last n migrations (default 1)
+  status    list migrations and when they were applied`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	var details *database.DBConnectionDetails
+	switch cfg.DBEngine {
+	case "sqlite3":
+		details = sqlite.ConnectionDetails(cfg.DBConnectionString)
+	case "postgres":
+		details = postgres.ConnectionDetails(cfg.DBConnectionString)
+	default:
+		log.Fatalf("unsupported database engine %q", cfg.DBEngine)
+	}
+
+	conn, err := database.Open(details)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+	defer conn.Close()
+
+	migrator, err := conn.Migrator()
+	if err != nil {
+		log.Fatalf("load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "up":
+		if err := migrator.Up(ctx); err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+	case "down":
+		n := 1
+		if len(os.Args) > 2 {
+			n, err = strconv.Atoi(os.Args[2])
+			if err != nil || n < 1 {
+				log.Fatalf("invalid number of migrations %q", os.Args[2])
+			}
+		}
+		if err := migrator.Down(ctx, n); err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("migration status: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/portbound/go-fs/internal/config"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database"
+	"github.com/portbound/go-fs/internal/platform/database/postgres"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+	"github.com/portbound/go-fs/internal/platform/storage"
+)
+
+const usage = `usage: provision [bucket ...]
+
+Creates the given buckets, or every user's bucket when none are given, and
+brings existing ones in line with the configured location, storage class,
+versioning, soft delete and lifecycle rules. Exits 1 if any bucket fails.`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	storageCfg, err := config.LoadStorage()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	media, err := storage.Open(*storageCfg)
+	if err != nil {
+		log.Fatalf("set up storage: %v", err)
+	}
+
+	buckets := flag.Args()
+	if len(buckets) == 0 {
+		buckets, err = userBuckets()
+		if err != nil {
+			log.Fatalf("list buckets: %v", err)
+		}
+	}
+
+	failed := 0
+	for _, bucket := range buckets {
+		if err := media.Provision(context.Background(), bucket); err != nil {
+			log.Printf("provision %s: %v", bucket, err)
+			failed++
+			continue
+		}
+		fmt.Printf("provisioned %s\n", bucket)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func userBuckets() ([]string, error) {
+	dbCfg, err := config.LoadDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	db, conn, err := openDatabase(*dbCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	users, err := db.GetBuckets(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]string, 0, len(users))
+	for _, u := range users {
+		buckets = append(buckets, u.Bucket)
+	}
+
+	return buckets, nil
+}
+
+func openDatabase(cfg config.Database) (fs.InventoryStore, *database.DBConnection, error) {
+	switch cfg.DBEngine {
+	case "sqlite3":
+		db, err := sqlite.NewSQLiteDB(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Conn, nil
+	case "postgres":
+		db, err := postgres.NewPostgresDB(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Conn, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported database engine %q", cfg.DBEngine)
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/portbound/go-fs/internal/album"
 	"github.com/portbound/go-fs/internal/auth"
 	"github.com/portbound/go-fs/internal/config"
 	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database"
+	"github.com/portbound/go-fs/internal/platform/database/postgres"
 	"github.com/portbound/go-fs/internal/platform/database/sqlite"
-	"github.com/portbound/go-fs/internal/platform/storage/gcs"
+	"github.com/portbound/go-fs/internal/platform/storage"
+	"github.com/portbound/go-fs/internal/platform/storage/replicated"
+	"github.com/portbound/go-fs/internal/tag"
+	"github.com/portbound/go-fs/internal/tus"
 	"github.com/portbound/go-fs/internal/user"
+	"github.com/portbound/go-fs/internal/vault"
 	"github.com/portbound/portlog"
 )
 
@@ -25,30 +37,77 @@ func main() {
 	}
 	defer logger.Close()
 
-	sqlite, err := sqlite.NewSQLiteDB(cfg.DBConnectionString)
+	db, conn, err := openDatabase(cfg.Database)
 	if err != nil {
 		log.Fatalf("set up database: %v", err)
 	}
-	defer sqlite.Conn.Close()
+	defer conn.Close()
 
-	gcs, err := gcs.New(cfg.GCSProjectId)
+	media, err := storage.Open(cfg.Storage)
 	if err != nil {
 		log.Fatalf("set up storage: %v", err)
 	}
+	provisionBuckets(media, db, logger)
 
 	authenticator := auth.New(cfg.JWTSecret, cfg.GoogleClientID, cfg.Environment)
-	userProvider := user.NewService(sqlite)
+	userProvider := user.NewService(db)
 	authService := auth.NewService(authenticator, userProvider)
 	authHandler := auth.NewHandler(authService, logger)
 
-	fsService := fs.NewService(sqlite, gcs)
+	fsService := fs.NewService(db, media)
+	fsService.SetUploadLimits(fs.UploadLimits{
+		Workers:  cfg.UploadWorkers,
+		FFmpeg:   cfg.UploadFFmpegConcurrency,
+		Storage:  cfg.UploadStorageConcurrency,
+		Database: cfg.UploadDatabaseConcurrency,
+	})
+	if media.Direct != nil {
+		media.SetUploadKey(signingKey(cfg.JWTSecret, "signed upload urls"))
+		fsService.EnableDirectUploads(media.Direct)
+	}
+	fsService.EnableSignedURLs(signingKey(cfg.JWTSecret, "signed download urls"), cfg.SignedURLExpiry, media.Signer)
 	fsHandler := fs.NewHandler(fsService, logger)
+	if n, err := fsService.SweepUploads(context.Background()); err != nil {
+		logger.Error("failed to sweep interrupted uploads", err, "swept", n)
+	} else if n > 0 {
+		logger.Info("swept interrupted uploads", "swept", n)
+	}
+	go purgeTrash(fsService, cfg.TrashRetention, cfg.TrashPurgeInterval, logger)
+	if cfg.FsckInterval > 0 {
+		go reconcile(fs.NewReconciler(fsService, db), cfg.FsckInterval, cfg.FsckDryRun, logger)
+	}
+	if media.Replicas != nil {
+		go repairReplicas(media.Replicas, db, cfg.ReplicaRepairInterval, cfg.ReplicaResyncInterval, logger)
+	}
+
+	tagService := tag.NewService(db)
+	tagHandler := tag.NewHandler(tagService, logger)
+
+	albumService := album.NewService(db)
+	albumHandler := album.NewHandler(albumService, logger)
+
+	tusService, err := tus.NewService(fsService, cfg.ResumableUploadDir, cfg.ResumableUploadMaxSize, cfg.ResumableUploadExpiry)
+	if err != nil {
+		log.Fatalf("set up resumable uploads: %v", err)
+	}
+	tusHandler := tus.NewHandler(tusService, logger)
+	go expireUploads(tusService, logger)
+
+	vaultService := vault.NewService(db)
+	vaultHandler := vault.NewHandler(vaultService, logger)
 
 	authMux := http.NewServeMux()
 	authHandler.RegisterRoutes(authMux)
+	// Signed uploads and downloads carry their own authorization.
+	media.RegisterRoutes(authMux)
+	fsHandler.RegisterSignedRoutes(authMux)
 
 	fsMux := http.NewServeMux()
 	fsHandler.RegisterRoutes(fsMux)
+	tagHandler.RegisterRoutes(fsMux)
+	albumHandler.RegisterRoutes(fsMux)
+	vaultHandler.RegisterRoutes(fsMux)
+	tusHandler.RegisterRoutes(fsMux)
 
 	switch cfg.Environment {
 	case "development":
@@ -69,3 +128,168 @@ func main() {
 		log.Fatalf("error: server failed to start: %v", err)
 	}
 }
+
+// provisionBuckets sets up every user's bucket, which applies any change to
+// the bucket settings. A bucket that fails is logged, uploads to it fail
+// until it is provisioned.
+func provisionBuckets(media *storage.Store, inventory fs.InventoryStore, logger *portlog.PortLog) {
+	ctx := context.Background()
+	buckets, err := inventory.GetBuckets(ctx)
+	if err != nil {
+		logger.Error("failed to provision buckets", err)
+		return
+	}
+
+	for _, b := range buckets {
+		if err := media.Provision(ctx, b.Bucket); err != nil {
+			logger.Error("failed to provision bucket", err, "bucket", b.Bucket)
+		}
+	}
+}
+
+// signingKey derives a key for signing URLs from the JWT secret, so that
+// URLs outlive a restart without another secret to manage. Each purpose gets
+// a key of its own, a signature for one kind of URL is no good for another.
+func signingKey(jwtSecret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// purgeTrash empties the trash of files older than retention every interval
+// for as long as the server runs.
+func purgeTrash(s *fs.Service, retention, interval time.Duration, logger *portlog.PortLog) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := s.PurgeTrash(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Error("failed to purge trash", err, "purged", n)
+			continue
+		}
+		if n > 0 {
+			logger.Info("purged trash", "purged", n)
+		}
+	}
+}
+
+// expireUploads removes abandoned resumable uploads every hour for as long as
+// the server runs.
+func expireUploads(s *tus.Service, logger *portlog.PortLog) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := s.Expire(context.Background(), time.Now())
+		if err != nil {
+			logger.Error("failed to expire uploads", err, "expired", n)
+			continue
+		}
+		if n > 0 {
+			logger.Info("expired uploads", "expired", n)
+		}
+	}
+}
+
+// reconcile checks storage against the database every interval and logs what
+// it finds, repairing it unless dryRun is set.
+func reconcile(r *fs.Reconciler, interval time.Duration, dryRun bool, logger *portlog.PortLog) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := r.Reconcile(context.Background(), fs.ReconcileOptions{DryRun: dryRun})
+		if err != nil {
+			logger.Error("failed to reconcile storage", err)
+			continue
+		}
+
+		for _, issue := range report.Issues {
+			if issue.Err != nil {
+				logger.Error("failed to repair storage", issue.Err, "kind", issue.Kind, "bucket", issue.Bucket, "object", issue.Object)
+				continue
+			}
+			logger.Info("storage inconsistency", "kind", issue.Kind, "bucket", issue.Bucket, "object", issue.Object, "repaired", issue.Repaired)
+		}
+		logger.Info("reconciled storage", "buckets", report.Buckets, "files", report.Files, "objects", report.Objects, "issues", len(report.Issues))
+	}
+}
+
+// repairReplicas catches up replicas that fell behind every repairInterval,
+// and compares them in full at startup and every resyncInterval.
+func repairReplicas(r *replicated.Replicated, inventory fs.InventoryStore, repairInterval, resyncInterval time.Duration, logger *portlog.PortLog) {
+	repair := time.NewTicker(repairInterval)
+	defer repair.Stop()
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+
+	resyncReplicas(r, inventory, logger)
+	for {
+		select {
+		case <-resync.C:
+			resyncReplicas(r, inventory, logger)
+			continue
+		case <-repair.C:
+		}
+
+		if r.Pending() == 0 {
+			continue
+		}
+		remaining, err := r.Repair(context.Background())
+		if err != nil {
+			logger.Error("failed to repair replicas", err, "remaining", remaining)
+			continue
+		}
+		logger.Info("repaired replicas", "remaining", remaining)
+	}
+}
+
+func resyncReplicas(r *replicated.Replicated, inventory fs.InventoryStore, logger *portlog.PortLog) {
+	ctx := context.Background()
+	buckets, err := inventory.GetBuckets(ctx)
+	if err != nil {
+		logger.Error("failed to resync replicas", err)
+		return
+	}
+
+	queued := 0
+	for _, b := range buckets {
+		n, err := r.Resync(ctx, b.Bucket)
+		queued += n
+		if err != nil {
+			logger.Error("failed to resync replicas", err, "bucket", b.Bucket)
+		}
+	}
+	if queued > 0 {
+		logger.Info("resynced replicas", "buckets", len(buckets), "queued", queued)
+	}
+}
+
+type store interface {
+	fs.MetaStore
+	fs.InventoryStore
+	user.Store
+	tag.Store
+	album.Store
+	vault.Store
+}
+
+func openDatabase(cfg config.Database) (store, *database.DBConnection, error) {
+	switch cfg.DBEngine {
+	case "sqlite3":
+		db, err := sqlite.NewSQLiteDB(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Conn, nil
+	case "postgres":
+		db, err := postgres.NewPostgresDB(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Conn, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported database engine %q", cfg.DBEngine)
+	}
+}
@@ -5,8 +5,16 @@ go 1.26.1
 require (
 	cloud.google.com/go/storage v1.55.0
 	github.com/google/uuid v1.6.0
+	github.com/jackc/pgx/v5 v5.7.5
 	github.com/mattn/go-sqlite3 v1.14.28
 	github.com/portbound/portlog v0.0.0-20260311154148-e184144aeed5
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
 )
 
 require github.com/kelseyhightower/envconfig v1.4.0 // direct
@@ -0,0 +1,64 @@
+package album
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+type Album struct {
+	Id     string `json:"id"`
+	UserId string `json:"user_id"`
+	Name   string `json:"name"`
+	// CoverId is the file shown for the album: the chosen cover, else the
+	// first file, empty for an empty album.
+	CoverId   string    `json:"cover_id,omitempty"`
+	FileCount int64     `json:"file_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store methods fail with sql.ErrNoRows when the album, or any file named,
+// is not the user's.
+type Store interface {
+	CreateAlbum(ctx context.Context, a *Album) error
+	GetAlbum(ctx context.Context, albumId, userId string) (*Album, error)
+	// GetAlbums returns the user's albums, newest first.
+	GetAlbums(ctx context.Context, userId string) ([]Album, error)
+	RenameAlbum(ctx context.Context, albumId, userId, name string) error
+	// SetAlbumCover sets the cover to a file in the album, or clears it when
+	// fileId is empty.
+	SetAlbumCover(ctx context.Context, albumId, userId, fileId string) error
+	DeleteAlbum(ctx context.Context, albumId, userId string) error
+	// UpdateAlbumFiles appends add to the end of the album and drops remove
+	// from it. Files already in the album keep their place.
+	UpdateAlbumFiles(ctx context.Context, albumId, userId string, add, remove []string) error
+	// ReorderAlbum sets the order of the album, fileIds must hold every file
+	// in it exactly once.
+	ReorderAlbum(ctx context.Context, albumId, userId string, fileIds []string) error
+	GetAlbumFiles(ctx context.Context, albumId, userId string) ([]fs.Metadata, error)
+}
+
+type UpdateRequest struct {
+	AlbumId string
+	UserId  string
+	Name    *string
+	// CoverId sets the cover, an empty string goes back to the first file.
+	CoverId *string
+}
+
+type UpdateFilesRequest struct {
+	AlbumId string
+	UserId  string
+	Add     []string
+	Remove  []string
+}
+
+var (
+	ErrInvalidName    = errors.New("invalid album name")
+	ErrInvalidRequest = errors.New("invalid album request")
+	// ErrNotInAlbum is returned when a cover or ordering names a file that
+	// is not in the album, or leaves one out.
+	ErrNotInAlbum = errors.New("file is not in the album")
+)
@@ -0,0 +1,198 @@
+package album
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/portbound/go-fs/internal/auth"
+	"github.com/portbound/go-fs/internal/platform/http/response"
+	"github.com/portbound/go-fs/internal/user"
+	"github.com/portbound/portlog"
+)
+
+type Handler struct {
+	service *Service
+	logger  *portlog.PortLog
+}
+
+func NewHandler(s *Service, l *portlog.PortLog) *Handler {
+	return &Handler{service: s, logger: l}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /albums", h.handleListAlbums)
+	mux.HandleFunc("POST /albums", h.handleCreateAlbum)
+	mux.HandleFunc("GET /albums/{id}", h.handleGetAlbum)
+	mux.HandleFunc("PATCH /albums/{id}", h.handleUpdateAlbum)
+	mux.HandleFunc("DELETE /albums/{id}", h.handleDeleteAlbum)
+	mux.HandleFunc("GET /albums/{id}/files", h.handleGetAlbumFiles)
+	mux.HandleFunc("POST /albums/{id}/files", h.handleUpdateAlbumFiles)
+	mux.HandleFunc("PUT /albums/{id}/order", h.handleReorderAlbum)
+}
+
+func (h *Handler) handleListAlbums(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	albums, err := h.service.List(r.Context(), requester.Id)
+	if err != nil {
+		h.logger.Error("failed to list albums", err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, errors.New("failed to list albums"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, albums)
+}
+
+func (h *Handler) handleCreateAlbum(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	album, err := h.service.Create(r.Context(), requester.Id, body.Name)
+	if err != nil {
+		h.error(w, err, "failed to create album", "")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, album)
+}
+
+func (h *Handler) handleGetAlbum(w http.ResponseWriter, r *http.Request) {
+	albumId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	album, err := h.service.Get(r.Context(), albumId, requester.Id)
+	if err != nil {
+		h.error(w, err, "failed to get album", albumId)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, album)
+}
+
+// handleUpdateAlbum renames the album with {"name"} and sets its cover with
+// {"cover_id"}, either or both.
+func (h *Handler) handleUpdateAlbum(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name    *string `json:"name"`
+		CoverId *string `json:"cover_id"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+
+	albumId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	request := UpdateRequest{
+		AlbumId: albumId,
+		UserId:  requester.Id,
+		Name:    body.Name,
+		CoverId: body.CoverId,
+	}
+
+	album, err := h.service.Update(r.Context(), request)
+	if err != nil {
+		h.error(w, err, "failed to update album", albumId)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, album)
+}
+
+func (h *Handler) handleDeleteAlbum(w http.ResponseWriter, r *http.Request) {
+	albumId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	if err := h.service.Delete(r.Context(), albumId, requester.Id); err != nil {
+		h.error(w, err, "failed to delete album", albumId)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleGetAlbumFiles(w http.ResponseWriter, r *http.Request) {
+	albumId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	files, err := h.service.Files(r.Context(), albumId, requester.Id)
+	if err != nil {
+		h.error(w, err, "failed to get album files", albumId)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, files)
+}
+
+// handleUpdateAlbumFiles applies {"add": [...], "remove": [...]} in one
+// transaction and returns the album.
+func (h *Handler) handleUpdateAlbumFiles(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+
+	albumId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	request := UpdateFilesRequest{
+		AlbumId: albumId,
+		UserId:  requester.Id,
+		Add:     body.Add,
+		Remove:  body.Remove,
+	}
+
+	album, err := h.service.UpdateFiles(r.Context(), request)
+	if err != nil {
+		h.error(w, err, "failed to update album files", albumId)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, album)
+}
+
+// handleReorderAlbum takes {"file_ids": [...]} with every file in the album
+// in its new order.
+func (h *Handler) handleReorderAlbum(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		FileIds []string `json:"file_ids"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+
+	albumId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	if err := h.service.Reorder(r.Context(), albumId, requester.Id, body.FileIds); err != nil {
+		h.error(w, err, "failed to reorder album", albumId)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) error(w http.ResponseWriter, err error, msg, albumId string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		response.Error(w, http.StatusNotFound, fmt.Errorf("album or file not found for album %q", albumId))
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrNotInAlbum):
+		response.Error(w, http.StatusBadRequest, err)
+	default:
+		h.logger.Error(msg, err, "albumId", albumId)
+		response.Error(w, http.StatusInternalServerError, errors.New(msg))
+	}
+}
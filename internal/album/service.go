@@ -0,0 +1,183 @@
+package album
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+const (
+	maxNameLength      = 200
+	maxFilesPerRequest = 500
+)
+
+type Service struct {
+	store Store
+}
+
+func NewService(s Store) *Service {
+	return &Service{store: s}
+}
+
+func (s *Service) Create(ctx context.Context, userId, name string) (*Album, error) {
+	name, err := validateName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Album{
+		Id:        uuid.NewString(),
+		UserId:    userId,
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := s.store.CreateAlbum(dbCtx, a); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (s *Service) Get(ctx context.Context, albumId, userId string) (*Album, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return s.store.GetAlbum(dbCtx, albumId, userId)
+}
+
+func (s *Service) List(ctx context.Context, userId string) ([]Album, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	albums, err := s.store.GetAlbums(dbCtx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if albums == nil {
+		albums = []Album{}
+	}
+
+	return albums, nil
+}
+
+// Update renames the album and or changes its cover, then returns it.
+func (s *Service) Update(ctx context.Context, request UpdateRequest) (*Album, error) {
+	if request.Name == nil && request.CoverId == nil {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidRequest)
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if request.Name != nil {
+		name, err := validateName(*request.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.store.RenameAlbum(dbCtx, request.AlbumId, request.UserId, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.CoverId != nil {
+		if err := s.store.SetAlbumCover(dbCtx, request.AlbumId, request.UserId, *request.CoverId); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.store.GetAlbum(dbCtx, request.AlbumId, request.UserId)
+}
+
+func (s *Service) Delete(ctx context.Context, albumId, userId string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return s.store.DeleteAlbum(dbCtx, albumId, userId)
+}
+
+func (s *Service) UpdateFiles(ctx context.Context, request UpdateFilesRequest) (*Album, error) {
+	add, remove := dedupe(request.Add), dedupe(request.Remove)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil, fmt.Errorf("%w: no files to add or remove", ErrInvalidRequest)
+	}
+
+	if len(add)+len(remove) > maxFilesPerRequest {
+		return nil, fmt.Errorf("%w: at most %d files can change at once", ErrInvalidRequest, maxFilesPerRequest)
+	}
+
+	for _, id := range add {
+		if slices.Contains(remove, id) {
+			return nil, fmt.Errorf("%w: %q is both added and removed", ErrInvalidRequest, id)
+		}
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := s.store.UpdateAlbumFiles(dbCtx, request.AlbumId, request.UserId, add, remove); err != nil {
+		return nil, err
+	}
+
+	return s.store.GetAlbum(dbCtx, request.AlbumId, request.UserId)
+}
+
+func (s *Service) Reorder(ctx context.Context, albumId, userId string, fileIds []string) error {
+	if len(dedupe(fileIds)) != len(fileIds) {
+		return fmt.Errorf("%w: files must appear once each", ErrInvalidRequest)
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.store.ReorderAlbum(dbCtx, albumId, userId, fileIds)
+}
+
+func (s *Service) Files(ctx context.Context, albumId, userId string) ([]fs.Metadata, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	files, err := s.store.GetAlbumFiles(dbCtx, albumId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if files == nil {
+		files = []fs.Metadata{}
+	}
+
+	return files, nil
+}
+
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength || !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: must be between 1 and %d characters", ErrInvalidName, maxNameLength)
+	}
+
+	return name, nil
+}
+
+// dedupe drops repeats and keeps the first occurrence of each id, so the
+// order a client sent is kept.
+func dedupe(ids []string) []string {
+	var unique []string
+	for _, id := range ids {
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,136 @@
+package album
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+type mockStore struct {
+	created     *Album
+	renamed     string
+	add, remove []string
+	order       []string
+}
+
+func (m *mockStore) CreateAlbum(ctx context.Context, a *Album) error {
+	m.created = a
+	return nil
+}
+
+func (m *mockStore) GetAlbum(ctx context.Context, albumId, userId string) (*Album, error) {
+	return &Album{Id: albumId, UserId: userId, Name: m.renamed}, nil
+}
+
+func (m *mockStore) GetAlbums(ctx context.Context, userId string) ([]Album, error) {
+	return nil, nil
+}
+
+func (m *mockStore) RenameAlbum(ctx context.Context, albumId, userId, name string) error {
+	m.renamed = name
+	return nil
+}
+
+func (m *mockStore) SetAlbumCover(ctx context.Context, albumId, userId, fileId string) error {
+	return nil
+}
+
+func (m *mockStore) DeleteAlbum(ctx context.Context, albumId, userId string) error {
+	return nil
+}
+
+func (m *mockStore) UpdateAlbumFiles(ctx context.Context, albumId, userId string, add, remove []string) error {
+	m.add, m.remove = add, remove
+	return nil
+}
+
+func (m *mockStore) ReorderAlbum(ctx context.Context, albumId, userId string, fileIds []string) error {
+	m.order = fileIds
+	return nil
+}
+
+func (m *mockStore) GetAlbumFiles(ctx context.Context, albumId, userId string) ([]fs.Metadata, error) {
+	return nil, nil
+}
+
+func TestService_Create(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantName string
+		wantErr  error
+	}{
+		{name: "trims", input: "  Hawaii 2024 ", wantName: "Hawaii 2024"},
+		{name: "blank", input: "   ", wantErr: ErrInvalidName},
+		{name: "too long", input: strings.Repeat("a", maxNameLength+1), wantErr: ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{}
+			a, err := NewService(store).Create(context.Background(), "u1", tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if a.Name != tt.wantName || a.Id == "" || store.created != a {
+				t.Errorf("Create() = %+v, want a saved album named %q", a, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestService_UpdateFiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    UpdateFilesRequest
+		wantErr    error
+		wantAdd    []string
+		wantRemove []string
+	}{
+		{
+			name:       "keeps order and drops repeats",
+			request:    UpdateFilesRequest{Add: []string{"f3", "f1", "f3"}, Remove: []string{"f2"}},
+			wantAdd:    []string{"f3", "f1"},
+			wantRemove: []string{"f2"},
+		},
+		{name: "nothing to do", request: UpdateFilesRequest{}, wantErr: ErrInvalidRequest},
+		{name: "add and remove", request: UpdateFilesRequest{Add: []string{"f1"}, Remove: []string{"f1"}}, wantErr: ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{}
+			_, err := NewService(store).UpdateFiles(context.Background(), tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateFiles() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(store.add, tt.wantAdd) || !reflect.DeepEqual(store.remove, tt.wantRemove) {
+				t.Errorf("UpdateAlbumFiles(%v, %v), want (%v, %v)", store.add, store.remove, tt.wantAdd, tt.wantRemove)
+			}
+		})
+	}
+}
+
+func TestService_Reorder(t *testing.T) {
+	store := &mockStore{}
+	service := NewService(store)
+	if err := service.Reorder(context.Background(), "a1", "u1", []string{"f1", "f2", "f1"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Reorder() with a repeat err = %v, want ErrInvalidRequest", err)
+	}
+
+	if err := service.Reorder(context.Background(), "a1", "u1", []string{"f2", "f1"}); err != nil || !reflect.DeepEqual(store.order, []string{"f2", "f1"}) {
+		t.Errorf("Reorder() = %v, order %v", err, store.order)
+	}
+}
@@ -1,18 +1,228 @@
 package config
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 )
 
-type Config struct {
-	ServerPort         string `envconfig:"SERVER_PORT" required:"true"`
-	Environment        string `envconfig:"ENVIRONMENT" required:"true"`
+type Database struct {
+	// DBEngine is sqlite3 or postgres, DBConnectionString is a file path for
+	// the former and a postgres:// URL or key=value DSN for the latter.
+	DBEngine           string `envconfig:"DB_ENGINE" default:"sqlite3"`
 	DBConnectionString string `envconfig:"DB_CONNECTION_STRING" default:"data/sqlite.db" required:"true"`
+}
+
+type Storage struct {
 	// GOOGLE_APPLICATION_CREDENTIALS
+	StorageBackend   string `envconfig:"STORAGE_BACKEND" default:"gcs"`
+	LocalStorageRoot string `envconfig:"LOCAL_STORAGE_ROOT" default:"data/media"`
+	GCSProjectId     string `envconfig:"GCS_PROJECT_ID"`
+	// GCS buckets are created in GCSBucketLocation, and provisioning brings
+	// every bucket in line with the rest of these settings. A zero
+	// GCSSoftDeleteRetention turns soft delete off.
+	GCSBucketLocation      string        `envconfig:"GCS_BUCKET_LOCATION" default:"us-east4"`
+	GCSStorageClass        string        `envconfig:"GCS_STORAGE_CLASS" default:"STANDARD"`
+	GCSVersioning          bool          `envconfig:"GCS_VERSIONING" default:"false"`
+	GCSSoftDeleteRetention time.Duration `envconfig:"GCS_SOFT_DELETE_RETENTION" default:"168h"`
+	// GCSLifecycle is a comma separated list of CLASS:DAYS rules, moving
+	// objects to a colder storage class once they are DAYS old, and
+	// noncurrent:DAYS, deleting versions DAYS after they were replaced.
+	GCSLifecycle []string `envconfig:"GCS_LIFECYCLE"`
+	// StorageEncryptionKey is a base64 encoded 32 byte master key. When set,
+	// objects are encrypted before they reach the backend.
+	StorageEncryptionKey string `envconfig:"STORAGE_ENCRYPTION_KEY"`
+	// StorageEncryptionPlaintextBefore is when encryption was turned on, as
+	// RFC 3339. Objects stored in the clear before then are still served,
+	// any other object without encryption is refused.
+	StorageEncryptionPlaintextBefore time.Time `envconfig:"STORAGE_ENCRYPTION_PLAINTEXT_BEFORE"`
+	// StorageReplicas are secondary backends every object is copied to, as a
+	// comma separated list of local:<root> and gcs:<project id>.
+	StorageReplicas []string `envconfig:"STORAGE_REPLICAS"`
+}
+
+// LifecycleRule is one entry of GCSLifecycle.
+type LifecycleRule struct {
+	Days int64
+	// StorageClass is empty for a noncurrent rule.
+	StorageClass string
+}
+
+var storageClasses = []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE"}
+
+// Replica is one entry of StorageReplicas.
+type Replica struct {
+	// Backend is local or gcs, Location the root or the project id.
+	Backend  string
+	Location string
+}
+
+func (s *Storage) validate() error {
+	if s.StorageBackend == "gcs" && s.GCSProjectId == "" {
+		return errors.New("GCS_PROJECT_ID is required when STORAGE_BACKEND is gcs")
+	}
+
+	if _, err := s.EncryptionKey(); err != nil {
+		return err
+	}
+
+	if _, err := s.Replicas(); err != nil {
+		return err
+	}
+
+	if s.GCSBucketLocation == "" {
+		return errors.New("GCS_BUCKET_LOCATION is required")
+	}
+
+	if !slices.Contains(storageClasses, s.GCSStorageClass) {
+		return fmt.Errorf("GCS_STORAGE_CLASS %q is not one of %s", s.GCSStorageClass, strings.Join(storageClasses, ", "))
+	}
+
+	// GCS only takes whole days between 7 and 90.
+	if r := s.GCSSoftDeleteRetention; r != 0 && (r%(24*time.Hour) != 0 || r < 7*24*time.Hour || r > 90*24*time.Hour) {
+		return fmt.Errorf("GCS_SOFT_DELETE_RETENTION %v is not 0 or a whole number of days between 7 and 90", r)
+	}
+
+	if _, err := s.LifecycleRules(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LifecycleRules parses GCSLifecycle.
+func (s *Storage) LifecycleRules() ([]LifecycleRule, error) {
+	var rules []LifecycleRule
+	for _, entry := range s.GCSLifecycle {
+		action, days, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("GCS_LIFECYCLE entry %q is not ACTION:DAYS", entry)
+		}
+
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("GCS_LIFECYCLE entry %q does not have a positive number of days", entry)
+		}
+
+		rule := LifecycleRule{Days: n}
+		switch {
+		case action == "noncurrent":
+			if !s.GCSVersioning {
+				return nil, fmt.Errorf("GCS_LIFECYCLE entry %q needs GCS_VERSIONING", entry)
+			}
+		case slices.Contains(storageClasses, action):
+			rule.StorageClass = action
+		default:
+			return nil, fmt.Errorf("GCS_LIFECYCLE entry %q is neither noncurrent nor one of %s", entry, strings.Join(storageClasses, ", "))
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Replicas parses StorageReplicas.
+func (s *Storage) Replicas() ([]Replica, error) {
+	// Bucket names are global in GCS, a second gcs store would be writing to
+	// the very same buckets.
+	gcs := s.StorageBackend == "gcs"
+	roots := map[string]bool{}
+	if s.StorageBackend == "local" {
+		roots[filepath.Clean(s.LocalStorageRoot)] = true
+	}
+
+	var replicas []Replica
+	for _, entry := range s.StorageReplicas {
+		backend, location, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || location == "" {
+			return nil, fmt.Errorf("STORAGE_REPLICAS entry %q is not backend:location", entry)
+		}
+
+		switch backend {
+		case "local":
+			root := filepath.Clean(location)
+			if roots[root] {
+				return nil, fmt.Errorf("STORAGE_REPLICAS stores %q twice", root)
+			}
+			roots[root] = true
+		case "gcs":
+			if gcs {
+				return nil, errors.New("STORAGE_REPLICAS can only use gcs once, including the primary backend")
+			}
+			gcs = true
+		default:
+			return nil, fmt.Errorf("STORAGE_REPLICAS entry %q has unsupported backend %q", entry, backend)
+		}
+
+		replicas = append(replicas, Replica{Backend: backend, Location: location})
+	}
+
+	return replicas, nil
+}
+
+// EncryptionKey decodes StorageEncryptionKey, it is nil if encryption is off.
+func (s *Storage) EncryptionKey() ([]byte, error) {
+	if s.StorageEncryptionKey == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(s.StorageEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("STORAGE_ENCRYPTION_KEY is not base64: %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("STORAGE_ENCRYPTION_KEY is %d bytes, want 32", len(key))
+	}
+
+	return key, nil
+}
+
+type Config struct {
+	ServerPort  string `envconfig:"SERVER_PORT" required:"true"`
+	Environment string `envconfig:"ENVIRONMENT" required:"true"`
+	Database
+	Storage
 	GoogleClientID string `envconfig:"GOOGLE_CLIENT_ID" required:"true"`
-	GCSProjectId   string `envconfig:"GCS_PROJECT_ID" required:"true"`
 	JWTSecret      string `envconfig:"JWT_SECRET" required:"true"`
+	// Trashed files are purged for good once they are older than
+	// TrashRetention, checked every TrashPurgeInterval.
+	TrashRetention     time.Duration `envconfig:"TRASH_RETENTION" default:"720h"`
+	TrashPurgeInterval time.Duration `envconfig:"TRASH_PURGE_INTERVAL" default:"1h"`
+	// FsckInterval is how often the server checks storage against the
+	// database, zero turns it off. FsckDryRun only reports what it finds.
+	FsckInterval time.Duration `envconfig:"FSCK_INTERVAL" default:"24h"`
+	FsckDryRun   bool          `envconfig:"FSCK_DRY_RUN" default:"true"`
+	// With STORAGE_REPLICAS set, replicas that fell behind are repaired every
+	// ReplicaRepairInterval, and compared in full every ReplicaResyncInterval
+	// to catch what was missed across restarts.
+	ReplicaRepairInterval time.Duration `envconfig:"REPLICA_REPAIR_INTERVAL" default:"1m"`
+	ReplicaResyncInterval time.Duration `envconfig:"REPLICA_RESYNC_INTERVAL" default:"24h"`
+	// Resumable uploads are staged in ResumableUploadDir, up to
+	// ResumableUploadMaxSize bytes each, and expire ResumableUploadExpiry
+	// after their last chunk.
+	ResumableUploadDir     string        `envconfig:"RESUMABLE_UPLOAD_DIR" default:"tmp/uploads"`
+	ResumableUploadMaxSize int64         `envconfig:"RESUMABLE_UPLOAD_MAX_SIZE" default:"10737418240"`
+	ResumableUploadExpiry  time.Duration `envconfig:"RESUMABLE_UPLOAD_EXPIRY" default:"24h"`
+	// Signed download URLs stay valid for between SignedURLExpiry and twice
+	// that.
+	SignedURLExpiry time.Duration `envconfig:"SIGNED_URL_EXPIRY" default:"1h"`
+	// Uploads are processed UploadWorkers at a time, which is also how many
+	// files are staged on disk at most. The rest bound the ffmpeg runs,
+	// bucket writes and database writes those workers share.
+	UploadWorkers             int `envconfig:"UPLOAD_WORKERS" default:"4"`
+	UploadFFmpegConcurrency   int `envconfig:"UPLOAD_FFMPEG_CONCURRENCY" default:"2"`
+	UploadStorageConcurrency  int `envconfig:"UPLOAD_STORAGE_CONCURRENCY" default:"4"`
+	UploadDatabaseConcurrency int `envconfig:"UPLOAD_DATABASE_CONCURRENCY" default:"2"`
 }
 
 func Load() (*Config, error) {
@@ -24,5 +234,62 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Storage.validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.TrashPurgeInterval <= 0 {
+		return nil, errors.New("TRASH_PURGE_INTERVAL must be positive")
+	}
+
+	if cfg.ResumableUploadMaxSize <= 0 || cfg.ResumableUploadExpiry <= 0 {
+		return nil, errors.New("RESUMABLE_UPLOAD_MAX_SIZE and RESUMABLE_UPLOAD_EXPIRY must be positive")
+	}
+
+	if min(cfg.UploadWorkers, cfg.UploadFFmpegConcurrency, cfg.UploadStorageConcurrency, cfg.UploadDatabaseConcurrency) < 1 {
+		return nil, errors.New("UPLOAD_WORKERS and the UPLOAD_*_CONCURRENCY limits must be positive")
+	}
+
+	// GCS signs URLs for up to seven days.
+	if cfg.SignedURLExpiry <= 0 || cfg.SignedURLExpiry > 84*time.Hour {
+		return nil, errors.New("SIGNED_URL_EXPIRY must be positive and at most 84h")
+	}
+
+	if len(cfg.StorageReplicas) > 0 && (cfg.ReplicaRepairInterval <= 0 || cfg.ReplicaResyncInterval <= 0) {
+		return nil, errors.New("REPLICA_REPAIR_INTERVAL and REPLICA_RESYNC_INTERVAL must be positive")
+	}
+
+	return &cfg, nil
+}
+
+// LoadDatabase loads only the database settings, for tools that do not run the
+// server.
+func LoadDatabase() (*Database, error) {
+	_ = godotenv.Load()
+
+	var cfg Database
+	err := envconfig.Process("", &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// LoadStorage loads only the storage settings, for tools that do not run the
+// server.
+func LoadStorage() (*Storage, error) {
+	_ = godotenv.Load()
+
+	var cfg Storage
+	err := envconfig.Process("", &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// cursor is what a client holds between pages. It is opaque to them, the
+// encoding only needs to round trip through a query string.
+type cursor struct {
+	SortBy    SortBy    `json:"s"`
+	Ascending bool      `json:"a,omitempty"`
+	Time      time.Time `json:"t,omitzero"`
+	Name      string    `json:"n,omitempty"`
+	Size      int64     `json:"z,omitempty"`
+	Id        string    `json:"i"`
+}
+
+func encodeCursor(opts ListOptions, m Metadata) string {
+	p := positionOf(opts.SortBy, m)
+	c := cursor{
+		SortBy:    opts.SortBy,
+		Ascending: opts.Ascending,
+		Time:      p.Time,
+		Name:      p.Name,
+		Size:      p.Size,
+		Id:        p.Id,
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor rejects cursors from a listing in a different order, their
+// position means nothing in this one.
+func decodeCursor(s string, opts ListOptions) (*Position, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.SortBy != opts.SortBy || c.Ascending != opts.Ascending || c.Id == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Position{Time: c.Time, Name: c.Name, Size: c.Size, Id: c.Id}, nil
+}
+
+func positionOf(sortBy SortBy, m Metadata) Position {
+	p := Position{Id: m.Id}
+	switch sortBy {
+	case SortByTaken:
+		p.Time = m.UploadedAt
+		if m.TakenAt != nil {
+			p.Time = *m.TakenAt
+		}
+	case SortByUploaded:
+		p.Time = m.UploadedAt
+	case SortByName:
+		p.Name = m.Filename
+	case SortBySize:
+		p.Size = m.Size
+	}
+
+	return p
+}
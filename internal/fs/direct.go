@@ -0,0 +1,223 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// directUploadExpiry is how long a signed upload stays valid. It is well
+	// inside staleUploadAge, so the sweep never takes an upload the client
+	// could still be sending.
+	directUploadExpiry = 15 * time.Minute
+
+	maxDirectUploadSize = 10 << 30
+
+	// incomingPrefix names the object a direct upload is stored under. The
+	// bytes never pass through the server, so unlike other uploads it is not
+	// named by its content and shares nothing with identical files.
+	incomingPrefix = "incoming-"
+
+	// directHeadSize is how much of a direct upload is read to probe it.
+	// It is xmpScanLimit, writers put EXIF and XMP ahead of the pixel data.
+	directHeadSize = xmpScanLimit
+)
+
+// EnableDirectUploads lets clients upload straight to d, which has to be the
+// backend under the service's MediaStore.
+func (s *Service) EnableDirectUploads(d DirectStore) {
+	s.direct = d
+}
+
+// CreateDirectUpload claims the filename with a pending row and signs an
+// upload of the file into the bucket. The file only shows up once
+// FinalizeDirectUpload is called, an upload never finalized is swept along
+// with whatever the client stored.
+func (s *Service) CreateDirectUpload(ctx context.Context, request DirectUploadRequest) (*DirectUpload, error) {
+	if s.direct == nil {
+		return nil, ErrDirectUploadUnavailable
+	}
+
+	filename := filepath.Base(request.Filename)
+	if request.Filename == "" || filename == "." || filename == string(filepath.Separator) {
+		return nil, fmt.Errorf("%w: a filename is required", ErrInvalidDirectUpload)
+	}
+
+	if request.Size <= 0 || request.Size > maxDirectUploadSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d bytes", ErrInvalidDirectUpload, maxDirectUploadSize)
+	}
+
+	fileType := strings.Split(request.ContentType, "/")[0]
+	if fileType != "image" && fileType != "video" {
+		return nil, ErrUnsupportedFileType
+	}
+
+	id := uuid.New().String()
+	meta := Metadata{
+		Id:          id,
+		Filename:    filename,
+		Thumbname:   "thumb-" + incomingPrefix + id,
+		UserId:      request.UserId,
+		Bucket:      request.Bucket,
+		Object:      incomingPrefix + id,
+		ContentType: request.ContentType,
+		Size:        request.Size,
+		UploadedAt:  time.Now().UTC(),
+		Pending:     true,
+	}
+
+	if err := s.save(ctx, &meta); err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(directUploadExpiry).UTC()
+	signed, err := s.direct.SignUpload(ctx, meta.Object, meta.Bucket, meta.ContentType, meta.Size, expires)
+	if err != nil {
+		err = fmt.Errorf("sign upload: %w", err)
+		if purgeErr := s.purge(context.WithoutCancel(ctx), &meta); purgeErr != nil {
+			return nil, errors.Join(err, purgeErr)
+		}
+		return nil, err
+	}
+
+	return &DirectUpload{Id: id, Upload: *signed, ExpiresAt: expires}, nil
+}
+
+// FinalizeDirectUpload takes in what the client uploaded: it is checked
+// against what was signed, probed and thumbnailed like any upload, and listed
+// under the name it was uploaded to. Only the start of the file is read, ffmpeg
+// reads what else it needs in place. If the file turns out to be bad the
+// upload is dropped, otherwise a failed finalize can be tried again.
+func (s *Service) FinalizeDirectUpload(ctx context.Context, fileId, userId string) (*Metadata, error) {
+	if s.direct == nil {
+		return nil, ErrDirectUploadUnavailable
+	}
+
+	var placeholder *Metadata
+	err := limited(ctx, s.limits.database, func() error {
+		dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		var err error
+		placeholder, err = s.meta.GetPending(dbCtx, fileId, userId)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get pending upload: %w", err)
+	}
+
+	// Other pending rows are uploads through the server still under way.
+	if !strings.HasPrefix(placeholder.Object, incomingPrefix) {
+		return nil, fmt.Errorf("%w: %q is not a direct upload", ErrInvalidDirectUpload, fileId)
+	}
+
+	// Only the object's metadata is wanted, the reader is closed unread.
+	info, reader, err := s.media.Download(ctx, placeholder.Object, placeholder.Bucket)
+	if err != nil {
+		if errors.Is(err, ErrMediaNotExist) {
+			return nil, fmt.Errorf("%w: nothing was uploaded", ErrInvalidDirectUpload)
+		}
+		return nil, fmt.Errorf("get upload: %w", err)
+	}
+	reader.Close()
+
+	if info.Size != placeholder.Size {
+		return nil, s.dropDirectUpload(ctx, placeholder, fmt.Errorf("%w: uploaded %d bytes, not %d", ErrInvalidDirectUpload, info.Size, placeholder.Size))
+	}
+
+	head, err := s.readHead(ctx, placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := s.direct.Locate(ctx, placeholder.Object, placeholder.Bucket, time.Now().Add(directUploadExpiry))
+	if err != nil {
+		return nil, fmt.Errorf("locate upload: %w", err)
+	}
+
+	fileType := strings.Split(placeholder.ContentType, "/")[0]
+	probed, err := s.probe(ctx, head, input, fileType)
+	if err != nil {
+		return nil, s.dropDirectUpload(ctx, placeholder, fmt.Errorf("%w: probe media: %w", ErrInvalidDirectUpload, err))
+	}
+
+	capture := extractCaptureInfo(head, fileType, probed)
+	if capture.exif != nil && capture.exif.Orientation >= 5 {
+		probed.width, probed.height = probed.height, probed.width
+	}
+
+	thumbReader, err := s.thumbnail(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("generate thumbnail: %w", err)
+	}
+
+	// The thumbnail goes where the placeholder's points, so a finalize that
+	// never completes leaves nothing the sweep does not remove.
+	if err := limited(ctx, s.limits.storage, func() error {
+		return s.media.Upload(ctx, placeholder.Thumbname, placeholder.Bucket, thumbReader)
+	}); err != nil {
+		return nil, fmt.Errorf("upload thumbnail: %w", err)
+	}
+
+	meta := Metadata{
+		Id:          placeholder.Id,
+		Filename:    placeholder.Filename,
+		Thumbname:   placeholder.Thumbname,
+		UserId:      placeholder.UserId,
+		Bucket:      placeholder.Bucket,
+		Object:      placeholder.Object,
+		ContentType: placeholder.ContentType,
+		Size:        info.Size,
+		UploadedAt:  time.Now().UTC(),
+		Width:       probed.width,
+		Height:      probed.height,
+		Duration:    probed.duration,
+		TakenAt:     capture.takenAt,
+		Exif:        capture.exif,
+	}
+
+	// The placeholder gives way to the real row in one go, until then it
+	// still owns the objects and a failed finalize can be tried again.
+	if err := limited(ctx, s.limits.database, func() error {
+		dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		return s.meta.ReplacePending(dbCtx, &meta)
+	}); err != nil {
+		return nil, fmt.Errorf("replace placeholder: %w", err)
+	}
+
+	return &meta, nil
+}
+
+// readHead reads the start of a direct upload, enough to probe most images
+// and to find their EXIF and XMP.
+func (s *Service) readHead(ctx context.Context, m *Metadata) (*bytes.Reader, error) {
+	r, err := s.media.DownloadRange(ctx, m.Object, m.Bucket, 0, min(m.Size, directHeadSize))
+	if err != nil {
+		return nil, fmt.Errorf("download start of upload: %w", err)
+	}
+	defer r.Close()
+
+	head, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read start of upload: %w", err)
+	}
+
+	return bytes.NewReader(head), nil
+}
+
+// dropDirectUpload removes an upload whose file is no good, along with what
+// the client stored, and returns err.
+func (s *Service) dropDirectUpload(ctx context.Context, placeholder *Metadata, err error) error {
+	if purgeErr := s.purge(context.WithoutCancel(ctx), placeholder); purgeErr != nil {
+		return errors.Join(err, purgeErr)
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockDirectStore signs uploads into a MockMediaStore, which the test then
+// writes to as the client would.
+type mockDirectStore struct {
+	*MockMediaStore
+}
+
+func (m mockDirectStore) SignUpload(ctx context.Context, name, bucket, contentType string, size int64, expires time.Time) (*SignedRequest, error) {
+	return &SignedRequest{Method: "PUT", URL: "/" + bucket + "/" + name, Headers: map[string]string{"Content-Type": contentType}}, nil
+}
+
+func (m mockDirectStore) Locate(ctx context.Context, name, bucket string, expires time.Time) (string, error) {
+	return "/" + bucket + "/" + name, nil
+}
+
+func TestService_CreateDirectUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  DirectUploadRequest
+		disabled bool
+		wantErr  error
+	}{
+		{name: "valid", request: DirectUploadRequest{Filename: "dir/a.jpg", ContentType: "image/jpeg", Size: 10}},
+		{name: "not enabled", request: DirectUploadRequest{Filename: "a.jpg", ContentType: "image/jpeg", Size: 10}, disabled: true, wantErr: ErrDirectUploadUnavailable},
+		{name: "no filename", request: DirectUploadRequest{ContentType: "image/jpeg", Size: 10}, wantErr: ErrInvalidDirectUpload},
+		{name: "zero size", request: DirectUploadRequest{Filename: "a.jpg", ContentType: "image/jpeg"}, wantErr: ErrInvalidDirectUpload},
+		{name: "too large", request: DirectUploadRequest{Filename: "a.jpg", ContentType: "image/jpeg", Size: maxDirectUploadSize + 1}, wantErr: ErrInvalidDirectUpload},
+		{name: "unsupported type", request: DirectUploadRequest{Filename: "a.txt", ContentType: "text/plain", Size: 10}, wantErr: ErrUnsupportedFileType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			meta := NewMockMetaStore()
+			media := NewMockMediaStore()
+			s := NewService(meta, media)
+			if !tt.disabled {
+				s.EnableDirectUploads(mockDirectStore{media})
+			}
+			tt.request.UserId = "u1"
+
+			upload, err := s.CreateDirectUpload(ctx, tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateDirectUpload() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(meta.store) != 0 {
+					t.Error("refused upload left a row behind")
+				}
+				return
+			}
+
+			if upload.Upload.URL != "/test_bucket/"+incomingPrefix+upload.Id {
+				t.Errorf("signed URL = %q, want one for the incoming object", upload.Upload.URL)
+			}
+			if _, err := meta.Get(ctx, upload.Id, "u1"); err == nil {
+				t.Error("Get() before finalize err = nil, want the file hidden")
+			}
+			got, err := meta.GetPending(ctx, upload.Id, "u1")
+			if err != nil || got.Filename != "a.jpg" || got.Size != 10 {
+				t.Errorf("GetPending() = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestService_FinalizeDirectUpload(t *testing.T) {
+	ctx := context.Background()
+	meta := NewMockMetaStore()
+	media := NewMockMediaStore()
+	s := NewService(meta, media)
+	s.EnableDirectUploads(mockDirectStore{media})
+
+	upload, err := s.CreateDirectUpload(ctx, DirectUploadRequest{Filename: "a.jpg", ContentType: "image/jpeg", Size: 4, UserId: "u1"})
+	if err != nil {
+		t.Fatalf("CreateDirectUpload(): %v", err)
+	}
+
+	if _, err := s.FinalizeDirectUpload(ctx, upload.Id, "u2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("FinalizeDirectUpload() by another user err = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	// Finalizing before the upload is in can be tried again.
+	if _, err := s.FinalizeDirectUpload(ctx, upload.Id, "u1"); !errors.Is(err, ErrInvalidDirectUpload) {
+		t.Fatalf("FinalizeDirectUpload() of nothing err = %v, want %v", err, ErrInvalidDirectUpload)
+	}
+	if _, err := meta.GetPending(ctx, upload.Id, "u1"); err != nil {
+		t.Fatalf("upload is gone after finalizing too early: %v", err)
+	}
+
+	// An upload of another size than signed is dropped.
+	media.Upload(ctx, incomingPrefix+upload.Id, "test_bucket", strings.NewReader("too long"))
+	if _, err := s.FinalizeDirectUpload(ctx, upload.Id, "u1"); !errors.Is(err, ErrInvalidDirectUpload) {
+		t.Fatalf("FinalizeDirectUpload() of the wrong size err = %v, want %v", err, ErrInvalidDirectUpload)
+	}
+	if _, err := meta.GetPending(ctx, upload.Id, "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPending() after a bad upload err = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, _, err := media.Download(ctx, incomingPrefix+upload.Id, "test_bucket"); !errors.Is(err, ErrMediaNotExist) {
+		t.Errorf("bad upload's object survived: %v", err)
+	}
+
+	// Uploads through the server are pending too, they are not the client's
+	// to finalize.
+	meta.Save(ctx, &Metadata{Id: "inflight", UserId: "u1", Filename: "b.jpg", Thumbname: "thumb-abc", Object: "abc", SHA256: "abc", UploadedAt: time.Now(), Pending: true})
+	if _, err := s.FinalizeDirectUpload(ctx, "inflight", "u1"); !errors.Is(err, ErrInvalidDirectUpload) {
+		t.Errorf("FinalizeDirectUpload() of a server upload err = %v, want %v", err, ErrInvalidDirectUpload)
+	}
+}
+
+func TestService_FinalizeDirectUpload_keepsUploadOnFailure(t *testing.T) {
+	ctx := context.Background()
+	meta := NewMockMetaStore()
+	media := NewMockMediaStore()
+	s := NewService(meta, media)
+	s.EnableDirectUploads(mockDirectStore{media})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	upload, err := s.CreateDirectUpload(ctx, DirectUploadRequest{Filename: "a.png", ContentType: "image/png", Size: int64(buf.Len()), UserId: "u1"})
+	if err != nil {
+		t.Fatalf("CreateDirectUpload(): %v", err)
+	}
+	media.Upload(ctx, incomingPrefix+upload.Id, "test_bucket", bytes.NewReader(buf.Bytes()))
+
+	// The mock locates objects nowhere ffmpeg can read them, so the finalize
+	// fails after the probe, once the file is known to be good.
+	if _, err := s.FinalizeDirectUpload(ctx, upload.Id, "u1"); err == nil || errors.Is(err, ErrInvalidDirectUpload) {
+		t.Fatalf("FinalizeDirectUpload() err = %v, want a failure to thumbnail", err)
+	}
+
+	if _, err := meta.GetPending(ctx, upload.Id, "u1"); err != nil {
+		t.Errorf("placeholder is gone after a failed finalize: %v", err)
+	}
+	if _, _, err := media.Download(ctx, incomingPrefix+upload.Id, "test_bucket"); err != nil {
+		t.Errorf("upload is gone after a failed finalize: %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package fs
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// xmpScanLimit bounds how much of a file is searched for an XMP packet. Writers
+// put it in the header, so anything further in is almost certainly pixel data.
+const xmpScanLimit = 512 << 10
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// captureInfo is what the file itself says about when and how it was taken, as
+// opposed to anything we know from the upload.
+type captureInfo struct {
+	takenAt *time.Time
+	exif    *Exif
+}
+
+// extractCaptureInfo reads EXIF/TIFF and XMP from images and container tags
+// from videos. Missing or corrupt metadata is normal for screenshots and
+// downloads, so it is never an error, the fields are simply left empty.
+func extractCaptureInfo(f io.ReadSeeker, fileType string, info *mediaInfo) *captureInfo {
+	var ci captureInfo
+	switch fileType {
+	case "image":
+		ci = imageCaptureInfo(f)
+	case "video":
+		ci = videoCaptureInfo(info.tags)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return &captureInfo{}
+	}
+
+	if ci.exif != nil && *ci.exif == (Exif{}) {
+		ci.exif = nil
+	}
+
+	return &ci
+}
+
+func imageCaptureInfo(f io.ReadSeeker) captureInfo {
+	var ci captureInfo
+	e := &Exif{}
+	if x, err := exif.Decode(f); err == nil {
+		ci.takenAt = exifTime(x)
+		e.CameraMake = exifString(x, exif.Make)
+		e.CameraModel = exifString(x, exif.Model)
+		e.LensModel = exifString(x, exif.LensModel)
+		e.Orientation = exifInt(x, exif.Orientation)
+		e.ExposureTime = exifRational(x, exif.ExposureTime)
+		e.FNumber = exifFloat(x, exif.FNumber)
+		e.ISO = exifInt(x, exif.ISOSpeedRatings)
+		e.FocalLength = exifFloat(x, exif.FocalLength)
+		if lat, long, err := x.LatLong(); err == nil {
+			e.Latitude, e.Longitude = &lat, &long
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		ci.exif = e
+		return ci
+	}
+
+	// XMP only fills gaps, EXIF written by the camera wins over XMP written
+	// by whatever edited the file afterwards.
+	if xmp := readXMP(f); xmp != nil {
+		if ci.takenAt == nil {
+			for _, name := range []string{"exif:DateTimeOriginal", "photoshop:DateCreated", "xmp:CreateDate"} {
+				if t := parseXMPTime(xmp.get(name)); t != nil {
+					ci.takenAt = t
+					break
+				}
+			}
+		}
+		e.CameraMake = cmp.Or(e.CameraMake, xmp.get("tiff:Make"))
+		e.CameraModel = cmp.Or(e.CameraModel, xmp.get("tiff:Model"))
+		e.LensModel = cmp.Or(e.LensModel, xmp.get("exifEX:LensModel"), xmp.get("aux:Lens"))
+		if e.Orientation == 0 {
+			e.Orientation, _ = strconv.Atoi(xmp.get("tiff:Orientation"))
+		}
+		if e.Latitude == nil || e.Longitude == nil {
+			lat, latOk := parseXMPCoordinate(xmp.get("exif:GPSLatitude"))
+			long, longOk := parseXMPCoordinate(xmp.get("exif:GPSLongitude"))
+			if latOk && longOk {
+				e.Latitude, e.Longitude = &lat, &long
+			}
+		}
+	}
+
+	ci.exif = e
+	return ci
+}
+
+func videoCaptureInfo(tags map[string]string) captureInfo {
+	var ci captureInfo
+	// Apple writes the local capture time with its offset, creation_time is
+	// the same instant in UTC and is what everything else writes.
+	for _, key := range []string{"com.apple.quicktime.creationdate", "creation_time"} {
+		if t := parseVideoTime(tags[key]); t != nil {
+			ci.takenAt = t
+			break
+		}
+	}
+
+	e := &Exif{
+		CameraMake:  cmp.Or(tags["com.apple.quicktime.make"], tags["make"]),
+		CameraModel: cmp.Or(tags["com.apple.quicktime.model"], tags["model"]),
+	}
+	if lat, long, ok := parseISO6709(cmp.Or(tags["com.apple.quicktime.location.ISO6709"], tags["location"])); ok {
+		e.Latitude, e.Longitude = &lat, &long
+	}
+
+	ci.exif = e
+	return ci
+}
+
+func parseVideoTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// exifTime treats the camera's wall clock as UTC. EXIF rarely records an
+// offset, and guessing one from the server's zone would be worse.
+func exifTime(x *exif.Exif) *time.Time {
+	for _, name := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTimeDigitized, exif.DateTime} {
+		s := exifString(x, name)
+		if s == "" {
+			continue
+		}
+		if t, err := time.ParseInLocation(exifTimeLayout, s, time.UTC); err == nil {
+			return &t
+		}
+	}
+
+	return nil
+}
+
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+
+	s, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+func exifInt(x *exif.Exif, name exif.FieldName) int {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0
+	}
+
+	n, err := tag.Int(0)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+func exifFloat(x *exif.Exif, name exif.FieldName) float64 {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0
+	}
+
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return 0
+	}
+
+	return float64(num) / float64(den)
+}
+
+// exifRational keeps exposure times in the "1/250" form photographers read.
+func exifRational(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return ""
+	}
+
+	if num >= den || num == 0 {
+		return strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64)
+	}
+
+	if den%num == 0 {
+		return "1/" + strconv.FormatInt(den/num, 10)
+	}
+
+	return strconv.FormatInt(num, 10) + "/" + strconv.FormatInt(den, 10)
+}
+
+type xmpPacket []byte
+
+var (
+	xmpStart = []byte("<x:xmpmeta")
+	xmpEnd   = []byte("</x:xmpmeta>")
+)
+
+func readXMP(r io.Reader) xmpPacket {
+	buf, err := io.ReadAll(io.LimitReader(r, xmpScanLimit))
+	if err != nil {
+		return nil
+	}
+
+	start := bytes.Index(buf, xmpStart)
+	if start < 0 {
+		return nil
+	}
+
+	end := bytes.Index(buf[start:], xmpEnd)
+	if end < 0 {
+		return nil
+	}
+
+	return xmpPacket(buf[start : start+end+len(xmpEnd)])
+}
+
+// get returns a property written either as an attribute (name="value") or as
+// a simple element (<name>value</name>), which covers what cameras and
+// common editors produce.
+func (p xmpPacket) get(name string) string {
+	quoted := regexp.QuoteMeta(name)
+	attr := regexp.MustCompile(quoted + `\s*=\s*"([^"]*)"`)
+	if m := attr.FindSubmatch(p); m != nil {
+		return strings.TrimSpace(string(m[1]))
+	}
+
+	elem := regexp.MustCompile(`<` + quoted + `>([^<]*)</` + quoted + `>`)
+	if m := elem.FindSubmatch(p); m != nil {
+		return strings.TrimSpace(string(m[1]))
+	}
+
+	return ""
+}
+
+func parseXMPTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// parseXMPCoordinate parses the XMP GPS form "DDD,MM.mmk" or "DDD,MM,SSk"
+// where k is one of N, S, E or W.
+func parseXMPCoordinate(s string) (float64, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	ref := s[len(s)-1]
+	parts := strings.Split(s[:len(s)-1], ",")
+	var v float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || i > 2 {
+			return 0, false
+		}
+		switch i {
+		case 0:
+			v += n
+		case 1:
+			v += n / 60
+		case 2:
+			v += n / 3600
+		}
+	}
+
+	switch ref {
+	case 'N', 'E':
+	case 'S', 'W':
+		v = -v
+	default:
+		return 0, false
+	}
+
+	return v, true
+}
+
+var iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+// parseISO6709 parses the "+37.7858-122.4064+012.345/" form phones write into
+// video containers. Only the decimal degrees form is used in practice.
+func parseISO6709(s string) (float64, float64, bool) {
+	m := iso6709.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	long, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return lat, long, true
+}
@@ -0,0 +1,98 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageCaptureInfo_XMP(t *testing.T) {
+	xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description
+		exif:DateTimeOriginal="2023-07-04T18:30:00-04:00"
+		exif:GPSLatitude="41,24.5N"
+		exif:GPSLongitude="2,9.75W"
+		tiff:Orientation="6">
+		<tiff:Make>FUJIFILM</tiff:Make>
+		<tiff:Model>X100V</tiff:Model>
+	</rdf:Description></rdf:RDF></x:xmpmeta>`
+
+	path := filepath.Join(t.TempDir(), "edited.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"+xmp), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ci := extractCaptureInfo(f, "image", &mediaInfo{})
+
+	wantTaken := time.Date(2023, 7, 4, 22, 30, 0, 0, time.UTC)
+	if ci.takenAt == nil || !ci.takenAt.Equal(wantTaken) {
+		t.Errorf("takenAt = %v, want %v", ci.takenAt, wantTaken)
+	}
+	if ci.exif == nil {
+		t.Fatal("exif = nil")
+	}
+	if ci.exif.CameraMake != "FUJIFILM" || ci.exif.CameraModel != "X100V" {
+		t.Errorf("camera = %q %q, want FUJIFILM X100V", ci.exif.CameraMake, ci.exif.CameraModel)
+	}
+	if ci.exif.Orientation != 6 {
+		t.Errorf("orientation = %d, want 6", ci.exif.Orientation)
+	}
+	if ci.exif.Latitude == nil || *ci.exif.Latitude != 41+24.5/60 {
+		t.Errorf("latitude = %v, want %v", ci.exif.Latitude, 41+24.5/60)
+	}
+	if ci.exif.Longitude == nil || *ci.exif.Longitude != -(2+9.75/60) {
+		t.Errorf("longitude = %v, want %v", ci.exif.Longitude, -(2 + 9.75/60))
+	}
+}
+
+func TestVideoCaptureInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      map[string]string
+		wantTaken time.Time
+		wantLat   float64
+		wantLong  float64
+	}{
+		{
+			name: "iphone",
+			tags: map[string]string{
+				"creation_time":                        "2024-01-01T12:00:00.000000Z",
+				"com.apple.quicktime.creationdate":     "2024-01-01T07:00:05-0500",
+				"com.apple.quicktime.location.ISO6709": "+40.6892-074.0445+010.000/",
+				"com.apple.quicktime.make":             "Apple",
+			},
+			wantTaken: time.Date(2024, 1, 1, 12, 0, 5, 0, time.UTC),
+			wantLat:   40.6892,
+			wantLong:  -74.0445,
+		},
+		{
+			name: "android",
+			tags: map[string]string{
+				"creation_time": "2024-02-29T08:15:00.000000Z",
+				"location":      "-33.8568+151.2153/",
+			},
+			wantTaken: time.Date(2024, 2, 29, 8, 15, 0, 0, time.UTC),
+			wantLat:   -33.8568,
+			wantLong:  151.2153,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci := videoCaptureInfo(tt.tags)
+			if ci.takenAt == nil || !ci.takenAt.Equal(tt.wantTaken) {
+				t.Errorf("takenAt = %v, want %v", ci.takenAt, tt.wantTaken)
+			}
+			if ci.exif.Latitude == nil || *ci.exif.Latitude != tt.wantLat {
+				t.Errorf("latitude = %v, want %v", ci.exif.Latitude, tt.wantLat)
+			}
+			if ci.exif.Longitude == nil || *ci.exif.Longitude != tt.wantLong {
+				t.Errorf("longitude = %v, want %v", ci.exif.Longitude, tt.wantLong)
+			}
+		})
+	}
+}
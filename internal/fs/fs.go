@@ -5,29 +5,270 @@ import (
 	"errors"
 	"io"
 	"time"
-
-	"cloud.google.com/go/storage"
 )
 
 type MediaStore interface {
 	Upload(ctx context.Context, name, bucket string, src io.Reader) error
-	// TODO: need to come up with a better signature for this since these return vals are CGS specific
-	Download(ctx context.Context, name, bucket string) (*storage.ObjectAttrs, *storage.Reader, error)
+	Download(ctx context.Context, name, bucket string) (*ObjectInfo, io.ReadSeekCloser, error)
+	DownloadRange(ctx context.Context, name, bucket string, offset, length int64) (io.ReadCloser, error)
 	Delete(ctx context.Context, name, bucket string) error
+	// List returns every object in the bucket, and fails with
+	// ErrBucketNotExist if there is no such bucket. Only Name, Size and
+	// Created are filled in.
+	List(ctx context.Context, bucket string) ([]ObjectInfo, error)
+}
+
+// DirectStore is a MediaStore clients can upload to without the bytes going
+// through the server.
+type DirectStore interface {
+	// SignUpload returns a request that stores exactly one object of the
+	// given type and size under name, until expires. It only creates the
+	// object, it must fail once the object exists.
+	SignUpload(ctx context.Context, name, bucket, contentType string, size int64, expires time.Time) (*SignedRequest, error)
+	// Locate returns a path or URL ffmpeg can read the object from in place,
+	// good until expires.
+	Locate(ctx context.Context, name, bucket string, expires time.Time) (string, error)
+}
+
+// DownloadSigner is a MediaStore clients can download from without the bytes
+// going through the server.
+type DownloadSigner interface {
+	// SignDownload returns a URL that serves the object until expires, with
+	// contentType as its type unless that is empty.
+	SignDownload(ctx context.Context, name, bucket, contentType string, expires time.Time) (string, error)
+}
+
+// SignedRequest is an upload for the client to send as is.
+type SignedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+type ObjectInfo struct {
+	Name        string
+	Size        int64
+	ContentType string
+	Created     time.Time
+	// Checksum is the hex encoded MD5 of the object, empty if the backend does not record one.
+	Checksum string
+	ETag     string
 }
 
 type MetaStore interface {
+	// Save inserts the row. A file whose Object is its SHA256 also takes a
+	// reference on that blob, and if the blob already exists meta.Bucket is
+	// changed to the bucket holding it.
 	Save(ctx context.Context, meta *Metadata) error
+	// Get returns a file that is neither pending nor trashed, sql.ErrNoRows
+	// if there is none.
 	Get(ctx context.Context, fileId, userId string) (*Metadata, error)
-	GetAll(ctx context.Context, userId string) ([]Metadata, error)
-	Delete(ctx context.Context, fileId, userId string) error
+	// GetAll returns up to opts.Limit files matching opts.Filter in the order
+	// opts describes, starting after opts.After.
+	GetAll(ctx context.Context, userId string, opts ListOptions) ([]Metadata, error)
+	Count(ctx context.Context, userId string, filter Filter) (int64, error)
+	// UpdateCaption fails with sql.ErrNoRows unless the file is the user's and
+	// neither pending nor trashed.
+	UpdateCaption(ctx context.Context, fileId, userId, caption string) error
+	// Search returns the files matching a free text query, best match first,
+	// with snippets marked up with HighlightStart and HighlightEnd.
+	Search(ctx context.Context, userId, query string, limit, offset int) ([]SearchResult, error)
+	// Trash moves a file to the trash, which leaves it out of listings and
+	// search. It fails with sql.ErrNoRows if the file is not the user's, is
+	// still pending or is already trashed.
+	Trash(ctx context.Context, fileId, userId string, at time.Time) error
+	// Restore takes a file back out of the trash, sql.ErrNoRows if it is not
+	// the user's or not trashed. A trashed file gives up its name, so it
+	// fails with ErrFileExists if another file has taken it since.
+	Restore(ctx context.Context, fileId, userId string) error
+	// GetTrashed returns a file in the trash, sql.ErrNoRows if it is not the
+	// user's or not trashed.
+	GetTrashed(ctx context.Context, fileId, userId string) (*Metadata, error)
+	// GetTrash returns the user's trashed files, most recently trashed first.
+	GetTrash(ctx context.Context, userId string) ([]Metadata, error)
+	// GetExpiredTrash returns up to limit files of every user that were
+	// trashed before cutoff, oldest first.
+	GetExpiredTrash(ctx context.Context, cutoff time.Time, limit int) ([]Metadata, error)
+	// GetPending returns a file whose upload has not been committed yet,
+	// which Get leaves out, sql.ErrNoRows if there is none.
+	GetPending(ctx context.Context, fileId, userId string) (*Metadata, error)
+	// ReplacePending swaps the pending row with meta's id for meta, all at
+	// once, sql.ErrNoRows if there is no such pending row.
+	ReplacePending(ctx context.Context, meta *Metadata) error
+	// CommitUpload clears Pending once an upload has stored its objects and
+	// marks its blob as stored, sql.ErrNoRows if the row is gone or was not
+	// pending.
+	CommitUpload(ctx context.Context, fileId, userId string) error
+	// GetStaleUploads returns up to limit pending files of every user whose
+	// upload started before cutoff, oldest first.
+	GetStaleUploads(ctx context.Context, cutoff time.Time, limit int) ([]Metadata, error)
+	// GetBlob returns the blob with the given content, sql.ErrNoRows if no
+	// file references it.
+	GetBlob(ctx context.Context, sha256 string) (*Blob, error)
+	// Delete removes the row for good and drops its reference on its blob.
+	// last reports whether nothing else points at the objects any more, they
+	// are then the caller's to remove. A row that is already gone is not an
+	// error, but is never last.
+	Delete(ctx context.Context, fileId, userId string) (last bool, err error)
 }
 
+// Stores wrap matched terms in snippets with these private use characters,
+// which cannot collide with anything a user typed, and the service turns them
+// into HTML once the rest of the snippet is escaped.
+const (
+	HighlightStart = "\ue000"
+	HighlightEnd   = "\ue001"
+)
+
 type Metadata struct {
-	Id        string `json:"id"`
-	Filename  string `json:"filename"`
-	Thumbname string `json:"thumbname"`
-	UserId    string `json:"user_id"`
+	Id          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	Thumbname   string    `json:"thumbname"`
+	UserId      string    `json:"user_id"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	// Duration is the running time in seconds, zero for images.
+	Duration float64 `json:"duration"`
+	SHA256   string  `json:"sha256"`
+	Caption  string  `json:"caption"`
+	// TakenAt is when the media was captured according to its own metadata,
+	// nil if it did not say.
+	TakenAt *time.Time `json:"taken_at,omitempty"`
+	// DeletedAt is when the file was moved to the trash, nil outside it.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Pending is set from when an upload starts until both of its objects
+	// are stored. Users never see pending files.
+	Pending bool `json:"-"`
+	// Bucket holds the original and the thumbnail. Identical uploads share
+	// one copy, which stays in the bucket of whoever uploaded it first.
+	Bucket string `json:"-"`
+	// Object names the original in Bucket. It is the SHA256 for files
+	// stored by content, and the filename for files from before that.
+	// Direct uploads keep the name the client uploaded them under, their
+	// bytes never pass through the server to be hashed.
+	Object string `json:"-"`
+	// WrappedKey is only set on vault files, which the client encrypted
+	// before uploading. It is the file's key wrapped by the user's vault
+	// key, EncryptedMetadata is the real name, type and the like sealed by
+	// the client. Every other field describes the ciphertext.
+	WrappedKey        string `json:"wrapped_key,omitempty"`
+	EncryptedMetadata string `json:"encrypted_metadata,omitempty"`
+	// Exif is only loaded for a single file, listings leave it nil.
+	Exif *Exif `json:"exif,omitempty"`
+}
+
+type Exif struct {
+	CameraMake   string   `json:"camera_make,omitempty"`
+	CameraModel  string   `json:"camera_model,omitempty"`
+	LensModel    string   `json:"lens_model,omitempty"`
+	Orientation  int      `json:"orientation,omitempty"`
+	ExposureTime string   `json:"exposure_time,omitempty"`
+	FNumber      float64  `json:"f_number,omitempty"`
+	ISO          int      `json:"iso,omitempty"`
+	FocalLength  float64  `json:"focal_length,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+}
+
+type SortBy string
+
+const (
+	// SortByTaken orders by capture time, falling back to the upload time
+	// for files that do not record one.
+	SortByTaken    SortBy = "taken"
+	SortByUploaded SortBy = "uploaded"
+	SortByName     SortBy = "name"
+	SortBySize     SortBy = "size"
+)
+
+type ListOptions struct {
+	Filter    Filter
+	SortBy    SortBy
+	Ascending bool
+	Limit     int
+	// After is the position of the last file on the previous page, nil for
+	// the first page.
+	After *Position
+}
+
+// Filter narrows a listing. Zero fields match everything.
+type Filter struct {
+	// MediaType is "image" or "video".
+	MediaType string
+	// Names must all appear in the filename, ignoring case.
+	Names []string
+	// Camera must appear in the camera make or model, ignoring case.
+	Camera string
+	// Tags must all be on the file, in the form tag.Normalize returns.
+	Tags     []string
+	Taken    TimeRange
+	Uploaded TimeRange
+	Size     SizeRange
+}
+
+// TimeRange includes From and excludes To.
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// SizeRange includes both ends.
+type SizeRange struct {
+	Min *int64
+	Max *int64
+}
+
+// Position is where a file sits in a listing, only the field for the sort in
+// use is set. Id breaks ties between files with the same value.
+type Position struct {
+	Time time.Time
+	Name string
+	Size int64
+	Id   string
+}
+
+type ListRequest struct {
+	UserId string
+	// Query is a filter in the language ParseQuery accepts.
+	Query  string
+	SortBy SortBy
+	// Order is "asc" or "desc", empty for the natural order of the sort.
+	Order  string
+	Limit  int
+	Cursor string
+}
+
+type MetadataPage struct {
+	Files []Metadata `json:"files"`
+	// NextCursor is passed back to fetch the following page, empty on the
+	// last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+type SearchResult struct {
+	Metadata
+	// Snippet is an HTML fragment of the best matching text with matches
+	// wrapped in <mark>.
+	Snippet string `json:"snippet"`
+	// Rank orders results, higher is a better match.
+	Rank float64 `json:"rank"`
+}
+
+type SearchRequest struct {
+	UserId string
+	Query  string
+	Limit  int
+	Offset int
+}
+
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	// NextOffset fetches the following page, zero on the last page.
+	NextOffset int `json:"next_offset,omitempty"`
 }
 
 type UploadRequest struct {
@@ -36,37 +277,109 @@ type UploadRequest struct {
 	ContentType string
 	UserId      string
 	Bucket      string
+	// Vault is set for a file the client encrypted itself.
+	Vault *VaultUpload
+}
+
+// VaultUpload is what comes with a file encrypted by the client. The server
+// cannot see into the file, so the thumbnail is the client's to render and
+// encrypt as well.
+type VaultUpload struct {
+	Thumbnail         io.Reader
+	WrappedKey        string
+	EncryptedMetadata string
 }
 
 type UploadResult struct {
+	// Index is the position of the request among those sent to Upload.
+	Index    int
 	Filename string
+	// Id is the new file's id and Metadata its row, empty if the upload
+	// failed.
+	Id       string
+	Metadata *Metadata
 	Err      error
 }
 
+type DirectUploadRequest struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	UserId      string
+	Bucket      string
+}
+
+// DirectUpload is a file the client uploads straight to storage and then
+// finalizes, which is when it is probed, thumbnailed and listed.
+type DirectUpload struct {
+	Id        string        `json:"id"`
+	Upload    SignedRequest `json:"upload"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+// SignedURL downloads a file without a token, until it expires.
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type DownloadRequest struct {
-	FileId string
-	UserId string
-	Bucket string
+	FileId    string
+	UserId    string
+	Thumbnail bool
 }
 
 type DownloadResult struct {
-	Reader      io.ReadCloser
+	Reader      io.ReadSeekCloser
 	ContentType string
 	Size        int64
 	Timestamp   time.Time
+	ETag        string
+
+	// object and bucket are where the file was found, for DownloadRange.
+	object string
+	bucket string
 }
 
 type DeleteRequest struct {
 	FileId string
 	UserId string
+}
+
+// Blob is one stored copy of some content along with how many files use it.
+type Blob struct {
+	SHA256 string
 	Bucket string
+	Refs   int
+	// Stored is set once an upload has written the objects. Until then a
+	// second upload of the same content writes them too rather than trust
+	// an upload that may still fail.
+	Stored bool
 }
 
 var (
 	ErrFileExists          = errors.New("file already exists")
 	ErrOrphanedFile        = errors.New("CRITICAL - orphaned file")
 	ErrMediaNotExist       = errors.New("file not found in storage")
+	ErrBucketNotExist      = errors.New("bucket not found in storage")
 	ErrMediaCorrupted      = errors.New("one or more parts of the file are missing/corrupted")
 	ErrUserUnauthorzied    = errors.New("user ")
 	ErrUnsupportedFileType = errors.New("unsupported file type")
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrInvalidSort         = errors.New("invalid sort")
+	ErrInvalidQuery        = errors.New("invalid query")
+	ErrInvalidCaption      = errors.New("invalid caption")
+	ErrSearchUnavailable   = errors.New("search is not available")
+	ErrNotTrashed          = errors.New("file is not in the trash")
+	ErrInvalidVaultUpload  = errors.New("invalid vault upload")
+	// ErrInvalidMedia is returned for an upload that cannot be read as the
+	// image or video it claims to be.
+	ErrInvalidMedia        = errors.New("invalid media")
+	ErrInvalidDirectUpload = errors.New("invalid direct upload")
+	// ErrDirectUploadUnavailable is returned when the storage backend cannot
+	// take uploads from clients.
+	ErrDirectUploadUnavailable = errors.New("direct uploads are not available")
+	ErrSignedURLUnavailable    = errors.New("signed urls are not available")
+	ErrInvalidSignature        = errors.New("invalid or expired signature")
 )
@@ -1,14 +1,21 @@
 package fs
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/portbound/go-fs/internal/auth"
 	"github.com/portbound/go-fs/internal/platform/http/response"
@@ -16,6 +23,10 @@ import (
 	"github.com/portbound/portlog"
 )
 
+// maxVaultThumbnailSize bounds the thumbnail of a vault upload, which is held
+// in memory while the file streams.
+const maxVaultThumbnailSize = 1 << 20
+
 type Handler struct {
 	service *Service
 	logger  *portlog.PortLog
@@ -27,9 +38,21 @@ func NewHandler(s *Service, l *portlog.PortLog) *Handler {
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /files", h.handleUploadFile)
+	mux.HandleFunc("POST /vault/files", h.handleUploadVaultFile)
+	mux.HandleFunc("POST /files/direct", h.handleCreateDirectUpload)
+	mux.HandleFunc("POST /files/{id}/finalize", h.handleFinalizeDirectUpload)
 	mux.HandleFunc("GET /files", h.handleGetMetadata)
 	mux.HandleFunc("GET /files/{id}", h.handleDownloadFile)
+	mux.HandleFunc("GET /files/{id}/thumbnail", h.handleDownloadThumbnail)
+	mux.HandleFunc("GET /files/{id}/url", h.handleSignDownload)
+	mux.HandleFunc("GET /files/{id}/thumbnail/url", h.handleSignThumbnail)
+	mux.HandleFunc("GET /files/{id}/metadata", h.handleGetFileMetadata)
+	mux.HandleFunc("PATCH /files/{id}", h.handleUpdateFile)
+	mux.HandleFunc("GET /search", h.handleSearch)
 	mux.HandleFunc("DELETE /files/{id}", h.handleDeleteFile)
+	mux.HandleFunc("POST /files/{id}/restore", h.handleRestoreFile)
+	mux.HandleFunc("GET /trash", h.handleGetTrash)
+	mux.HandleFunc("DELETE /trash/{id}", h.handlePurgeFile)
 }
 
 func (h *Handler) handleUploadFile(w http.ResponseWriter, r *http.Request) {
@@ -44,45 +67,276 @@ func (h *Handler) handleUploadFile(w http.ResponseWriter, r *http.Request) {
 	requests := make(chan UploadRequest)
 	results := h.service.Upload(r.Context(), requests)
 
-	defer close(requests)
+	// Files are processed while later parts are still coming in, so results
+	// are collected on the side, in the order of the parts.
+	var files []uploadFileResult
+	failed := false
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for result := range results {
+			if result.Index >= len(files) {
+				files = append(files, make([]uploadFileResult, result.Index+1-len(files))...)
+			}
+			files[result.Index] = h.uploadFileResult(result, requester.Id)
+			failed = failed || result.Err != nil
+		}
+	}()
 
-	var resultErrs error
+	var parseErr error
 	for {
 		part, err := reader.NextPart()
 		if err != nil {
-			if err == io.EOF {
-				close(requests)
-				break
+			if err != io.EOF {
+				parseErr = err
 			}
-			msg := "failed to parse incoming multipart request"
-			h.logger.Error(msg, err)
-			response.Error(w, http.StatusInternalServerError, errors.New(msg))
-			return
+			break
 		}
 
+		// The next part can only be read once Upload is done with this one.
+		read := make(chan struct{})
 		requests <- UploadRequest{
 			Filename:    filepath.Base(part.FileName()),
 			ContentType: part.Header.Get("Content-Type"),
-			Reader:      part,
+			Reader:      &notifyCloser{ReadCloser: part, closed: read},
 			UserId:      requester.Id,
 			Bucket:      requester.Bucket,
 		}
+		<-read
+	}
+	close(requests)
+	<-collected
+
+	if parseErr != nil {
+		msg := "failed to parse incoming multipart request"
+		h.logger.Error(msg, parseErr)
+		response.Error(w, http.StatusInternalServerError, errors.New(msg))
+		return
+	}
+
+	if files == nil {
+		files = []uploadFileResult{}
+	}
+
+	if failed {
+		response.JSON(w, http.StatusMultiStatus, files)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, files)
+}
+
+// uploadFileResult is what became of one part of an upload.
+type uploadFileResult struct {
+	Filename string       `json:"filename"`
+	Status   int          `json:"status"`
+	Id       string       `json:"id,omitempty"`
+	Metadata *Metadata    `json:"metadata,omitempty"`
+	Error    *uploadError `json:"error,omitempty"`
+}
+
+type uploadError struct {
+	// Code is one of file_exists, unsupported_file_type, invalid_media,
+	// invalid_vault_upload, cancelled and internal.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (h *Handler) uploadFileResult(result UploadResult, userId string) uploadFileResult {
+	file := uploadFileResult{Filename: result.Filename, Status: http.StatusCreated, Id: result.Id, Metadata: result.Metadata}
+	if result.Err == nil {
+		return file
+	}
+
+	var code string
+	switch err := result.Err; {
+	case errors.Is(err, ErrFileExists):
+		file.Status, code = http.StatusConflict, "file_exists"
+	case errors.Is(err, ErrUnsupportedFileType):
+		file.Status, code = http.StatusUnsupportedMediaType, "unsupported_file_type"
+	case errors.Is(err, ErrInvalidMedia):
+		file.Status, code = http.StatusUnprocessableEntity, "invalid_media"
+	case errors.Is(err, ErrInvalidVaultUpload):
+		file.Status, code = http.StatusBadRequest, "invalid_vault_upload"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		file.Status, code = http.StatusServiceUnavailable, "cancelled"
+	default:
+		h.logger.Error("failed to upload file", err, "filename", result.Filename, "userId", userId)
+		file.Status = http.StatusInternalServerError
+		file.Error = &uploadError{Code: "internal", Message: "failed to upload file"}
+		return file
+	}
+
+	file.Error = &uploadError{Code: code, Message: result.Err.Error()}
+	return file
+}
+
+// notifyCloser closes closed along with the reader.
+type notifyCloser struct {
+	io.ReadCloser
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (n *notifyCloser) Close() error {
+	err := n.ReadCloser.Close()
+	n.once.Do(func() { close(n.closed) })
+	return err
+}
+
+func (h *Handler) handleUploadVaultFile(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	var vault VaultUpload
+	var file *multipart.Part
+	for file == nil {
+		part, err := reader.NextPart()
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("expected metadata, thumbnail and file parts"))
+			return
+		}
 
-		result := <-results
-		if result.Err != nil {
-			resultErrs = errors.Join(resultErrs, result.Err)
+		switch part.FormName() {
+		case "metadata":
+			var body struct {
+				WrappedKey        string `json:"wrapped_key"`
+				EncryptedMetadata string `json:"encrypted_metadata"`
+			}
+			if err := json.NewDecoder(io.LimitReader(part, 128<<10)).Decode(&body); err != nil {
+				response.Error(w, http.StatusBadRequest, errors.New("invalid metadata part"))
+				return
+			}
+			vault.WrappedKey, vault.EncryptedMetadata = body.WrappedKey, body.EncryptedMetadata
+		case "thumbnail":
+			thumbnail, err := io.ReadAll(io.LimitReader(part, maxVaultThumbnailSize+1))
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, errors.New("invalid thumbnail part"))
+				return
+			}
+			if len(thumbnail) > maxVaultThumbnailSize {
+				response.Error(w, http.StatusRequestEntityTooLarge, fmt.Errorf("thumbnail is larger than %d bytes", maxVaultThumbnailSize))
+				return
+			}
+			vault.Thumbnail = bytes.NewReader(thumbnail)
+		case "file":
+			file = part
 		}
 	}
 
-	if resultErrs != nil {
-		response.JSON(w, http.StatusMultiStatus, resultErrs.Error())
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	requests := make(chan UploadRequest, 1)
+	requests <- UploadRequest{
+		Reader: file,
+		UserId: requester.Id,
+		Bucket: requester.Bucket,
+		Vault:  &vault,
+	}
+	close(requests)
+
+	result := <-h.service.Upload(r.Context(), requests)
+	if result.Err != nil {
+		if errors.Is(result.Err, ErrInvalidVaultUpload) {
+			response.Error(w, http.StatusBadRequest, result.Err)
+			return
+		}
+
+		h.logger.Error("failed to upload vault file", result.Err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, errors.New("failed to upload vault file"))
 		return
 	}
 
-	response.JSON(w, http.StatusCreated, nil)
+	response.JSON(w, http.StatusCreated, map[string]string{"id": result.Id})
+}
+
+func (h *Handler) handleCreateDirectUpload(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	upload, err := h.service.CreateDirectUpload(r.Context(), DirectUploadRequest{
+		Filename:    body.Filename,
+		ContentType: body.ContentType,
+		Size:        body.Size,
+		UserId:      requester.Id,
+		Bucket:      requester.Bucket,
+	})
+	if err != nil {
+		h.directUploadError(w, err, "failed to create direct upload", requester.Id)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, upload)
+}
+
+func (h *Handler) handleFinalizeDirectUpload(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PathValue("id")
+	if fileId == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	metadata, err := h.service.FinalizeDirectUpload(r.Context(), fileId, requester.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("no upload waiting to be finalized for id: %q", fileId))
+			return
+		}
+
+		h.directUploadError(w, err, "failed to finalize direct upload", requester.Id)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, metadata)
+}
+
+func (h *Handler) directUploadError(w http.ResponseWriter, err error, msg, userId string) {
+	switch {
+	case errors.Is(err, ErrDirectUploadUnavailable):
+		response.Error(w, http.StatusNotImplemented, err)
+	case errors.Is(err, ErrInvalidDirectUpload):
+		response.Error(w, http.StatusBadRequest, err)
+	case errors.Is(err, ErrFileExists):
+		response.Error(w, http.StatusConflict, err)
+	case errors.Is(err, ErrUnsupportedFileType):
+		response.Error(w, http.StatusUnsupportedMediaType, err)
+	default:
+		h.logger.Error(msg, err, "userId", userId)
+		response.Error(w, http.StatusInternalServerError, errors.New(msg))
+	}
 }
 
 func (h *Handler) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	h.download(w, r, requester.Id, false)
+}
+
+func (h *Handler) handleDownloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	h.download(w, r, requester.Id, true)
+}
+
+func (h *Handler) handleSignDownload(w http.ResponseWriter, r *http.Request) {
+	h.signDownload(w, r, false)
+}
+
+func (h *Handler) handleSignThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.signDownload(w, r, true)
+}
+
+func (h *Handler) signDownload(w http.ResponseWriter, r *http.Request, thumbnail bool) {
 	fileId := r.PathValue("id")
 	if fileId == "" {
 		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
@@ -90,16 +344,78 @@ func (h *Handler) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	signed, err := h.service.SignDownload(r.Context(), DownloadRequest{FileId: fileId, UserId: requester.Id, Thumbnail: thumbnail})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found for id: %q", fileId))
+			return
+		}
+
+		if errors.Is(err, ErrSignedURLUnavailable) {
+			response.Error(w, http.StatusNotImplemented, err)
+			return
+		}
+
+		h.logger.Error("failed to sign download", err, "fileId", fileId, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to sign download of file %q", fileId))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, http.StatusOK, signed)
+}
+
+// RegisterSignedRoutes serves the URLs SignDownload signs when storage does
+// not serve them itself. The routes have to be reachable without logging in,
+// the signature is what allows a download.
+func (h *Handler) RegisterSignedRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+signedPath+"{user}/{id}", h.handleSignedDownloadFile)
+	mux.HandleFunc("GET "+signedPath+"{user}/{id}/thumbnail", h.handleSignedDownloadThumbnail)
+}
+
+func (h *Handler) handleSignedDownloadFile(w http.ResponseWriter, r *http.Request) {
+	h.signedDownload(w, r, false)
+}
+
+func (h *Handler) handleSignedDownloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.signedDownload(w, r, true)
+}
+
+func (h *Handler) signedDownload(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	userId := r.PathValue("user")
+	request := DownloadRequest{FileId: r.PathValue("id"), UserId: userId, Thumbnail: thumbnail}
+
+	query := r.URL.Query()
+	expires, err := h.service.VerifyDownload(request, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		response.Error(w, http.StatusForbidden, err)
+		return
+	}
+
+	// The URL is only good until it expires, and only for whoever was
+	// handed it.
+	maxAge := int(time.Until(expires).Seconds())
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	h.download(w, r, userId, thumbnail)
+}
+
+func (h *Handler) download(w http.ResponseWriter, r *http.Request, userId string, thumbnail bool) {
+	fileId := r.PathValue("id")
+	if fileId == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
+		return
+	}
+
 	request := DownloadRequest{
-		FileId: fileId,
-		UserId: requester.Id,
-		Bucket: requester.Bucket,
+		FileId:    fileId,
+		UserId:    userId,
+		Thumbnail: thumbnail,
 	}
 
 	result, err := h.service.Download(r.Context(), request)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			h.logger.Error("file not found during download", err, "fileId", fileId, "userId", requester.Id)
+			h.logger.Error("file not found during download", err, "fileId", fileId, "userId", userId)
 			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found for id: %q", fileId))
 			return
 		}
@@ -116,26 +432,224 @@ func (h *Handler) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
 	}
 	defer result.Reader.Close()
 
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Last-Modified", result.Timestamp.UTC().Format(http.TimeFormat))
+	if result.ETag != "" {
+		w.Header().Set("ETag", result.ETag)
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != result.ETag && ifRange != w.Header().Get("Last-Modified") {
+		rangeHeader = ""
+	}
+
+	ranges, err := parseRange(rangeHeader, result.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", result.Size))
+		response.Error(w, http.StatusRequestedRangeNotSatisfiable, err)
+		return
+	}
+
+	switch len(ranges) {
+	case 0:
+		w.Header().Set("Content-Type", result.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(result.Size, 10))
+		w.WriteHeader(http.StatusOK)
+
+		if _, err := io.Copy(w, result.Reader); err != nil {
+			h.logger.Error("failed to stream file to client", err, "fileId", fileId)
+		}
+	case 1:
+		h.serveRange(w, r, request, result, ranges[0])
+	default:
+		h.serveMultiRange(w, r, request, result, ranges)
+	}
+}
+
+func (h *Handler) serveRange(w http.ResponseWriter, r *http.Request, request DownloadRequest, result *DownloadResult, br ByteRange) {
+	reader, err := h.service.DownloadRange(r.Context(), result, br)
+	if err != nil {
+		h.logger.Error("failed to download file range", err, "fileId", request.FileId)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to download file %q", request.FileId))
+		return
+	}
+	defer reader.Close()
+
 	w.Header().Set("Content-Type", result.ContentType)
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Range", br.contentRange(result.Size))
+	w.Header().Set("Content-Length", strconv.FormatInt(br.Length, 10))
+	w.WriteHeader(http.StatusPartialContent)
 
-	if _, err := io.Copy(w, result.Reader); err != nil {
-		h.logger.Error("failed to stream file to client", err, "fileId", fileId)
+	if _, err := io.Copy(w, reader); err != nil {
+		h.logger.Error("failed to stream file range to client", err, "fileId", request.FileId)
+	}
+}
+
+func (h *Handler) serveMultiRange(w http.ResponseWriter, r *http.Request, request DownloadRequest, result *DownloadResult, ranges []ByteRange) {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+
+	for _, br := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {result.ContentType},
+			"Content-Range": {br.contentRange(result.Size)},
+		})
+		if err != nil {
+			h.logger.Error("failed to write multipart range header", err, "fileId", request.FileId)
+			return
+		}
+
+		reader, err := h.service.DownloadRange(r.Context(), result, br)
+		if err != nil {
+			h.logger.Error("failed to download file range", err, "fileId", request.FileId)
+			return
+		}
+
+		_, err = io.Copy(part, reader)
+		reader.Close()
+		if err != nil {
+			h.logger.Error("failed to stream file range to client", err, "fileId", request.FileId)
+			return
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		h.logger.Error("failed to finish multipart range response", err, "fileId", request.FileId)
 	}
 }
 
 func (h *Handler) handleGetMetadata(w http.ResponseWriter, r *http.Request) {
 	requester := r.Context().Value(auth.RequesterKey).(*user.User)
-	metadata, err := h.service.GetMetadata(r.Context(), requester.Id)
+	request := ListRequest{
+		UserId: requester.Id,
+		Query:  r.URL.Query().Get("q"),
+		SortBy: SortBy(r.URL.Query().Get("sort")),
+		Order:  r.URL.Query().Get("order"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			response.Error(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", limit))
+			return
+		}
+		request.Limit = n
+	}
+
+	page, err := h.service.GetMetadata(r.Context(), request)
 	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) || errors.Is(err, ErrInvalidSort) || errors.Is(err, ErrInvalidQuery) {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
 		h.logger.Error("failed to retrieve metadata", err, "userId", requester.Id)
 		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to fetch metadata for user %q", requester.Id))
 		return
 	}
 
+	response.JSON(w, http.StatusOK, page)
+}
+
+func (h *Handler) handleGetFileMetadata(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PathValue("id")
+	if fileId == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	metadata, err := h.service.GetFileMetadata(r.Context(), fileId, requester.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found for id: %q", fileId))
+			return
+		}
+
+		h.logger.Error("failed to retrieve file metadata", err, "fileId", fileId, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to fetch metadata for file %q", fileId))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, metadata)
+}
+
+func (h *Handler) handleUpdateFile(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PathValue("id")
+	if fileId == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
+		return
+	}
+
+	var body struct {
+		Caption *string `json:"caption"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	if body.Caption == nil {
+		response.Error(w, http.StatusBadRequest, errors.New("nothing to update"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	metadata, err := h.service.UpdateCaption(r.Context(), fileId, requester.Id, *body.Caption)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found for id: %q", fileId))
+			return
+		}
+
+		if errors.Is(err, ErrInvalidCaption) {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		h.logger.Error("failed to update file", err, "fileId", fileId, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to update file %q", fileId))
+		return
+	}
+
 	response.JSON(w, http.StatusOK, metadata)
 }
 
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	request := SearchRequest{
+		UserId: requester.Id,
+		Query:  r.URL.Query().Get("q"),
+	}
+
+	for name, dst := range map[string]*int{"limit": &request.Limit, "offset": &request.Offset} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				response.Error(w, http.StatusBadRequest, fmt.Errorf("invalid %s %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+
+	page, err := h.service.Search(r.Context(), request)
+	if err != nil {
+		if errors.Is(err, ErrSearchUnavailable) {
+			response.Error(w, http.StatusNotImplemented, err)
+			return
+		}
+
+		h.logger.Error("failed to search", err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, errors.New("search failed"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, page)
+}
+
 func (h *Handler) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 	fileId := r.PathValue("id")
 	if fileId == "" {
@@ -147,12 +661,84 @@ func (h *Handler) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 	request := DeleteRequest{
 		FileId: fileId,
 		UserId: requester.Id,
-		Bucket: requester.Bucket,
 	}
 
 	if err := h.service.Delete(r.Context(), request); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found for id: %q", fileId))
+			return
+		}
+
 		h.logger.Error("failed to delete file", err, "fileId", fileId, "userId", requester.Id)
 		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to delete file %q", request.FileId))
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleRestoreFile(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PathValue("id")
+	if fileId == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	metadata, err := h.service.Restore(r.Context(), fileId, requester.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found in trash for id: %q", fileId))
+			return
+		}
+		if errors.Is(err, ErrFileExists) {
+			response.Error(w, http.StatusConflict, err)
+			return
+		}
+
+		h.logger.Error("failed to restore file", err, "fileId", fileId, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to restore file %q", fileId))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, metadata)
+}
+
+func (h *Handler) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	files, err := h.service.GetTrash(r.Context(), requester.Id)
+	if err != nil {
+		h.logger.Error("failed to retrieve trash", err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to fetch trash for user %q", requester.Id))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, files)
+}
+
+func (h *Handler) handlePurgeFile(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PathValue("id")
+	if fileId == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("file id missing from request"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	request := DeleteRequest{
+		FileId: fileId,
+		UserId: requester.Id,
+	}
+
+	if err := h.service.Purge(r.Context(), request); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrNotTrashed) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found in trash for id: %q", fileId))
+			return
+		}
+
+		h.logger.Error("failed to purge file", err, "fileId", fileId, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to purge file %q", fileId))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -1,65 +1,399 @@
 package fs
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"database/sql"
 	"io"
-
-	"cloud.google.com/go/storage"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type MockMediaStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	created map[string]time.Time
 }
 
 func NewMockMediaStore() *MockMediaStore {
-	return &MockMediaStore{}
+	return &MockMediaStore{objects: make(map[string][]byte), created: make(map[string]time.Time)}
 }
 
 func (m *MockMediaStore) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[bucket+"/"+name] = data
+	m.created[bucket+"/"+name] = time.Now()
 	return nil
 }
 
-func (m *MockMediaStore) Download(ctx context.Context, name, bucket string) (*storage.ObjectAttrs, *storage.Reader, error) {
-	return nil, nil, nil
+func (m *MockMediaStore) Download(ctx context.Context, name, bucket string) (*ObjectInfo, io.ReadSeekCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[bucket+"/"+name]
+	if !ok {
+		return nil, nil, ErrMediaNotExist
+	}
+
+	info := &ObjectInfo{
+		Size:        int64(len(data)),
+		ContentType: http.DetectContentType(data),
+		Created:     time.Now(),
+	}
+
+	return info, nopReadSeekCloser{bytes.NewReader(data)}, nil
+}
+
+func (m *MockMediaStore) DownloadRange(ctx context.Context, name, bucket string, offset, length int64) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[bucket+"/"+name]
+	if !ok {
+		return nil, ErrMediaNotExist
+	}
+
+	end := min(offset+length, int64(len(data)))
+	return io.NopCloser(bytes.NewReader(data[offset:end])), nil
 }
 
 func (m *MockMediaStore) Delete(ctx context.Context, name, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[bucket+"/"+name]; !ok {
+		return ErrMediaNotExist
+	}
+	delete(m.objects, bucket+"/"+name)
+	delete(m.created, bucket+"/"+name)
+	return nil
+}
+
+func (m *MockMediaStore) List(ctx context.Context, bucket string) ([]ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []ObjectInfo
+	for key, data := range m.objects {
+		if name, ok := strings.CutPrefix(key, bucket+"/"); ok {
+			objects = append(objects, ObjectInfo{Name: name, Size: int64(len(data)), Created: m.created[key]})
+		}
+	}
+	return objects, nil
+}
+
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error {
 	return nil
 }
 
 type MockMetaStore struct {
 	store map[string]*Metadata
+	blobs map[string]*Blob
 }
 
 func NewMockMetaStore() *MockMetaStore {
 	return &MockMetaStore{
 		store: make(map[string]*Metadata),
+		blobs: make(map[string]*Blob),
 	}
 }
 
+// Save puts files without a bucket in "test_bucket" and, unless told
+// otherwise, keeps their original under the filename like files from before
+// blobs.
 func (m *MockMetaStore) Save(ctx context.Context, meta *Metadata) error {
+	if meta.Bucket == "" {
+		meta.Bucket = "test_bucket"
+	}
+	if meta.Object == "" {
+		meta.Object = meta.Filename
+	}
+	if meta.SHA256 != "" && meta.Object == meta.SHA256 {
+		blob, ok := m.blobs[meta.SHA256]
+		if !ok {
+			blob = &Blob{SHA256: meta.SHA256, Bucket: meta.Bucket}
+			m.blobs[meta.SHA256] = blob
+		}
+		blob.Refs++
+		meta.Bucket = blob.Bucket
+	}
 	m.store[meta.Id] = meta
 	return nil
 }
 
 func (m *MockMetaStore) Get(ctx context.Context, fileId, userId string) (*Metadata, error) {
 	meta, ok := m.store[fileId]
-	if !ok {
+	if !ok || meta.Pending || meta.DeletedAt != nil {
 		return nil, ErrMediaNotExist
 	}
 	return meta, nil
 }
 
-func (m *MockMetaStore) GetAll(ctx context.Context, userId string) ([]Metadata, error) {
+func (m *MockMetaStore) GetAll(ctx context.Context, userId string, opts ListOptions) ([]Metadata, error) {
 	var all []Metadata
 	for _, meta := range m.store {
-		if meta.UserId == userId {
+		if meta.UserId == userId && matchesFilter(opts.Filter, *meta) {
 			all = append(all, *meta)
 		}
 	}
-	return all, nil
+
+	// before reports whether a is listed before b.
+	before := func(a, b Position) bool {
+		c := a.Time.Compare(b.Time)
+		if c == 0 {
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Size, b.Size)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Id, b.Id)
+		}
+		if opts.Ascending {
+			return c < 0
+		}
+		return c > 0
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return before(positionOf(opts.SortBy, all[i]), positionOf(opts.SortBy, all[j]))
+	})
+
+	var page []Metadata
+	for _, meta := range all {
+		if opts.After != nil && !before(*opts.After, positionOf(opts.SortBy, meta)) {
+			continue
+		}
+		if len(page) == opts.Limit {
+			break
+		}
+		page = append(page, meta)
+	}
+	return page, nil
 }
 
-func (m *MockMetaStore) Delete(ctx context.Context, fileId, userId string) error {
-	delete(m.store, fileId)
+func (m *MockMetaStore) Count(ctx context.Context, userId string, filter Filter) (int64, error) {
+	var n int64
+	for _, meta := range m.store {
+		if meta.UserId == userId && matchesFilter(filter, *meta) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MockMetaStore) UpdateCaption(ctx context.Context, fileId, userId, caption string) error {
+	meta, ok := m.store[fileId]
+	if !ok || meta.UserId != userId || meta.DeletedAt != nil || meta.Pending {
+		return sql.ErrNoRows
+	}
+	meta.Caption = caption
+	return nil
+}
+
+// Search matches each word of the query against the filename and caption and
+// highlights the first word in the caption.
+func (m *MockMetaStore) Search(ctx context.Context, userId, query string, limit, offset int) ([]SearchResult, error) {
+	words := strings.Fields(strings.ToLower(query))
+	var results []SearchResult
+	for _, meta := range m.store {
+		text := strings.ToLower(meta.Filename + " " + meta.Caption)
+		matched := meta.UserId == userId && meta.DeletedAt == nil && !meta.Pending && len(words) > 0
+		for _, w := range words {
+			matched = matched && strings.Contains(text, w)
+		}
+		if !matched {
+			continue
+		}
+
+		snippet := meta.Caption
+		if i := strings.Index(strings.ToLower(snippet), words[0]); i >= 0 {
+			snippet = snippet[:i] + HighlightStart + snippet[i:i+len(words[0])] + HighlightEnd + snippet[i+len(words[0]):]
+		}
+		results = append(results, SearchResult{Metadata: *meta, Snippet: snippet, Rank: float64(len(words))})
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Id < results[j].Id })
+	if offset >= len(results) {
+		return nil, nil
+	}
+	return results[offset:min(offset+limit, len(results))], nil
+}
+
+func matchesFilter(f Filter, m Metadata) bool {
+	if m.DeletedAt != nil || m.Pending {
+		return false
+	}
+	if f.MediaType != "" && !strings.HasPrefix(m.ContentType, f.MediaType+"/") {
+		return false
+	}
+	for _, name := range f.Names {
+		if !strings.Contains(strings.ToLower(m.Filename), strings.ToLower(name)) {
+			return false
+		}
+	}
+	if f.Camera != "" && (m.Exif == nil || !strings.Contains(strings.ToLower(m.Exif.CameraMake+" "+m.Exif.CameraModel), strings.ToLower(f.Camera))) {
+		return false
+	}
+	// Mock files carry no tags.
+	if len(f.Tags) > 0 {
+		return false
+	}
+	inRange := func(r TimeRange, t time.Time) bool {
+		return (r.From == nil || !t.Before(*r.From)) && (r.To == nil || t.Before(*r.To))
+	}
+	if !inRange(f.Taken, positionOf(SortByTaken, m).Time) || !inRange(f.Uploaded, m.UploadedAt) {
+		return false
+	}
+	if (f.Size.Min != nil && m.Size < *f.Size.Min) || (f.Size.Max != nil && m.Size > *f.Size.Max) {
+		return false
+	}
+	return true
+}
+
+func (m *MockMetaStore) Trash(ctx context.Context, fileId, userId string, at time.Time) error {
+	meta, ok := m.store[fileId]
+	if !ok || meta.UserId != userId || meta.DeletedAt != nil || meta.Pending {
+		return sql.ErrNoRows
+	}
+	meta.DeletedAt = &at
+	return nil
+}
+
+func (m *MockMetaStore) Restore(ctx context.Context, fileId, userId string) error {
+	meta, ok := m.store[fileId]
+	if !ok || meta.UserId != userId || meta.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	meta.DeletedAt = nil
+	return nil
+}
+
+func (m *MockMetaStore) GetTrashed(ctx context.Context, fileId, userId string) (*Metadata, error) {
+	meta, ok := m.store[fileId]
+	if !ok || meta.UserId != userId || meta.DeletedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+	return meta, nil
+}
+
+func (m *MockMetaStore) GetTrash(ctx context.Context, userId string) ([]Metadata, error) {
+	var trash []Metadata
+	for _, meta := range m.store {
+		if meta.UserId == userId && meta.DeletedAt != nil && !meta.Pending {
+			trash = append(trash, *meta)
+		}
+	}
+	sort.Slice(trash, func(i, j int) bool { return trash[i].DeletedAt.After(*trash[j].DeletedAt) })
+	return trash, nil
+}
+
+func (m *MockMetaStore) GetExpiredTrash(ctx context.Context, cutoff time.Time, limit int) ([]Metadata, error) {
+	var expired []Metadata
+	for _, meta := range m.store {
+		if meta.DeletedAt != nil && meta.DeletedAt.Before(cutoff) {
+			expired = append(expired, *meta)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].DeletedAt.Before(*expired[j].DeletedAt) })
+	return expired[:min(limit, len(expired))], nil
+}
+
+func (m *MockMetaStore) GetPending(ctx context.Context, fileId, userId string) (*Metadata, error) {
+	meta, ok := m.store[fileId]
+	if !ok || meta.UserId != userId || !meta.Pending {
+		return nil, sql.ErrNoRows
+	}
+	return meta, nil
+}
+
+func (m *MockMetaStore) ReplacePending(ctx context.Context, meta *Metadata) error {
+	pending, ok := m.store[meta.Id]
+	if !ok || pending.UserId != meta.UserId || !pending.Pending {
+		return sql.ErrNoRows
+	}
+	return m.Save(ctx, meta)
+}
+
+func (m *MockMetaStore) CommitUpload(ctx context.Context, fileId, userId string) error {
+	meta, ok := m.store[fileId]
+	if !ok || meta.UserId != userId || !meta.Pending {
+		return sql.ErrNoRows
+	}
+	meta.Pending = false
+	if blob, ok := m.blobs[meta.Object]; ok {
+		blob.Stored = true
+	}
 	return nil
 }
+
+func (m *MockMetaStore) GetStaleUploads(ctx context.Context, cutoff time.Time, limit int) ([]Metadata, error) {
+	var stale []Metadata
+	for _, meta := range m.store {
+		if meta.Pending && meta.UploadedAt.Before(cutoff) {
+			stale = append(stale, *meta)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].UploadedAt.Before(stale[j].UploadedAt) })
+	return stale[:min(limit, len(stale))], nil
+}
+
+func (m *MockMetaStore) GetBlob(ctx context.Context, sha256 string) (*Blob, error) {
+	blob, ok := m.blobs[sha256]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	b := *blob
+	return &b, nil
+}
+
+// GetBuckets returns every bucket holding a file, each owned by one of the
+// users with a file in it.
+func (m *MockMetaStore) GetBuckets(ctx context.Context) ([]UserBucket, error) {
+	var buckets []UserBucket
+	for _, meta := range m.store {
+		if !slices.ContainsFunc(buckets, func(b UserBucket) bool { return b.Bucket == meta.Bucket }) {
+			buckets = append(buckets, UserBucket{UserId: meta.UserId, Bucket: meta.Bucket})
+		}
+	}
+	return buckets, nil
+}
+
+func (m *MockMetaStore) GetInventory(ctx context.Context, bucket string) ([]Metadata, error) {
+	var files []Metadata
+	for _, meta := range m.store {
+		if meta.Bucket == bucket {
+			files = append(files, *meta)
+		}
+	}
+	return files, nil
+}
+
+func (m *MockMetaStore) Delete(ctx context.Context, fileId, userId string) (bool, error) {
+	meta, ok := m.store[fileId]
+	if !ok {
+		return false, nil
+	}
+	delete(m.store, fileId)
+
+	blob, ok := m.blobs[meta.Object]
+	if !ok || meta.SHA256 == "" || meta.Object != meta.SHA256 {
+		return true, nil
+	}
+	if blob.Refs--; blob.Refs > 0 {
+		return false, nil
+	}
+	delete(m.blobs, meta.Object)
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package fs
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// UploadLimits bounds how much uploading happens at once. Workers is how many
+// files are processed at a time, and so how many sit staged on disk; FFmpeg,
+// Storage and Database bound the ffmpeg and ffprobe runs, bucket writes and
+// database writes shared between them.
+type UploadLimits struct {
+	Workers  int
+	FFmpeg   int
+	Storage  int
+	Database int
+}
+
+var DefaultUploadLimits = UploadLimits{Workers: 4, FFmpeg: 2, Storage: 4, Database: 2}
+
+type limits struct {
+	workers  *semaphore.Weighted
+	ffmpeg   *semaphore.Weighted
+	storage  *semaphore.Weighted
+	database *semaphore.Weighted
+}
+
+func newLimits(l UploadLimits) limits {
+	return limits{
+		workers:  semaphore.NewWeighted(int64(max(l.Workers, 1))),
+		ffmpeg:   semaphore.NewWeighted(int64(max(l.FFmpeg, 1))),
+		storage:  semaphore.NewWeighted(int64(max(l.Storage, 1))),
+		database: semaphore.NewWeighted(int64(max(l.Database, 1))),
+	}
+}
+
+// SetUploadLimits replaces DefaultUploadLimits. It is not safe to call while
+// uploads are running.
+func (s *Service) SetUploadLimits(l UploadLimits) {
+	s.limits = newLimits(l)
+}
+
+// limited runs f once sem has room, or fails with ctx.
+func limited(ctx context.Context, sem *semaphore.Weighted, f func() error) error {
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer sem.Release(1)
+
+	return f()
+}
+
+func (s *Service) probe(ctx context.Context, r io.ReadSeeker, input, fileType string) (*mediaInfo, error) {
+	var info *mediaInfo
+	err := limited(ctx, s.limits.ffmpeg, func() error {
+		var err error
+		info, err = probeMedia(ctx, r, input, fileType)
+		return err
+	})
+
+	return info, err
+}
+
+func (s *Service) thumbnail(ctx context.Context, path string) (io.Reader, error) {
+	var thumb io.Reader
+	err := limited(ctx, s.limits.ffmpeg, func() error {
+		buf, err := generateThumbnail(ctx, path)
+		if err != nil {
+			return err
+		}
+		thumb = buf
+		return nil
+	})
+
+	return thumb, err
+}
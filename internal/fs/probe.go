@@ -0,0 +1,126 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+type mediaInfo struct {
+	width    int
+	height   int
+	duration float64
+	// tags are the container level tags ffprobe reports, only set for videos.
+	tags map[string]string
+}
+
+type ffprobeStream struct {
+	CodecType string            `json:"codec_type"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Duration  string            `json:"duration"`
+	Tags      map[string]string `json:"tags"`
+	SideData  []struct {
+		Rotation int `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+type ffprobeOutput struct {
+	Streams []ffprobeStream `json:"streams"`
+	Format  struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// probeMedia reads the dimensions of an image, or the dimensions and duration
+// of a video. Images the standard library can decode from r skip the ffprobe
+// call, which reads input instead, a path or URL holding the same file.
+func probeMedia(ctx context.Context, r io.ReadSeeker, input, fileType string) (*mediaInfo, error) {
+	if fileType == "image" {
+		cfg, _, err := image.DecodeConfig(r)
+		if _, seekErr := r.Seek(0, io.SeekStart); seekErr != nil {
+			return nil, fmt.Errorf("seek to start: %w", seekErr)
+		}
+		if err == nil {
+			return &mediaInfo{width: cfg.Width, height: cfg.Height}, nil
+		}
+	}
+
+	out, err := runFFprobe(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var info mediaInfo
+	for _, stream := range out.Streams {
+		if stream.CodecType != "video" {
+			continue
+		}
+
+		info.width, info.height = stream.Width, stream.Height
+		if isRotated(stream) {
+			info.width, info.height = info.height, info.width
+		}
+
+		if fileType == "video" {
+			info.duration, _ = strconv.ParseFloat(stream.Duration, 64)
+		}
+		break
+	}
+
+	if fileType == "video" {
+		if info.duration == 0 {
+			info.duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+		}
+		info.tags = out.Format.Tags
+	}
+
+	return &info, nil
+}
+
+func runFFprobe(ctx context.Context, path string) (*ffprobeOutput, error) {
+	args := []string{
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	}
+
+	ffprobeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ffprobeCtx, "ffprobe", args...)
+	cmd.Stdout = &buf
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run ffprobe: %w", err)
+	}
+
+	var out ffprobeOutput
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("decode ffprobe output: %w", err)
+	}
+
+	return &out, nil
+}
+
+// isRotated reports whether a video stream is displayed rotated by a quarter
+// turn, in which case its stored width and height are swapped on screen.
+func isRotated(stream ffprobeStream) bool {
+	rotation, _ := strconv.Atoi(stream.Tags["rotate"])
+	for _, sd := range stream.SideData {
+		if sd.Rotation != 0 {
+			rotation = sd.Rotation
+		}
+	}
+
+	return rotation%180 != 0
+}
@@ -0,0 +1,259 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/portbound/go-fs/internal/tag"
+)
+
+// ParseQuery parses the library filter language. A query is a list of terms
+// separated by spaces, all of which must match:
+//
+//	beach                    filename contains "beach"
+//	name:"summer trip"       filename contains "summer trip"
+//	type:video               images or videos, photo is accepted for image
+//	camera:iphone            camera make or model contains "iphone"
+//	tag:"road trip"          tagged "road trip", ignoring case
+//	taken:2024-06            taken during June 2024, also 2024 or 2024-06-01
+//	taken:>=2024-01-01       comparisons with >, >=, < and <=
+//	uploaded:2024-01..2024-03  inclusive ranges, * leaves a side open
+//	size:>10MB               sizes in B, KB, MB or GB, 1KB is 1024 bytes
+//
+// Dates are UTC, the same clock capture times are stored in.
+func ParseQuery(q string) (Filter, error) {
+	var f Filter
+	terms, err := splitTerms(q)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	for _, term := range terms {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok {
+			f.Names = append(f.Names, term)
+			continue
+		}
+
+		if value == "" {
+			return Filter{}, fmt.Errorf("%w: %q has no value", ErrInvalidQuery, key)
+		}
+
+		switch strings.ToLower(key) {
+		case "name":
+			f.Names = append(f.Names, value)
+		case "type":
+			switch strings.ToLower(value) {
+			case "image", "photo":
+				f.MediaType = "image"
+			case "video":
+				f.MediaType = "video"
+			default:
+				return Filter{}, fmt.Errorf("%w: unknown type %q", ErrInvalidQuery, value)
+			}
+		case "camera":
+			f.Camera = value
+		case "tag":
+			f.Tags = append(f.Tags, tag.Normalize(value))
+		case "taken":
+			err = parseTimeRange(value, &f.Taken)
+		case "uploaded":
+			err = parseTimeRange(value, &f.Uploaded)
+		case "size":
+			err = parseSizeRange(value, &f.Size)
+		default:
+			return Filter{}, fmt.Errorf("%w: unknown key %q", ErrInvalidQuery, key)
+		}
+		if err != nil {
+			return Filter{}, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, key, err)
+		}
+	}
+
+	return f, nil
+}
+
+// splitTerms splits on spaces outside double quotes and drops the quotes, so
+// camera:"EOS R5" is one term.
+func splitTerms(q string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+
+	return terms, nil
+}
+
+// cutOperator splits a leading comparison operator off a value.
+func cutOperator(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if rest, ok := strings.CutPrefix(value, op); ok {
+			return op, rest
+		}
+	}
+
+	return "", value
+}
+
+func parseTimeRange(value string, r *TimeRange) error {
+	op, value := cutOperator(value)
+	if lo, hi, ok := strings.Cut(value, ".."); ok && op == "" {
+		if lo != "*" {
+			start, _, err := parseDate(lo)
+			if err != nil {
+				return err
+			}
+			r.From = &start
+		}
+		if hi != "*" {
+			_, end, err := parseDate(hi)
+			if err != nil {
+				return err
+			}
+			r.To = &end
+		}
+		return nil
+	}
+
+	start, end, err := parseDate(value)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case "":
+		r.From, r.To = &start, &end
+	case ">=":
+		r.From = &start
+	case ">":
+		r.From = &end
+	case "<":
+		r.To = &start
+	case "<=":
+		r.To = &end
+	}
+
+	return nil
+}
+
+// parseDate returns the start and end of the year, month or day written.
+func parseDate(s string) (time.Time, time.Time, error) {
+	layouts := []struct {
+		layout     string
+		years      int
+		months     int
+		days       int
+		isDateTime bool
+	}{
+		{layout: "2006", years: 1},
+		{layout: "2006-01", months: 1},
+		{layout: "2006-01-02", days: 1},
+		{layout: time.RFC3339, isDateTime: true},
+	}
+
+	for _, l := range layouts {
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			continue
+		}
+
+		t = t.UTC()
+		if l.isDateTime {
+			return t, t.Add(time.Second), nil
+		}
+		return t, t.AddDate(l.years, l.months, l.days), nil
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func parseSizeRange(value string, r *SizeRange) error {
+	op, value := cutOperator(value)
+	if lo, hi, ok := strings.Cut(value, ".."); ok && op == "" {
+		if lo != "*" {
+			n, err := parseSize(lo)
+			if err != nil {
+				return err
+			}
+			r.Min = &n
+		}
+		if hi != "*" {
+			n, err := parseSize(hi)
+			if err != nil {
+				return err
+			}
+			r.Max = &n
+		}
+		return nil
+	}
+
+	n, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case "":
+		r.Min, r.Max = &n, &n
+	case ">=":
+		r.Min = &n
+	case ">":
+		n++
+		r.Min = &n
+	case "<":
+		n--
+		r.Max = &n
+	case "<=":
+		r.Max = &n
+	}
+
+	return nil
+}
+
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(s)
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if rest, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, multiplier = rest, unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,62 @@
+package fs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	date := func(year int, month time.Month, day int) *time.Time {
+		t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	size := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Filter
+		wantErr bool
+	}{
+		{name: "empty", query: "  ", want: Filter{}},
+		{name: "bare words", query: "beach  day", want: Filter{Names: []string{"beach", "day"}}},
+		{name: "quoted name", query: `name:"summer trip" "a b"`, want: Filter{Names: []string{"summer trip", "a b"}}},
+		{name: "type", query: "type:Photo", want: Filter{MediaType: "image"}},
+		{name: "camera", query: `camera:"EOS R5"`, want: Filter{Camera: "EOS R5"}},
+		{name: "tags", query: `tag:Beach tag:"Road  Trip"`, want: Filter{Tags: []string{"beach", "road trip"}}},
+		{name: "year", query: "taken:2024", want: Filter{Taken: TimeRange{From: date(2024, 1, 1), To: date(2025, 1, 1)}}},
+		{name: "month", query: "taken:2024-12", want: Filter{Taken: TimeRange{From: date(2024, 12, 1), To: date(2025, 1, 1)}}},
+		{name: "after day", query: "taken:>2024-02-28", want: Filter{Taken: TimeRange{From: date(2024, 2, 29)}}},
+		{name: "up to day", query: "uploaded:<=2024-02-28", want: Filter{Uploaded: TimeRange{To: date(2024, 2, 29)}}},
+		{name: "date range", query: "taken:2024-01..2024-03", want: Filter{Taken: TimeRange{From: date(2024, 1, 1), To: date(2024, 4, 1)}}},
+		{name: "open range", query: "taken:*..2023", want: Filter{Taken: TimeRange{To: date(2024, 1, 1)}}},
+		{name: "combined bounds", query: "taken:>=2024 taken:<2024-07", want: Filter{Taken: TimeRange{From: date(2024, 1, 1), To: date(2024, 7, 1)}}},
+		{name: "size greater", query: "size:>10MB", want: Filter{Size: SizeRange{Min: size(10<<20 + 1)}}},
+		{name: "size range", query: "size:1.5kb..2KB", want: Filter{Size: SizeRange{Min: size(1536), Max: size(2048)}}},
+		{name: "unknown key", query: "colour:red", wantErr: true},
+		{name: "unknown type", query: "type:audio", wantErr: true},
+		{name: "bad date", query: "taken:yesterday", wantErr: true},
+		{name: "bad size", query: "size:>big", wantErr: true},
+		{name: "empty value", query: "camera:", wantErr: true},
+		{name: "unterminated quote", query: `name:"oops`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.query)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("ParseQuery(%q) err = %v, want ErrInvalidQuery", tt.query, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
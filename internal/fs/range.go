@@ -0,0 +1,106 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxRanges caps the number of ranges served from a single request so a
+// client cannot make us open one storage reader per byte.
+const maxRanges = 32
+
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+func (r ByteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// parseRange parses a Range header against an object of the given size. A nil
+// slice with a nil error means the header should be ignored and the whole
+// object served.
+func parseRange(header string, size int64) ([]ByteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, errors.New("invalid range unit")
+	}
+
+	var ranges []ByteRange
+	var total int64
+	noOverlap := false
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+		var r ByteRange
+		if startStr == "" {
+			// Suffix range, the last n bytes of the object.
+			if endStr == "" || endStr[0] == '-' {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			if n == 0 {
+				noOverlap = true
+				continue
+			}
+			n = min(n, size)
+			r = ByteRange{Start: size - n, Length: n}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+
+			end := size - 1
+			if endStr != "" {
+				end, err = strconv.ParseInt(endStr, 10, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("invalid range %q", spec)
+				}
+				end = min(end, size-1)
+			}
+			r = ByteRange{Start: start, Length: end - start + 1}
+		}
+
+		ranges = append(ranges, r)
+		total += r.Length
+	}
+
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, ErrRangeNotSatisfiable
+		}
+		return nil, errors.New("empty range")
+	}
+
+	// Asking for more bytes than the object holds, or for an absurd number of
+	// pieces, is cheaper to answer with the whole object.
+	if total > size || len(ranges) > maxRanges {
+		return nil, nil
+	}
+
+	return ranges, nil
+}
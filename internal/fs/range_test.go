@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		size    int64
+		want    []ByteRange
+		wantErr error
+	}{
+		{name: "no header", header: "", size: 100, want: nil},
+		{name: "first bytes", header: "bytes=0-9", size: 100, want: []ByteRange{{Start: 0, Length: 10}}},
+		{name: "open ended", header: "bytes=90-", size: 100, want: []ByteRange{{Start: 90, Length: 10}}},
+		{name: "suffix", header: "bytes=-5", size: 100, want: []ByteRange{{Start: 95, Length: 5}}},
+		{name: "suffix larger than object", header: "bytes=-500", size: 100, want: []ByteRange{{Start: 0, Length: 100}}},
+		{name: "end past object", header: "bytes=50-500", size: 100, want: []ByteRange{{Start: 50, Length: 50}}},
+		{name: "multiple", header: "bytes=0-0, 10-19", size: 100, want: []ByteRange{{Start: 0, Length: 1}, {Start: 10, Length: 10}}},
+		{name: "skips unsatisfiable part", header: "bytes=0-0,200-300", size: 100, want: []ByteRange{{Start: 0, Length: 1}}},
+		{name: "overlapping exceeds size", header: "bytes=0-99,0-99", size: 100, want: nil},
+		{name: "start past object", header: "bytes=100-", size: 100, wantErr: ErrRangeNotSatisfiable},
+		{name: "empty object", header: "bytes=0-", size: 0, wantErr: ErrRangeNotSatisfiable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRange(tt.header, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseRange() err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	invalid := []string{"bytes", "items=0-1", "bytes=a-b", "bytes=5-1", "bytes=--1", "bytes=,"}
+	for _, header := range invalid {
+		t.Run(header, func(t *testing.T) {
+			if _, err := parseRange(header, 100); err == nil {
+				t.Errorf("parseRange(%q) expected error", header)
+			}
+		})
+	}
+}
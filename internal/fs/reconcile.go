@@ -0,0 +1,245 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultStrayAge is how old an object without a row has to be before it
+// counts as stray. Anything younger may belong to a file still being written,
+// such as a thumbnail being rendered again.
+const DefaultStrayAge = 24 * time.Hour
+
+// DefaultMaxPurges is how many rows missing their original one run removes.
+// A few lost originals are an accident, far more at once is more likely
+// storage the server is not seeing all of, and purging would lose the rows
+// for good.
+const DefaultMaxPurges = 50
+
+type IssueKind string
+
+const (
+	// IssueMissingOriginal is a row whose original is gone. The file cannot
+	// be served again, so repairing it removes the row and the thumbnail.
+	IssueMissingOriginal IssueKind = "missing_original"
+	// IssueMissingThumbnail is repaired by rendering the thumbnail again from
+	// the original.
+	IssueMissingThumbnail IssueKind = "missing_thumbnail"
+	// IssueStrayObject is an object no row points to, repaired by deleting it.
+	IssueStrayObject IssueKind = "stray_object"
+	// IssueEmptyBucket is a bucket that lists no objects although rows point
+	// into it. That is an unmounted disk or the wrong project far more often
+	// than every file being lost, so nothing in the bucket is repaired.
+	IssueEmptyBucket IssueKind = "empty_bucket"
+)
+
+// InventoryStore is what the reconciler needs on top of MetaStore to walk
+// every user's files.
+type InventoryStore interface {
+	// GetBuckets returns every user along with the bucket holding their files.
+	GetBuckets(ctx context.Context) ([]UserBucket, error)
+	// GetInventory returns every file whose objects are in the bucket,
+	// whoever owns it, trashed and pending ones included.
+	GetInventory(ctx context.Context, bucket string) ([]Metadata, error)
+}
+
+type UserBucket struct {
+	UserId string
+	Bucket string
+}
+
+type ReconcileOptions struct {
+	// DryRun reports what is wrong without changing anything.
+	DryRun bool
+	// StrayAge overrides DefaultStrayAge when set.
+	StrayAge time.Duration
+	// MaxPurges overrides DefaultMaxPurges when set. Rows past it are still
+	// reported but left alone.
+	MaxPurges int
+}
+
+type Issue struct {
+	Kind   IssueKind `json:"kind"`
+	UserId string    `json:"user_id"`
+	Bucket string    `json:"bucket"`
+	// FileId is empty for stray objects, they have no row.
+	FileId   string `json:"file_id,omitempty"`
+	Object   string `json:"object"`
+	Repaired bool   `json:"repaired"`
+	Err      error  `json:"-"`
+}
+
+type ReconcileReport struct {
+	Buckets int     `json:"buckets"`
+	Files   int     `json:"files"`
+	Objects int     `json:"objects"`
+	Issues  []Issue `json:"issues"`
+	// purges counts the rows removed so far, across buckets.
+	purges int
+}
+
+// Reconciler compares every bucket with the rows pointing into it and
+// reports, or repairs, whatever does not line up.
+type Reconciler struct {
+	service   *Service
+	inventory InventoryStore
+}
+
+func NewReconciler(s *Service, inventory InventoryStore) *Reconciler {
+	return &Reconciler{service: s, inventory: inventory}
+}
+
+// Reconcile walks every user's bucket. A failed repair is recorded on its
+// issue rather than stopping the run, only failing to read a bucket or the
+// database does that.
+func (r *Reconciler) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	buckets, err := r.inventory.GetBuckets(dbCtx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("get buckets: %w", err)
+	}
+
+	report := &ReconcileReport{Issues: []Issue{}}
+	for _, b := range buckets {
+		if err := r.reconcileBucket(ctx, b, opts, report); err != nil {
+			return report, fmt.Errorf("reconcile bucket %q: %w", b.Bucket, err)
+		}
+		report.Buckets++
+	}
+
+	return report, nil
+}
+
+func (r *Reconciler) reconcileBucket(ctx context.Context, b UserBucket, opts ReconcileOptions, report *ReconcileReport) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	files, err := r.inventory.GetInventory(dbCtx, b.Bucket)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("get inventory: %w", err)
+	}
+
+	// List after reading the rows, so an upload finishing in between shows
+	// up as a young object rather than a row without its original.
+	objects, err := r.service.media.List(ctx, b.Bucket)
+	if err != nil && !errors.Is(err, ErrBucketNotExist) {
+		return fmt.Errorf("list objects: %w", err)
+	}
+
+	report.Files += len(files)
+	report.Objects += len(objects)
+
+	if len(objects) == 0 {
+		if len(files) > 0 {
+			report.Issues = append(report.Issues, Issue{Kind: IssueEmptyBucket, UserId: b.UserId, Bucket: b.Bucket})
+		}
+		return nil
+	}
+
+	maxPurges := opts.MaxPurges
+	if maxPurges <= 0 {
+		maxPurges = DefaultMaxPurges
+	}
+
+	stored := make(map[string]ObjectInfo, len(objects))
+	for _, o := range objects {
+		stored[o.Name] = o
+	}
+
+	referenced := make(map[string]bool, 2*len(files))
+	// Files sharing a blob share its thumbnail, which only needs rendering
+	// once.
+	rebuilt := make(map[string]bool)
+	for i := range files {
+		f := &files[i]
+		referenced[f.Object] = true
+		referenced[f.Thumbname] = true
+		// A pending upload may not have stored its objects yet, and if it
+		// died SweepUploads owns the cleanup.
+		if f.Pending {
+			continue
+		}
+
+		issue := Issue{UserId: f.UserId, Bucket: b.Bucket, FileId: f.Id}
+		_, hasOriginal := stored[f.Object]
+		_, hasThumbnail := stored[f.Thumbname]
+		switch {
+		case !hasOriginal:
+			issue.Kind, issue.Object = IssueMissingOriginal, f.Object
+			switch {
+			case opts.DryRun:
+			case report.purges >= maxPurges:
+				issue.Err = fmt.Errorf("over the limit of %d purges per run", maxPurges)
+			default:
+				report.purges++
+				issue.Err = r.service.purge(ctx, f)
+			}
+		case !hasThumbnail && !rebuilt[f.Thumbname]:
+			issue.Kind, issue.Object = IssueMissingThumbnail, f.Thumbname
+			rebuilt[f.Thumbname] = true
+			if !opts.DryRun {
+				issue.Err = r.rebuildThumbnail(ctx, f)
+			}
+		default:
+			continue
+		}
+
+		issue.Repaired = !opts.DryRun && issue.Err == nil
+		report.Issues = append(report.Issues, issue)
+	}
+
+	strayAge := opts.StrayAge
+	if strayAge <= 0 {
+		strayAge = DefaultStrayAge
+	}
+	for _, o := range objects {
+		if referenced[o.Name] || time.Since(o.Created) < strayAge {
+			continue
+		}
+
+		issue := Issue{Kind: IssueStrayObject, UserId: b.UserId, Bucket: b.Bucket, Object: o.Name}
+		if !opts.DryRun {
+			if err := r.service.media.Delete(ctx, o.Name, b.Bucket); err != nil && !errors.Is(err, ErrMediaNotExist) {
+				issue.Err = fmt.Errorf("delete media %q: %w", o.Name, err)
+			}
+		}
+
+		issue.Repaired = !opts.DryRun && issue.Err == nil
+		report.Issues = append(report.Issues, issue)
+	}
+
+	return nil
+}
+
+func (r *Reconciler) rebuildThumbnail(ctx context.Context, m *Metadata) error {
+	if m.WrappedKey != "" {
+		return errors.New("only the client can render the thumbnail of a vault file")
+	}
+
+	_, reader, err := r.service.media.Download(ctx, m.Object, m.Bucket)
+	if err != nil {
+		return fmt.Errorf("download original: %w", err)
+	}
+	defer reader.Close()
+
+	f, err := stageFile(m.Filename, reader)
+	if err != nil {
+		return fmt.Errorf("stage file to disk: %w", err)
+	}
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	thumbReader, err := r.service.thumbnail(ctx, f.Name())
+	if err != nil {
+		return fmt.Errorf("generate thumbnail: %w", err)
+	}
+
+	if err := r.service.media.Upload(ctx, m.Thumbname, m.Bucket, thumbReader); err != nil {
+		return fmt.Errorf("upload thumbnail: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package fs_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+func TestReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	meta := fs.NewMockMetaStore()
+	media := fs.NewMockMediaStore()
+
+	// ok has both objects, gone lost its original and bare its thumbnail.
+	// stray.jpg was left behind by an upload whose row never got written.
+	for _, id := range []string{"ok", "gone", "bare"} {
+		meta.Save(ctx, &fs.Metadata{Id: id, UserId: "u1", Filename: id + ".jpg", Thumbname: "thumb-" + id + ".jpg", UploadedAt: time.Now()})
+	}
+	// twin is u2's copy of bare, sharing its objects.
+	meta.Save(ctx, &fs.Metadata{Id: "twin", UserId: "u2", Filename: "twin.jpg", Object: "bare.jpg", Thumbname: "thumb-bare.jpg", UploadedAt: time.Now()})
+	// inflight is an upload that has not stored anything yet.
+	meta.Save(ctx, &fs.Metadata{Id: "inflight", UserId: "u1", Filename: "inflight.jpg", Thumbname: "thumb-inflight.jpg", UploadedAt: time.Now(), Pending: true})
+	for _, name := range []string{"ok.jpg", "thumb-ok.jpg", "thumb-gone.jpg", "bare.jpg", "stray.jpg"} {
+		media.Upload(ctx, name, "test_bucket", strings.NewReader("data"))
+	}
+
+	r := fs.NewReconciler(fs.NewService(meta, media), meta)
+	issues := func(report *fs.ReconcileReport) []string {
+		var issues []string
+		for _, i := range report.Issues {
+			issues = append(issues, string(i.Kind)+" "+i.Object)
+		}
+		slices.Sort(issues)
+		return issues
+	}
+	want := []string{"missing_original gone.jpg", "missing_thumbnail thumb-bare.jpg", "stray_object stray.jpg"}
+
+	// Everything was just uploaded, so nothing is old enough to be stray.
+	report, err := r.Reconcile(ctx, fs.ReconcileOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Reconcile(): %v", err)
+	}
+	if got := issues(report); !slices.Equal(got, want[:2]) {
+		t.Errorf("Reconcile() issues = %v, want %v", got, want[:2])
+	}
+
+	report, err = r.Reconcile(ctx, fs.ReconcileOptions{DryRun: true, StrayAge: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Reconcile(): %v", err)
+	}
+	if got := issues(report); !slices.Equal(got, want) {
+		t.Errorf("Reconcile() issues = %v, want %v", got, want)
+	}
+	if report.Buckets != 1 || report.Files != 5 || report.Objects != 5 {
+		t.Errorf("Reconcile() counted %d buckets, %d files, %d objects, want 1, 5, 5", report.Buckets, report.Files, report.Objects)
+	}
+	for _, i := range report.Issues {
+		if i.Repaired {
+			t.Errorf("dry run repaired %s %s", i.Kind, i.Object)
+		}
+	}
+	if _, _, err := media.Download(ctx, "stray.jpg", "test_bucket"); err != nil {
+		t.Errorf("dry run removed stray.jpg: %v", err)
+	}
+
+	report, err = r.Reconcile(ctx, fs.ReconcileOptions{StrayAge: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Reconcile(): %v", err)
+	}
+	for _, i := range report.Issues {
+		// Rendering a thumbnail needs ffmpeg, which the test may not have.
+		if i.Kind != fs.IssueMissingThumbnail && !i.Repaired {
+			t.Errorf("Reconcile() did not repair %s %s: %v", i.Kind, i.Object, i.Err)
+		}
+	}
+
+	if _, err := meta.Get(ctx, "gone", "u1"); err == nil {
+		t.Error("row without an original survived the repair")
+	}
+	for _, name := range []string{"thumb-gone.jpg", "stray.jpg"} {
+		if _, _, err := media.Download(ctx, name, "test_bucket"); err == nil {
+			t.Errorf("%s survived the repair", name)
+		}
+	}
+	if _, _, err := media.Download(ctx, "ok.jpg", "test_bucket"); err != nil {
+		t.Errorf("repair touched a healthy file: %v", err)
+	}
+}
+
+func TestReconciler_EmptyBucket(t *testing.T) {
+	ctx := context.Background()
+	meta := fs.NewMockMetaStore()
+	media := fs.NewMockMediaStore()
+
+	// Storage that lists nothing for a bucket full of rows is more likely
+	// not mounted than empty.
+	for _, id := range []string{"a", "b"} {
+		meta.Save(ctx, &fs.Metadata{Id: id, UserId: "u1", Filename: id + ".jpg", Thumbname: "thumb-" + id + ".jpg", UploadedAt: time.Now()})
+	}
+
+	r := fs.NewReconciler(fs.NewService(meta, media), meta)
+	report, err := r.Reconcile(ctx, fs.ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile(): %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != fs.IssueEmptyBucket || report.Issues[0].Repaired {
+		t.Errorf("Reconcile() issues = %+v, want one unrepaired %s", report.Issues, fs.IssueEmptyBucket)
+	}
+	for _, id := range []string{"a", "b"} {
+		if _, err := meta.Get(ctx, id, "u1"); err != nil {
+			t.Errorf("row %s of an empty bucket was removed: %v", id, err)
+		}
+	}
+}
+
+func TestReconciler_MaxPurges(t *testing.T) {
+	ctx := context.Background()
+	meta := fs.NewMockMetaStore()
+	media := fs.NewMockMediaStore()
+
+	ids := []string{"a", "b", "c"}
+	for _, id := range ids {
+		meta.Save(ctx, &fs.Metadata{Id: id, UserId: "u1", Filename: id + ".jpg", Thumbname: "thumb-" + id + ".jpg", UploadedAt: time.Now()})
+		media.Upload(ctx, "thumb-"+id+".jpg", "test_bucket", strings.NewReader("data"))
+	}
+
+	r := fs.NewReconciler(fs.NewService(meta, media), meta)
+	report, err := r.Reconcile(ctx, fs.ReconcileOptions{MaxPurges: 2})
+	if err != nil {
+		t.Fatalf("Reconcile(): %v", err)
+	}
+
+	var repaired, refused int
+	for _, i := range report.Issues {
+		if i.Kind != fs.IssueMissingOriginal {
+			t.Errorf("unexpected issue %s %s", i.Kind, i.Object)
+			continue
+		}
+		switch {
+		case i.Repaired:
+			repaired++
+		case i.Err != nil:
+			refused++
+		}
+	}
+	if repaired != 2 || refused != 1 {
+		t.Errorf("Reconcile() repaired %d and refused %d, want 2 and 1", repaired, refused)
+	}
+
+	var left int
+	for _, id := range ids {
+		if _, err := meta.Get(ctx, id, "u1"); err == nil {
+			left++
+		}
+	}
+	if left != 1 {
+		t.Errorf("%d rows left, want the 1 past the limit", left)
+	}
+}
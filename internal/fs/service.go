@@ -3,100 +3,254 @@ package fs
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	_ "image/gif"
+	_ "image/jpeg"
 	_ "image/png"
 
 	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+
+	defaultSearchResults = 50
+	maxCaptionLength     = 2000
+
+	purgeBatchSize = 100
+
+	maxWrappedKeyLength        = 1 << 10
+	maxEncryptedMetadataLength = 64 << 10
+
+	// staleUploadAge is how long an upload can stay pending before the sweep
+	// takes it for one that died with its process. Uploads on other replicas
+	// are still running well inside it.
+	staleUploadAge = time.Hour
+)
+
 type Service struct {
 	meta  MetaStore
 	media MediaStore
+	// direct is nil unless EnableDirectUploads was called.
+	direct DirectStore
+	// urlKey is nil unless EnableSignedURLs was called, signer is nil unless
+	// storage signs the URLs itself.
+	urlKey    []byte
+	urlExpiry time.Duration
+	signer    DownloadSigner
+	limits    limits
 }
 
 func NewService(meta MetaStore, media MediaStore) *Service {
-	return &Service{meta: meta, media: media}
+	return &Service{meta: meta, media: media, limits: newLimits(DefaultUploadLimits)}
 }
 
+// Upload processes requests on a pool of workers, see UploadLimits, so results
+// come back in the order uploads finish; Index tells which request each is
+// for. A request's Reader is read to the end, or not at all, and closed before
+// the next request is taken, so readers may share one stream like the parts
+// of a multipart body. Results have to be received while requests are sent.
 func (s *Service) Upload(ctx context.Context, requests <-chan UploadRequest) <-chan UploadResult {
 	results := make(chan UploadResult)
 
 	go func() {
+		var wg sync.WaitGroup
 		defer close(results)
+		defer wg.Wait()
 
+		index := 0
 		for request := range requests {
-			err := func() error {
-				defer request.Reader.Close()
+			result := UploadResult{Index: index, Filename: request.Filename}
+			index++
+
+			// A worker is taken before staging, so no more files are on
+			// disk than there are workers.
+			if err := s.limits.workers.Acquire(ctx, 1); err != nil {
+				request.Reader.Close()
+				result.Err = err
+				results <- result
+				continue
+			}
 
-				fileType := strings.Split(request.ContentType, "/")[0]
-				if fileType != "image" && fileType != "video" {
-					return ErrUnsupportedFileType
-				}
+			f, err := s.stage(ctx, request)
+			if err != nil {
+				s.limits.workers.Release(1)
+				result.Err = err
+				results <- result
+				continue
+			}
 
-				dbReadCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
-				defer cancel()
-				if _, err := s.meta.Get(dbReadCtx, request.Filename, request.UserId); err == nil {
-					return ErrFileExists
-				}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer s.limits.workers.Release(1)
+				defer os.Remove(f.Name())
+				defer f.Close()
 
-				meta := Metadata{
-					Id:        uuid.New().String(),
-					Filename:  request.Filename,
-					Thumbname: "thumb-" + request.Filename,
-					UserId:    request.UserId,
+				result.Metadata, result.Err = s.process(ctx, request, f)
+				if result.Err == nil {
+					result.Id = result.Metadata.Id
 				}
+				results <- result
+			}()
+		}
+	}()
 
-				f, err := stageFile(meta.Filename, request.Reader)
-				if err != nil {
-					return fmt.Errorf("stage file to disk: %w", err)
-				}
-				defer f.Close()
-				defer os.Remove(f.Name())
+	return results
+}
 
-				thumbReader, err := generateThumbnail(ctx, f.Name())
-				if err != nil {
-					return fmt.Errorf("generate thumbnail: %w", err)
-				}
+// stage copies the request to disk and closes its Reader, unless the request
+// is refused before that.
+func (s *Service) stage(ctx context.Context, request UploadRequest) (*stagedFile, error) {
+	defer request.Reader.Close()
 
-				g, groupCtx := errgroup.WithContext(ctx)
-				g.Go(func() error {
-					return s.media.Upload(groupCtx, meta.Filename, request.Bucket, f)
-				})
+	if v := request.Vault; v != nil {
+		if v.Thumbnail == nil || v.WrappedKey == "" || v.EncryptedMetadata == "" {
+			return nil, fmt.Errorf("%w: a thumbnail, a wrapped key and encrypted metadata are required", ErrInvalidVaultUpload)
+		}
 
-				g.Go(func() error {
-					return s.media.Upload(groupCtx, meta.Thumbname, request.Bucket, thumbReader)
-				})
+		if len(v.WrappedKey) > maxWrappedKeyLength || len(v.EncryptedMetadata) > maxEncryptedMetadataLength {
+			return nil, fmt.Errorf("%w: wrapped key or encrypted metadata too long", ErrInvalidVaultUpload)
+		}
 
-				if err := g.Wait(); err != nil {
-					return err
-				}
+		f, err := stageFile("vault-*", request.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("stage file to disk: %w", err)
+		}
+		return f, nil
+	}
 
-				dbWriteCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
-				defer cancel()
-				if err := s.meta.Save(dbWriteCtx, &meta); err != nil {
-					return fmt.Errorf("save metadata: %w", err)
-				}
+	fileType := strings.Split(request.ContentType, "/")[0]
+	if fileType != "image" && fileType != "video" {
+		return nil, ErrUnsupportedFileType
+	}
 
-				return nil
-			}()
+	f, err := stageFile(request.Filename, request.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("stage file to disk: %w", err)
+	}
 
-			results <- UploadResult{
-				Filename: request.Filename,
-				Err:      err,
-			}
-		}
-	}()
+	return f, nil
+}
 
-	return results
+// process stores a staged upload and returns the new file.
+func (s *Service) process(ctx context.Context, request UploadRequest, f *stagedFile) (*Metadata, error) {
+	if request.Vault != nil {
+		return s.uploadVault(ctx, request, f)
+	}
+
+	fileType := strings.Split(request.ContentType, "/")[0]
+	info, err := s.probe(ctx, f, f.Name(), fileType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: probe media: %w", ErrInvalidMedia, err)
+	}
+
+	capture := extractCaptureInfo(f.File, fileType, info)
+	// EXIF orientations 5 through 8 are rotated a quarter turn, so the stored
+	// dimensions are the displayed ones swapped.
+	if capture.exif != nil && capture.exif.Orientation >= 5 {
+		info.width, info.height = info.height, info.width
+	}
+
+	// Objects are named by their content, so identical uploads share them.
+	meta := Metadata{
+		Id:          uuid.New().String(),
+		Filename:    request.Filename,
+		Thumbname:   "thumb-" + f.sha256,
+		UserId:      request.UserId,
+		Bucket:      request.Bucket,
+		Object:      f.sha256,
+		ContentType: request.ContentType,
+		Size:        f.size,
+		UploadedAt:  time.Now().UTC(),
+		Width:       info.width,
+		Height:      info.height,
+		Duration:    info.duration,
+		SHA256:      f.sha256,
+		TakenAt:     capture.takenAt,
+		Exif:        capture.exif,
+	}
+
+	// The pending row goes in first and claims the name, so a duplicate fails
+	// before it can overwrite anything. Its reference also keeps the blob from
+	// being purged meanwhile.
+	meta.Pending = true
+	if err := s.save(ctx, &meta); err != nil {
+		return nil, err
+	}
+
+	if err := s.storeUpload(ctx, &meta, f, func() (io.Reader, error) {
+		return s.thumbnail(ctx, f.Name())
+	}); err != nil {
+		return nil, err
+	}
+
+	meta.Pending = false
+	return &meta, nil
+}
+
+// uploadVault stores a file the client encrypted. There is nothing to probe
+// or render in ciphertext, so the row only records what the server can see
+// and the client's sealed metadata.
+func (s *Service) uploadVault(ctx context.Context, request UploadRequest, f *stagedFile) (*Metadata, error) {
+	v := request.Vault
+
+	// The real filename is sealed, the id stands in for it so the row still
+	// has a unique name.
+	id := uuid.New().String()
+	meta := Metadata{
+		Id:                id,
+		Filename:          id,
+		Thumbname:         "thumb-" + f.sha256,
+		UserId:            request.UserId,
+		Bucket:            request.Bucket,
+		Object:            f.sha256,
+		ContentType:       "application/octet-stream",
+		Size:              f.size,
+		UploadedAt:        time.Now().UTC(),
+		SHA256:            f.sha256,
+		Pending:           true,
+		WrappedKey:        v.WrappedKey,
+		EncryptedMetadata: v.EncryptedMetadata,
+	}
+
+	if err := s.save(ctx, &meta); err != nil {
+		return nil, err
+	}
+
+	if err := s.storeUpload(ctx, &meta, f, func() (io.Reader, error) { return v.Thumbnail, nil }); err != nil {
+		return nil, err
+	}
+
+	meta.Pending = false
+	return &meta, nil
+}
+
+// save writes the row of a new upload once a database slot is free, the wait
+// does not count against the write's timeout.
+func (s *Service) save(ctx context.Context, m *Metadata) error {
+	return limited(ctx, s.limits.database, func() error {
+		dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		if err := s.meta.Save(dbCtx, m); err != nil {
+			return fmt.Errorf("save metadata: %w", err)
+		}
+		return nil
+	})
 }
 
 func (s *Service) Download(ctx context.Context, request DownloadRequest) (*DownloadResult, error) {
@@ -112,7 +266,7 @@ func (s *Service) Download(ctx context.Context, request DownloadRequest) (*Downl
 		return nil, errors.New("unauthorized request")
 	}
 
-	attrs, reader, err := s.media.Download(ctx, request.FileId, request.Bucket)
+	info, reader, err := s.media.Download(ctx, objectName(metadata, request.Thumbnail), metadata.Bucket)
 	if err != nil {
 		if errors.Is(err, ErrMediaNotExist) {
 			return nil, ErrMediaCorrupted
@@ -123,39 +277,406 @@ func (s *Service) Download(ctx context.Context, request DownloadRequest) (*Downl
 
 	return &DownloadResult{
 		Reader:      reader,
-		ContentType: attrs.ContentType,
-		Size:        attrs.Size,
-		Timestamp:   attrs.Created,
+		ContentType: contentType(metadata, request.Thumbnail, info.ContentType),
+		object:      objectName(metadata, request.Thumbnail),
+		bucket:      metadata.Bucket,
+		Size:        info.Size,
+		Timestamp:   info.Created,
+		ETag:        info.ETag,
 	}, nil
 }
 
-func (s *Service) GetMetadata(ctx context.Context, userId string) ([]Metadata, error) {
+// contentType is what a file or its thumbnail is served as, given the type
+// storage has for the object. Objects named by content have no extension to
+// tell their type by, and only the client knows what is inside a vault file.
+func contentType(metadata *Metadata, thumbnail bool, stored string) string {
+	switch {
+	case metadata.WrappedKey != "":
+		return "application/octet-stream"
+	case !thumbnail && metadata.ContentType != "":
+		return metadata.ContentType
+	default:
+		return stored
+	}
+}
+
+// DownloadRange reads part of a file Download already resolved, so serving
+// several ranges looks the file up once.
+func (s *Service) DownloadRange(ctx context.Context, result *DownloadResult, r ByteRange) (io.ReadCloser, error) {
+	reader, err := s.media.DownloadRange(ctx, result.object, result.bucket, r.Start, r.Length)
+	if err != nil {
+		if errors.Is(err, ErrMediaNotExist) {
+			return nil, ErrMediaCorrupted
+		}
+
+		return nil, fmt.Errorf("download media %q range %d-%d: %w", result.object, r.Start, r.Start+r.Length-1, err)
+	}
+
+	return reader, nil
+}
+
+func (s *Service) GetMetadata(ctx context.Context, request ListRequest) (*MetadataPage, error) {
+	filter, err := ParseQuery(request.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := ListOptions{
+		Filter: filter,
+		SortBy: request.SortBy,
+		Limit:  request.Limit,
+	}
+
+	if opts.SortBy == "" {
+		opts.SortBy = SortByTaken
+	}
+
+	switch opts.SortBy {
+	case SortByTaken, SortByUploaded, SortBySize:
+	case SortByName:
+		opts.Ascending = true
+	default:
+		return nil, ErrInvalidSort
+	}
+
+	switch request.Order {
+	case "":
+	case "asc":
+		opts.Ascending = true
+	case "desc":
+		opts.Ascending = false
+	default:
+		return nil, ErrInvalidSort
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	opts.Limit = min(opts.Limit, maxPageSize)
+
+	if request.Cursor != "" {
+		after, err := decodeCursor(request.Cursor, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.After = after
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	// One extra row tells us whether there is a next page without a second
+	// query.
+	limit := opts.Limit
+	opts.Limit++
+	files, err := s.meta.GetAll(dbCtx, request.UserId, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.meta.Count(dbCtx, request.UserId, opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
+
+	page := &MetadataPage{Files: files, Total: total}
+	if len(files) > limit {
+		page.Files = files[:limit]
+		page.NextCursor = encodeCursor(opts, page.Files[limit-1])
+	}
+
+	if page.Files == nil {
+		page.Files = []Metadata{}
+	}
+
+	return page, nil
+}
+
+func (s *Service) GetFileMetadata(ctx context.Context, fileId, userId string) (*Metadata, error) {
 	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	return s.meta.GetAll(dbCtx, userId)
+	return s.meta.Get(dbCtx, fileId, userId)
 }
 
+func (s *Service) UpdateCaption(ctx context.Context, fileId, userId, caption string) (*Metadata, error) {
+	caption = strings.TrimSpace(caption)
+	if utf8.RuneCountInString(caption) > maxCaptionLength || !utf8.ValidString(caption) {
+		return nil, fmt.Errorf("%w: must be valid UTF-8 of at most %d characters", ErrInvalidCaption, maxCaptionLength)
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := s.meta.UpdateCaption(dbCtx, fileId, userId, caption); err != nil {
+		return nil, err
+	}
+
+	return s.meta.Get(dbCtx, fileId, userId)
+}
+
+func (s *Service) Search(ctx context.Context, request SearchRequest) (*SearchPage, error) {
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultSearchResults
+	}
+	limit = min(limit, maxPageSize)
+	offset := max(request.Offset, 0)
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	results, err := s.meta.Search(dbCtx, request.UserId, request.Query, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SearchPage{Results: results}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		page.NextOffset = offset + limit
+	}
+
+	if page.Results == nil {
+		page.Results = []SearchResult{}
+	}
+
+	for i := range page.Results {
+		page.Results[i].Snippet = highlight(page.Results[i].Snippet)
+	}
+
+	return page, nil
+}
+
+// highlight escapes a snippet from the store and only then turns its match
+// markers into tags, so nothing in a filename or caption is ever markup.
+func highlight(snippet string) string {
+	return strings.NewReplacer(HighlightStart, "<mark>", HighlightEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// Delete moves a file to the trash. The objects stay in the bucket until the
+// file is purged, so it can be restored until then.
 func (s *Service) Delete(ctx context.Context, request DeleteRequest) error {
-	if err := s.media.Delete(ctx, request.FileId, request.Bucket); err != nil {
-		return fmt.Errorf("delete media: %w", err)
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := s.meta.Trash(dbCtx, request.FileId, request.UserId, time.Now().UTC()); err != nil {
+		return fmt.Errorf("trash metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) Restore(ctx context.Context, fileId, userId string) (*Metadata, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := s.meta.Restore(dbCtx, fileId, userId); err != nil {
+		return nil, fmt.Errorf("restore metadata: %w", err)
+	}
+
+	return s.meta.Get(dbCtx, fileId, userId)
+}
+
+func (s *Service) GetTrash(ctx context.Context, userId string) ([]Metadata, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	files, err := s.meta.GetTrash(dbCtx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if files == nil {
+		files = []Metadata{}
 	}
 
-	if err := s.meta.Delete(ctx, request.FileId, request.UserId); err != nil {
+	return files, nil
+}
+
+// Purge deletes a trashed file and its objects for good, without waiting for
+// the retention period to run out.
+func (s *Service) Purge(ctx context.Context, request DeleteRequest) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	metadata, err := s.meta.GetTrashed(dbCtx, request.FileId, request.UserId)
+	if err != nil {
+		if _, getErr := s.meta.Get(dbCtx, request.FileId, request.UserId); getErr == nil {
+			return ErrNotTrashed
+		}
+		return fmt.Errorf("get trashed metadata: %w", err)
+	}
+
+	return s.purge(ctx, metadata)
+}
+
+// storeUpload stores both objects of a pending file, unless another file
+// already did, and commits it. If any step fails the rest is undone, so a
+// failed upload leaves nothing behind.
+func (s *Service) storeUpload(ctx context.Context, m *Metadata, original io.Reader, thumbnail func() (io.Reader, error)) error {
+	err := func() error {
+		dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		blob, err := s.meta.GetBlob(dbCtx, m.SHA256)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("get blob: %w", err)
+		}
+
+		if !blob.Stored {
+			if err := s.storeObjects(ctx, m, original, thumbnail); err != nil {
+				return err
+			}
+		}
+
+		return limited(ctx, s.limits.database, func() error {
+			dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+			defer cancel()
+			if err := s.meta.CommitUpload(dbCtx, m.Id, m.UserId); err != nil {
+				return fmt.Errorf("commit upload: %w", err)
+			}
+			return nil
+		})
+	}()
+	if err == nil {
+		return nil
+	}
+
+	// A cancelled request is a common reason to get here, the cleanup must
+	// not be cut short along with it. If it fails anyway the row is still
+	// pending and SweepUploads gets it later.
+	if purgeErr := s.purge(context.WithoutCancel(ctx), m); purgeErr != nil {
+		return errors.Join(err, fmt.Errorf("%w: %w", ErrOrphanedFile, purgeErr))
+	}
+
+	return err
+}
+
+func (s *Service) storeObjects(ctx context.Context, m *Metadata, original io.Reader, thumbnail func() (io.Reader, error)) error {
+	thumbReader, err := thumbnail()
+	if err != nil {
+		return fmt.Errorf("generate thumbnail: %w", err)
+	}
+
+	g, groupCtx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return limited(groupCtx, s.limits.storage, func() error {
+			return s.media.Upload(groupCtx, m.Object, m.Bucket, original)
+		})
+	})
+
+	g.Go(func() error {
+		return limited(groupCtx, s.limits.storage, func() error {
+			return s.media.Upload(groupCtx, m.Thumbname, m.Bucket, thumbReader)
+		})
+	})
+
+	return g.Wait()
+}
+
+// SweepUploads removes uploads a crash left pending, along with whatever
+// objects they stored, and reports how many it removed.
+func (s *Service) SweepUploads(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-staleUploadAge)
+	return s.purgeAll(ctx, func(ctx context.Context) ([]Metadata, error) {
+		files, err := s.meta.GetStaleUploads(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("get stale uploads: %w", err)
+		}
+		return files, nil
+	})
+}
+
+// PurgeTrash purges every file trashed before cutoff and reports how many it
+// removed. A file whose row fails to delete stays in the trash for the next
+// run.
+func (s *Service) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	return s.purgeAll(ctx, func(ctx context.Context) ([]Metadata, error) {
+		files, err := s.meta.GetExpiredTrash(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("get expired trash: %w", err)
+		}
+		return files, nil
+	})
+}
+
+// purgeAll purges batches from next until it returns a short one.
+func (s *Service) purgeAll(ctx context.Context, next func(context.Context) ([]Metadata, error)) (int, error) {
+	var purged int
+	for {
+		dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		files, err := next(dbCtx)
+		cancel()
+		if err != nil {
+			return purged, err
+		}
+
+		var errs error
+		for _, f := range files {
+			if err := s.purge(ctx, &f); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("purge %q: %w", f.Id, err))
+				continue
+			}
+			purged++
+		}
+
+		// Failures would come back in the next batch, so stop rather than
+		// spin on them.
+		if errs != nil || len(files) < purgeBatchSize {
+			return purged, errs
+		}
+	}
+}
+
+// purge removes the row before the objects, only then is it known whether
+// another file still uses them. Objects a failure leaves behind are strays
+// the reconciler removes.
+func (s *Service) purge(ctx context.Context, m *Metadata) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	last, err := s.meta.Delete(dbCtx, m.Id, m.UserId)
+	cancel()
+	if err != nil {
 		return fmt.Errorf("delete metadata: %w", err)
 	}
 
+	if !last {
+		return nil
+	}
+
+	for _, name := range []string{m.Object, m.Thumbname} {
+		if err := s.media.Delete(ctx, name, m.Bucket); err != nil && !errors.Is(err, ErrMediaNotExist) {
+			return fmt.Errorf("delete media %q: %w", name, err)
+		}
+	}
+
 	return nil
 }
 
-func stageFile(name string, r io.Reader) (*os.File, error) {
+func objectName(m *Metadata, thumbnail bool) string {
+	if thumbnail {
+		return m.Thumbname
+	}
+
+	return m.Object
+}
+
+type stagedFile struct {
+	*os.File
+	size   int64
+	sha256 string
+}
+
+func stageFile(name string, r io.Reader) (*stagedFile, error) {
 	f, err := os.CreateTemp("", name)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = io.Copy(f, r)
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
 	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
 		return nil, fmt.Errorf("copy file: %w", err)
 	}
 
@@ -165,7 +686,7 @@ func stageFile(name string, r io.Reader) (*os.File, error) {
 		return nil, fmt.Errorf("seek to start: %w", err)
 	}
 
-	return f, nil
+	return &stagedFile{File: f, size: n, sha256: hex.EncodeToString(h.Sum(nil))}, nil
 }
 
 func generateThumbnail(ctx context.Context, path string) (*bytes.Buffer, error) {
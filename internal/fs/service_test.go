@@ -2,10 +2,16 @@ package fs_test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/portbound/go-fs/internal/fs"
 )
@@ -105,3 +111,198 @@ func TestService_Upload(t *testing.T) {
 		})
 	}
 }
+
+func TestService_GetMetadata(t *testing.T) {
+	meta := fs.NewMockMetaStore()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		meta.Save(context.Background(), &fs.Metadata{
+			Id:         fmt.Sprintf("f%d", i),
+			UserId:     "u1",
+			UploadedAt: base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	meta.Save(context.Background(), &fs.Metadata{Id: "other", UserId: "u2", UploadedAt: base})
+
+	s := fs.NewService(meta, fs.NewMockMediaStore())
+
+	var got []string
+	request := fs.ListRequest{UserId: "u1", SortBy: fs.SortByUploaded, Limit: 2}
+	for range 10 {
+		page, err := s.GetMetadata(context.Background(), request)
+		if err != nil {
+			t.Fatalf("GetMetadata(): %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("Total = %d, want 5", page.Total)
+		}
+
+		for _, m := range page.Files {
+			got = append(got, m.Id)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		request.Cursor = page.NextCursor
+	}
+
+	want := []string{"f4", "f3", "f2", "f1", "f0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	if _, err := s.GetMetadata(context.Background(), fs.ListRequest{UserId: "u1", SortBy: fs.SortByTaken, Cursor: request.Cursor}); !errors.Is(err, fs.ErrInvalidCursor) {
+		t.Errorf("cursor from another sort err = %v, want ErrInvalidCursor", err)
+	}
+
+	if _, err := s.GetMetadata(context.Background(), fs.ListRequest{UserId: "u1", Cursor: "not a cursor"}); !errors.Is(err, fs.ErrInvalidCursor) {
+		t.Errorf("garbage cursor err = %v, want ErrInvalidCursor", err)
+	}
+
+	if _, err := s.GetMetadata(context.Background(), fs.ListRequest{UserId: "u1", SortBy: fs.SortByUploaded, Order: "asc", Cursor: request.Cursor}); !errors.Is(err, fs.ErrInvalidCursor) {
+		t.Errorf("cursor from another order err = %v, want ErrInvalidCursor", err)
+	}
+
+	if _, err := s.GetMetadata(context.Background(), fs.ListRequest{UserId: "u1", SortBy: "colour"}); !errors.Is(err, fs.ErrInvalidSort) {
+		t.Errorf("unknown sort err = %v, want ErrInvalidSort", err)
+	}
+
+	if _, err := s.GetMetadata(context.Background(), fs.ListRequest{UserId: "u1", Query: "colour:red"}); !errors.Is(err, fs.ErrInvalidQuery) {
+		t.Errorf("unknown filter err = %v, want ErrInvalidQuery", err)
+	}
+
+	page, err := s.GetMetadata(context.Background(), fs.ListRequest{UserId: "u1", Query: "uploaded:<2025-01-01T02:00:00Z", SortBy: fs.SortByUploaded, Order: "asc"})
+	if err != nil {
+		t.Fatalf("GetMetadata() filtered: %v", err)
+	}
+	if page.Total != 2 || len(page.Files) != 2 || page.Files[0].Id != "f0" || page.Files[1].Id != "f1" {
+		t.Errorf("filtered page = %+v, want f0, f1 of 2", page)
+	}
+}
+
+func TestService_Search(t *testing.T) {
+	meta := fs.NewMockMetaStore()
+	meta.Save(context.Background(), &fs.Metadata{Id: "f1", UserId: "u1", Filename: "IMG_0001.jpg"})
+	meta.Save(context.Background(), &fs.Metadata{Id: "f2", UserId: "u1", Filename: "IMG_0002.jpg"})
+	meta.Save(context.Background(), &fs.Metadata{Id: "f3", UserId: "u2", Filename: "IMG_0003.jpg", Caption: "beach"})
+
+	s := fs.NewService(meta, fs.NewMockMediaStore())
+
+	if _, err := s.UpdateCaption(context.Background(), "f3", "u1", "mine now"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateCaption() of another user's file err = %v, want sql.ErrNoRows", err)
+	}
+
+	if _, err := s.UpdateCaption(context.Background(), "f1", "u1", strings.Repeat("a", 2001)); !errors.Is(err, fs.ErrInvalidCaption) {
+		t.Errorf("UpdateCaption() of a long caption err = %v, want ErrInvalidCaption", err)
+	}
+
+	for _, id := range []string{"f1", "f2"} {
+		m, err := s.UpdateCaption(context.Background(), id, "u1", "  <b>Beach</b> day  ")
+		if err != nil {
+			t.Fatalf("UpdateCaption(): %v", err)
+		}
+		if m.Caption != "<b>Beach</b> day" {
+			t.Errorf("Caption = %q, want it trimmed", m.Caption)
+		}
+	}
+
+	page, err := s.Search(context.Background(), fs.SearchRequest{UserId: "u1", Query: "beach", Limit: 1})
+	if err != nil {
+		t.Fatalf("Search(): %v", err)
+	}
+	if len(page.Results) != 1 || page.Results[0].Id != "f1" || page.NextOffset != 1 {
+		t.Fatalf("Search() first page = %+v", page)
+	}
+
+	want := "&lt;b&gt;<mark>Beach</mark>&lt;/b&gt; day"
+	if page.Results[0].Snippet != want {
+		t.Errorf("Snippet = %q, want %q", page.Results[0].Snippet, want)
+	}
+
+	page, err = s.Search(context.Background(), fs.SearchRequest{UserId: "u1", Query: "beach", Limit: 1, Offset: page.NextOffset})
+	if err != nil {
+		t.Fatalf("Search(): %v", err)
+	}
+	if len(page.Results) != 1 || page.Results[0].Id != "f2" || page.NextOffset != 0 {
+		t.Errorf("Search() last page = %+v", page)
+	}
+}
+
+func TestService_Trash(t *testing.T) {
+	ctx := context.Background()
+	meta := fs.NewMockMetaStore()
+	media := fs.NewMockMediaStore()
+	for _, id := range []string{"f1", "f2", "f3"} {
+		meta.Save(ctx, &fs.Metadata{Id: id, UserId: "u1", Filename: id + ".jpg", Thumbname: "thumb-" + id + ".jpg", UploadedAt: time.Now()})
+		media.Upload(ctx, id+".jpg", "test_bucket", strings.NewReader("original"))
+		media.Upload(ctx, "thumb-"+id+".jpg", "test_bucket", strings.NewReader("thumbnail"))
+	}
+
+	s := fs.NewService(meta, media)
+	ids := func(files []fs.Metadata) []string {
+		var ids []string
+		for _, f := range files {
+			ids = append(ids, f.Id)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	for _, id := range []string{"f1", "f2"} {
+		if err := s.Delete(ctx, fs.DeleteRequest{FileId: id, UserId: "u1"}); err != nil {
+			t.Fatalf("Delete(%s): %v", id, err)
+		}
+	}
+	if err := s.Delete(ctx, fs.DeleteRequest{FileId: "f1", UserId: "u1"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Delete() of a trashed file err = %v, want sql.ErrNoRows", err)
+	}
+
+	page, err := s.GetMetadata(ctx, fs.ListRequest{UserId: "u1"})
+	if err != nil || !slices.Equal(ids(page.Files), []string{"f3"}) || page.Total != 1 {
+		t.Errorf("GetMetadata() = %+v, %v, want only f3", page, err)
+	}
+
+	if _, err := s.GetFileMetadata(ctx, "f1", "u1"); err == nil {
+		t.Error("GetFileMetadata() of a trashed file succeeded")
+	}
+	if _, err := s.Download(ctx, fs.DownloadRequest{FileId: "f1", UserId: "u1"}); err == nil {
+		t.Error("Download() of a trashed file succeeded")
+	}
+
+	trash, err := s.GetTrash(ctx, "u1")
+	if err != nil || !slices.Equal(ids(trash), []string{"f1", "f2"}) {
+		t.Errorf("GetTrash() = %v, %v, want f1 and f2", ids(trash), err)
+	}
+
+	if m, err := s.Restore(ctx, "f1", "u1"); err != nil || m.DeletedAt != nil {
+		t.Errorf("Restore() = %+v, %v", m, err)
+	}
+	if _, err := s.Restore(ctx, "f3", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Restore() of a file outside the trash err = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := s.Purge(ctx, fs.DeleteRequest{FileId: "f3", UserId: "u1"}); !errors.Is(err, fs.ErrNotTrashed) {
+		t.Errorf("Purge() of a file outside the trash err = %v, want ErrNotTrashed", err)
+	}
+
+	// Only f2 is trashed, and only a cutoff after it was trashed purges it.
+	if n, err := s.PurgeTrash(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("PurgeTrash() before retention ran out = %d, %v, want 0", n, err)
+	}
+	if n, err := s.PurgeTrash(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("PurgeTrash() = %d, %v, want 1", n, err)
+	}
+
+	for _, name := range []string{"f2.jpg", "thumb-f2.jpg"} {
+		if _, _, err := media.Download(ctx, name, "test_bucket"); !errors.Is(err, fs.ErrMediaNotExist) {
+			t.Errorf("Download(%s) after purge err = %v, want ErrMediaNotExist", name, err)
+		}
+	}
+	if _, _, err := media.Download(ctx, "thumb-f1.jpg", "test_bucket"); err != nil {
+		t.Errorf("Download() of a restored thumbnail: %v", err)
+	}
+	if trash, _ := s.GetTrash(ctx, "u1"); len(trash) != 0 {
+		t.Errorf("GetTrash() after purge = %v, want empty", ids(trash))
+	}
+}
@@ -0,0 +1,111 @@
+package fs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signedPath is where RegisterSignedRoutes serves downloads signed by the
+// server.
+const signedPath = "/signed/"
+
+// EnableSignedURLs lets SignDownload hand out URLs signed with key. They are
+// storage's own URLs when signer is not nil, which has to be the backend under
+// the service's MediaStore.
+func (s *Service) EnableSignedURLs(key []byte, expiry time.Duration, signer DownloadSigner) {
+	s.urlKey = key
+	s.urlExpiry = expiry
+	s.signer = signer
+}
+
+// SignDownload returns a URL the file or its thumbnail can be fetched from
+// without a token, such as by an <img> or <video> tag. URLs stay valid for
+// between one and two expiry periods: handed out again within the same period
+// the URL is the same, so the browser finds it in its cache.
+func (s *Service) SignDownload(ctx context.Context, request DownloadRequest) (*SignedURL, error) {
+	if s.urlKey == nil {
+		return nil, ErrSignedURLUnavailable
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	metadata, err := s.meta.Get(dbCtx, request.FileId, request.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("get metadata: %w", err)
+	}
+
+	if metadata.UserId != request.UserId {
+		return nil, errors.New("unauthorized request")
+	}
+
+	expires := time.Now().UTC().Truncate(s.urlExpiry).Add(2 * s.urlExpiry)
+
+	if s.signer != nil {
+		u, err := s.signer.SignDownload(ctx, objectName(metadata, request.Thumbnail), metadata.Bucket, contentType(metadata, request.Thumbnail, ""), expires)
+		if err != nil {
+			return nil, fmt.Errorf("sign download of %q: %w", request.FileId, err)
+		}
+		return &SignedURL{URL: u, ExpiresAt: expires}, nil
+	}
+
+	path := signedPath + url.PathEscape(request.UserId) + "/" + url.PathEscape(request.FileId)
+	if request.Thumbnail {
+		path += "/thumbnail"
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", hex.EncodeToString(s.signDownload(request, expires.Unix())))
+
+	return &SignedURL{URL: path + "?" + query.Encode(), ExpiresAt: expires}, nil
+}
+
+// VerifyDownload checks a URL SignDownload signed for request and returns
+// when it expires.
+func (s *Service) VerifyDownload(request DownloadRequest, expires, signature string) (time.Time, error) {
+	if s.urlKey == nil {
+		return time.Time{}, ErrSignedURLUnavailable
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	mac, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.signDownload(request, unix)) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expiresAt := time.Unix(unix, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	return expiresAt, nil
+}
+
+func (s *Service) signDownload(request DownloadRequest, expires int64) []byte {
+	object := "original"
+	if request.Thumbnail {
+		object = "thumbnail"
+	}
+
+	mac := hmac.New(sha256.New, s.urlKey)
+	mac.Write([]byte(strings.Join([]string{
+		"GET",
+		request.UserId,
+		request.FileId,
+		object,
+		strconv.FormatInt(expires, 10),
+	}, "\n")))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,95 @@
+package fs
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockDownloadSigner records what it was asked to sign.
+type mockDownloadSigner struct {
+	name, bucket, contentType string
+}
+
+func (m *mockDownloadSigner) SignDownload(ctx context.Context, name, bucket, contentType string, expires time.Time) (string, error) {
+	m.name, m.bucket, m.contentType = name, bucket, contentType
+	return "https://storage.example.com/" + bucket + "/" + name, nil
+}
+
+func TestService_SignDownload(t *testing.T) {
+	ctx := context.Background()
+	meta := NewMockMetaStore()
+	s := NewService(meta, NewMockMediaStore())
+	meta.Save(ctx, &Metadata{Id: "f1", UserId: "u1", Filename: "a.jpg", Thumbname: "thumb-abc", Object: "abc", SHA256: "abc", ContentType: "image/jpeg", UploadedAt: time.Now()})
+	request := DownloadRequest{FileId: "f1", UserId: "u1"}
+
+	if _, err := s.SignDownload(ctx, request); !errors.Is(err, ErrSignedURLUnavailable) {
+		t.Fatalf("SignDownload() before EnableSignedURLs err = %v, want %v", err, ErrSignedURLUnavailable)
+	}
+
+	s.EnableSignedURLs([]byte("key"), time.Hour, nil)
+	signed, err := s.SignDownload(ctx, request)
+	if err != nil {
+		t.Fatalf("SignDownload(): %v", err)
+	}
+	if until := time.Until(signed.ExpiresAt); until < time.Hour || until > 2*time.Hour {
+		t.Errorf("URL expires in %v, want between one and two hours", until)
+	}
+	if again, _ := s.SignDownload(ctx, request); again.URL != signed.URL {
+		t.Errorf("SignDownload() again = %q, want the same URL %q", again.URL, signed.URL)
+	}
+
+	u, err := url.Parse(signed.URL)
+	if err != nil || u.Path != "/signed/u1/f1" {
+		t.Fatalf("signed URL = %q, want it under /signed/u1/f1", signed.URL)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	tests := []struct {
+		name      string
+		request   DownloadRequest
+		expires   string
+		signature string
+		wantErr   error
+	}{
+		{name: "valid", request: request, expires: expires, signature: signature},
+		{name: "thumbnail", request: DownloadRequest{FileId: "f1", UserId: "u1", Thumbnail: true}, expires: expires, signature: signature, wantErr: ErrInvalidSignature},
+		{name: "other user", request: DownloadRequest{FileId: "f1", UserId: "u2"}, expires: expires, signature: signature, wantErr: ErrInvalidSignature},
+		{name: "extended", request: request, expires: expires + "0", signature: signature, wantErr: ErrInvalidSignature},
+		{name: "garbage", request: request, expires: "soon", signature: "zz", wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.VerifyDownload(tt.request, tt.expires, tt.signature); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyDownload() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// An expired URL is refused even with a good signature.
+	past := time.Now().Add(-time.Minute).Unix()
+	stale := hex.EncodeToString(s.signDownload(request, past))
+	if _, err := s.VerifyDownload(request, strconv.FormatInt(past, 10), stale); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyDownload() of an expired URL err = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	if _, err := s.SignDownload(ctx, DownloadRequest{FileId: "f1", UserId: "u2"}); err == nil {
+		t.Error("SignDownload() of another user's file err = nil")
+	}
+
+	// Storage that signs its own URLs gets the object and what to serve it as.
+	signer := &mockDownloadSigner{}
+	s.EnableSignedURLs([]byte("key"), time.Hour, signer)
+	signed, err = s.SignDownload(ctx, request)
+	if err != nil || !strings.HasPrefix(signed.URL, "https://storage.example.com/") {
+		t.Fatalf("SignDownload() = %+v, %v, want storage's URL", signed, err)
+	}
+	if signer.name != "abc" || signer.bucket != "test_bucket" || signer.contentType != "image/jpeg" {
+		t.Errorf("signed %+v, want the original in test_bucket as image/jpeg", signer)
+	}
+}
@@ -0,0 +1,322 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingMediaStore fails every upload of one object name.
+type failingMediaStore struct {
+	*MockMediaStore
+	fail string
+}
+
+func (f *failingMediaStore) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	if name == f.fail {
+		return errors.New("upload failed")
+	}
+	return f.MockMediaStore.Upload(ctx, name, bucket, src)
+}
+
+func thumbnail() (io.Reader, error) {
+	return strings.NewReader("thumbnail"), nil
+}
+
+func TestService_storeUpload(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      string
+		committed bool
+	}{
+		{name: "both objects stored", committed: true},
+		{name: "original fails", fail: "abc"},
+		{name: "thumbnail fails", fail: "thumb-abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			meta := NewMockMetaStore()
+			media := &failingMediaStore{MockMediaStore: NewMockMediaStore(), fail: tt.fail}
+			s := NewService(meta, media)
+
+			m := &Metadata{Id: "f1", UserId: "u1", Filename: "a.jpg", Thumbname: "thumb-abc", Object: "abc", SHA256: "abc", UploadedAt: time.Now(), Pending: true}
+			meta.Save(ctx, m)
+
+			err := s.storeUpload(ctx, m, strings.NewReader("original"), thumbnail)
+			if (err == nil) != tt.committed {
+				t.Fatalf("storeUpload() err = %v, want committed %v", err, tt.committed)
+			}
+
+			got, err := meta.Get(ctx, "f1", "u1")
+			if tt.committed && (err != nil || got.Pending) {
+				t.Errorf("Get() after commit = %+v, %v", got, err)
+			}
+			if !tt.committed && len(meta.store) != 0 {
+				t.Errorf("failed upload left its row behind")
+			}
+
+			objects, _ := media.List(ctx, "test_bucket")
+			if want := map[bool]int{true: 2, false: 0}[tt.committed]; len(objects) != want {
+				t.Errorf("bucket holds %d objects, want %d", len(objects), want)
+			}
+		})
+	}
+}
+
+func TestService_SweepUploads(t *testing.T) {
+	ctx := context.Background()
+	meta := NewMockMetaStore()
+	media := NewMockMediaStore()
+	s := NewService(meta, media)
+
+	// stale died an hour and a half ago after storing its original, fresh
+	// is still running and done is a finished upload.
+	meta.Save(ctx, &Metadata{Id: "stale", UserId: "u1", Filename: "stale.jpg", Thumbname: "thumb-stale.jpg", UploadedAt: time.Now().Add(-90 * time.Minute), Pending: true})
+	meta.Save(ctx, &Metadata{Id: "fresh", UserId: "u1", Filename: "fresh.jpg", Thumbname: "thumb-fresh.jpg", UploadedAt: time.Now(), Pending: true})
+	meta.Save(ctx, &Metadata{Id: "done", UserId: "u1", Filename: "done.jpg", Thumbname: "thumb-done.jpg", UploadedAt: time.Now().Add(-2 * time.Hour)})
+	media.Upload(ctx, "stale.jpg", "test_bucket", strings.NewReader("original"))
+
+	n, err := s.SweepUploads(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("SweepUploads() = %d, %v, want 1", n, err)
+	}
+	if _, ok := meta.store["stale"]; ok {
+		t.Error("stale upload survived the sweep")
+	}
+	if _, _, err := media.Download(ctx, "stale.jpg", "test_bucket"); !errors.Is(err, ErrMediaNotExist) {
+		t.Errorf("stale upload's original survived the sweep: %v", err)
+	}
+	for _, id := range []string{"fresh", "done"} {
+		if _, ok := meta.store[id]; !ok {
+			t.Errorf("sweep removed %s", id)
+		}
+	}
+}
+
+func TestService_sharedBlobs(t *testing.T) {
+	ctx := context.Background()
+	meta := NewMockMetaStore()
+	media := NewMockMediaStore()
+	s := NewService(meta, media)
+
+	// u2 uploads the same content u1 already stored, into their own bucket.
+	first := &Metadata{Id: "f1", UserId: "u1", Filename: "a.jpg", Thumbname: "thumb-abc", Object: "abc", SHA256: "abc", Bucket: "b1", Pending: true}
+	meta.Save(ctx, first)
+	if err := s.storeUpload(ctx, first, strings.NewReader("original"), thumbnail); err != nil {
+		t.Fatalf("storeUpload() first: %v", err)
+	}
+
+	second := &Metadata{Id: "f2", UserId: "u2", Filename: "b.jpg", Thumbname: "thumb-abc", Object: "abc", SHA256: "abc", Bucket: "b2", Pending: true}
+	meta.Save(ctx, second)
+	// Writing again would fail, the stored blob has to be reused.
+	s.media = &failingMediaStore{MockMediaStore: media, fail: "abc"}
+	if err := s.storeUpload(ctx, second, strings.NewReader("original"), thumbnail); err != nil {
+		t.Fatalf("storeUpload() second: %v", err)
+	}
+	if second.Bucket != "b1" {
+		t.Errorf("second upload filed under bucket %q, want b1", second.Bucket)
+	}
+	if objects, _ := media.List(ctx, "b2"); len(objects) != 0 {
+		t.Errorf("second upload wrote %d objects to its own bucket", len(objects))
+	}
+
+	if err := s.purge(ctx, first); err != nil {
+		t.Fatalf("purge() first: %v", err)
+	}
+	if objects, _ := media.List(ctx, "b1"); len(objects) != 2 {
+		t.Errorf("purging one of two references left %d objects, want 2", len(objects))
+	}
+
+	if err := s.purge(ctx, second); err != nil {
+		t.Fatalf("purge() second: %v", err)
+	}
+	if objects, _ := media.List(ctx, "b1"); len(objects) != 0 {
+		t.Errorf("purging the last reference left %d objects", len(objects))
+	}
+	if _, err := meta.GetBlob(ctx, "abc"); err == nil {
+		t.Error("blob survived its last reference")
+	}
+}
+
+func TestService_uploadVault(t *testing.T) {
+	tests := []struct {
+		name    string
+		vault   VaultUpload
+		wantErr error
+	}{
+		{name: "sealed file", vault: VaultUpload{Thumbnail: strings.NewReader("sealed thumbnail"), WrappedKey: "a2V5", EncryptedMetadata: "bWV0YQ=="}},
+		{name: "no thumbnail", vault: VaultUpload{WrappedKey: "a2V5", EncryptedMetadata: "bWV0YQ=="}, wantErr: ErrInvalidVaultUpload},
+		{name: "no wrapped key", vault: VaultUpload{Thumbnail: strings.NewReader("sealed thumbnail"), EncryptedMetadata: "bWV0YQ=="}, wantErr: ErrInvalidVaultUpload},
+		{name: "metadata too long", vault: VaultUpload{Thumbnail: strings.NewReader("sealed thumbnail"), WrappedKey: "a2V5", EncryptedMetadata: strings.Repeat("x", maxEncryptedMetadataLength+1)}, wantErr: ErrInvalidVaultUpload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			meta := NewMockMetaStore()
+			media := NewMockMediaStore()
+			s := NewService(meta, media)
+
+			requests := make(chan UploadRequest, 1)
+			requests <- UploadRequest{Reader: io.NopCloser(strings.NewReader("ciphertext")), UserId: "u1", Bucket: "test_bucket", Vault: &tt.vault}
+			close(requests)
+			result := <-s.Upload(ctx, requests)
+			if !errors.Is(result.Err, tt.wantErr) {
+				t.Fatalf("Upload() err = %v, want %v", result.Err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(meta.store) != 0 {
+					t.Error("rejected upload left a row behind")
+				}
+				return
+			}
+
+			m, err := meta.Get(ctx, result.Id, "u1")
+			if err != nil {
+				t.Fatalf("Get(): %v", err)
+			}
+			if m.Pending || m.Filename != m.Id || m.WrappedKey != "a2V5" || m.EncryptedMetadata != "bWV0YQ==" || m.Size != int64(len("ciphertext")) {
+				t.Errorf("Get() = %+v, want a committed row describing the ciphertext", m)
+			}
+
+			for _, thumb := range []bool{false, true} {
+				result, err := s.Download(ctx, DownloadRequest{FileId: m.Id, UserId: "u1", Thumbnail: thumb})
+				if err != nil {
+					t.Fatalf("Download(thumbnail %v): %v", thumb, err)
+				}
+				got, _ := io.ReadAll(result.Reader)
+				result.Reader.Close()
+				want := map[bool]string{false: "ciphertext", true: "sealed thumbnail"}[thumb]
+				if string(got) != want || result.ContentType != "application/octet-stream" {
+					t.Errorf("Download(thumbnail %v) = %q as %s, want %q as application/octet-stream", thumb, got, result.ContentType, want)
+				}
+			}
+		})
+	}
+}
+
+// slowMediaStore holds every upload for a moment and records how many were
+// running at once.
+type slowMediaStore struct {
+	*MockMediaStore
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+}
+
+func (s *slowMediaStore) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	s.mu.Lock()
+	s.running++
+	s.maxRunning = max(s.maxRunning, s.running)
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+
+	return s.MockMediaStore.Upload(ctx, name, bucket, src)
+}
+
+// lockedMetaStore serializes the calls an upload makes, the mock is not safe
+// for concurrent use.
+type lockedMetaStore struct {
+	*MockMetaStore
+	mu sync.Mutex
+}
+
+func (l *lockedMetaStore) Save(ctx context.Context, meta *Metadata) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MockMetaStore.Save(ctx, meta)
+}
+
+func (l *lockedMetaStore) Get(ctx context.Context, fileId, userId string) (*Metadata, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MockMetaStore.Get(ctx, fileId, userId)
+}
+
+func (l *lockedMetaStore) GetBlob(ctx context.Context, sha256 string) (*Blob, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MockMetaStore.GetBlob(ctx, sha256)
+}
+
+func (l *lockedMetaStore) CommitUpload(ctx context.Context, fileId, userId string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MockMetaStore.CommitUpload(ctx, fileId, userId)
+}
+
+func (l *lockedMetaStore) Delete(ctx context.Context, fileId, userId string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MockMetaStore.Delete(ctx, fileId, userId)
+}
+
+func TestService_UploadPool(t *testing.T) {
+	ctx := context.Background()
+	meta := &lockedMetaStore{MockMetaStore: NewMockMetaStore()}
+	media := &slowMediaStore{MockMediaStore: NewMockMediaStore()}
+	s := NewService(meta, media)
+	s.SetUploadLimits(UploadLimits{Workers: 3, FFmpeg: 1, Storage: 2, Database: 1})
+
+	// Every file differs so none share a blob, and the third is refused.
+	const n = 6
+	requests := make(chan UploadRequest)
+	go func() {
+		defer close(requests)
+		for i := range n {
+			vault := VaultUpload{Thumbnail: strings.NewReader(fmt.Sprint("thumbnail ", i)), WrappedKey: "a2V5", EncryptedMetadata: fmt.Sprint("meta ", i)}
+			if i == 2 {
+				vault.WrappedKey = ""
+			}
+			requests <- UploadRequest{Reader: io.NopCloser(strings.NewReader(fmt.Sprint("ciphertext ", i))), UserId: "u1", Bucket: "test_bucket", Vault: &vault}
+		}
+	}()
+
+	seen := make(map[int]bool)
+	for result := range s.Upload(ctx, requests) {
+		if seen[result.Index] {
+			t.Fatalf("two results for request %d", result.Index)
+		}
+		seen[result.Index] = true
+
+		if result.Index == 2 {
+			if !errors.Is(result.Err, ErrInvalidVaultUpload) {
+				t.Errorf("result for the refused request err = %v, want %v", result.Err, ErrInvalidVaultUpload)
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Fatalf("result %d: %v", result.Index, result.Err)
+		}
+
+		if result.Metadata == nil || result.Metadata.Id != result.Id || result.Metadata.Pending {
+			t.Errorf("result %d carries metadata %+v, want the committed row of %q", result.Index, result.Metadata, result.Id)
+		}
+
+		if result.Metadata == nil || result.Metadata.Id != result.Id || result.Metadata.Pending {
+			t.Errorf("result %d carries metadata %+v, want the committed row of %q", result.Index, result.Metadata, result.Id)
+		}
+
+		m, err := meta.Get(ctx, result.Id, "u1")
+		if err != nil || m.EncryptedMetadata != fmt.Sprint("meta ", result.Index) {
+			t.Errorf("result %d is file %+v, %v, want the one sent as request %d", result.Index, m, err, result.Index)
+		}
+	}
+
+	if len(seen) != n {
+		t.Errorf("got %d results, want %d", len(seen), n)
+	}
+	if media.maxRunning != 2 {
+		t.Errorf("%d bucket writes ran at once, want the limit of 2", media.maxRunning)
+	}
+}
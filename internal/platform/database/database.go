@@ -1,36 +1,64 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"io/fs"
 )
 
 type DBConnection struct {
-	DB *sql.DB
+	DB            *sql.DB
+	migrations    fs.FS
+	migrationLock *MigrationLock
 }
 
 type DBConnectionDetails struct {
-	DriverName string
-	ConnStr    string
-	Schema     string
+	DriverName    string
+	ConnStr       string
+	Migrations    fs.FS
+	MigrationLock *MigrationLock
 }
 
+// NewDBConnection opens the database and applies any pending migrations.
 func NewDBConnection(d *DBConnectionDetails) (*DBConnection, error) {
-	db, err := sql.Open(d.DriverName, d.ConnStr)
+	conn, err := Open(d)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.Ping()
+	migrator, err := conn.Migrator()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := migrator.Up(context.Background()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	return conn, nil
+}
+
+// Open opens the database without touching its schema.
+func Open(d *DBConnectionDetails) (*DBConnection, error) {
+	db, err := sql.Open(d.DriverName, d.ConnStr)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(d.Schema)
+	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &DBConnection{DB: db}, nil
+	return &DBConnection{DB: db, migrations: d.Migrations, migrationLock: d.MigrationLock}, nil
+}
+
+func (dbConn *DBConnection) Migrator() (*Migrator, error) {
+	return NewMigrator(dbConn.DB, dbConn.migrations, dbConn.migrationLock)
 }
 
 func (dbConn *DBConnection) Close() error {
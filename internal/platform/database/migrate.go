@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migration files are named NNNN_name.up.sql and NNNN_name.down.sql, the same
+// layout golang-migrate uses, so sqlc can read the directory as a schema.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// MigrationLock is a pair of statements that keep processes sharing a database
+// from migrating it at the same time, e.g. Postgres advisory locks. Both run on
+// the same connection.
+type MigrationLock struct {
+	Acquire string
+	Release string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	lock       *MigrationLock
+}
+
+// NewMigrator loads the migrations in fsys. lock may be nil for databases only
+// one process opens, like SQLite.
+func NewMigrator(db *sql.DB, fsys fs.FS, lock *MigrationLock) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, lock: lock}, nil
+}
+
+// LoadMigrations reads every migration in the root of fsys, ordered by version.
+// Each version needs both an up and a down file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse version of %q: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %q: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction.
+func (m *Migrator) Up(ctx context.Context) error {
+	unlock, err := m.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := m.step(ctx, migration.Up, fmt.Sprintf("INSERT INTO schema_migrations (version) VALUES (%d)", migration.Version)); err != nil {
+			return fmt.Errorf("apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Down rolls back the most recently applied n migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	unlock, err := m.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if err := m.step(ctx, migration.Down, fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %d", migration.Version)); err != nil {
+			return fmt.Errorf("roll back migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		n--
+	}
+
+	return nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if t, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &t
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) acquire(ctx context.Context) (func(), error) {
+	if m.lock == nil {
+		return func() {}, nil
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection for migration lock: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, m.lock.Acquire); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	return func() {
+		// Closing the connection releases a session lock anyway, the explicit
+		// release only matters when the pool keeps the connection around.
+		conn.ExecContext(context.Background(), m.lock.Release)
+		conn.Close()
+	}, nil
+}
+
+func (m *Migrator) step(ctx context.Context, migration, record string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, record); err != nil {
+		return fmt.Errorf("record version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := m.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+func (db *PostgresDB) GetBuckets(ctx context.Context) ([]fs.UserBucket, error) {
+	users, err := db.Queries.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]fs.UserBucket, len(users))
+	for i, u := range users {
+		buckets[i] = fs.UserBucket{UserId: u.ID, Bucket: u.Bucket}
+	}
+
+	return buckets, nil
+}
+
+func (db *PostgresDB) GetInventory(ctx context.Context, bucket string) ([]fs.Metadata, error) {
+	rows, err := db.Queries.ListInventory(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]fs.Metadata, len(rows))
+	for i, row := range rows {
+		files[i] = toMetadata(row)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// The listing queries are built by hand because sqlc cannot vary the WHERE
+// and ORDER BY clauses. The sort expressions match the indexes in the
+// migrations.
+const (
+	takenExpr    = "COALESCE(taken_at, uploaded_at)"
+	uploadedExpr = "uploaded_at"
+	nameExpr     = "lower(file_name)"
+	sizeExpr     = "size"
+
+	metadataColumns = "id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, wrapped_key, encrypted_metadata"
+)
+
+type listQuery struct {
+	where []string
+	args  []any
+}
+
+func newListQuery(userId string, f fs.Filter) *listQuery {
+	q := &listQuery{}
+	q.add("user_id = %s", userId)
+	q.add("deleted_at IS NULL")
+	q.add("NOT pending")
+
+	if f.MediaType != "" {
+		// A range rather than LIKE so the index on content_type applies,
+		// '0' is the character after '/'.
+		q.add("content_type >= %s AND content_type < %s", f.MediaType+"/", f.MediaType+"0")
+	}
+	for _, name := range f.Names {
+		q.add("file_name ILIKE %s", "%"+escapeLike(name)+"%")
+	}
+	if f.Camera != "" {
+		q.add("EXISTS (SELECT 1 FROM exif WHERE exif.file_id = metadata.id AND camera_make || ' ' || camera_model ILIKE %s)", "%"+escapeLike(f.Camera)+"%")
+	}
+	for _, name := range f.Tags {
+		q.add("EXISTS (SELECT 1 FROM file_tags JOIN tags ON tags.id = file_tags.tag_id WHERE file_tags.file_id = metadata.id AND tags.name = %s)", name)
+	}
+	q.timeRange(takenExpr, f.Taken)
+	q.timeRange(uploadedExpr, f.Uploaded)
+	if f.Size.Min != nil {
+		q.add("size >= %s", *f.Size.Min)
+	}
+	if f.Size.Max != nil {
+		q.add("size <= %s", *f.Size.Max)
+	}
+
+	return q
+}
+
+// add appends a condition with a %s for each of args, which become numbered
+// placeholders.
+func (q *listQuery) add(cond string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, arg := range args {
+		placeholders[i] = q.arg(arg)
+	}
+	q.where = append(q.where, fmt.Sprintf(cond, placeholders...))
+}
+
+func (q *listQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *listQuery) timeRange(expr string, r fs.TimeRange) {
+	if r.From != nil {
+		q.add(expr+" >= %s", *r.From)
+	}
+	if r.To != nil {
+		q.add(expr+" < %s", *r.To)
+	}
+}
+
+func (q *listQuery) sql(selection string) string {
+	return "SELECT " + selection + " FROM metadata WHERE " + strings.Join(q.where, " AND ")
+}
+
+func (db *PostgresDB) GetAll(ctx context.Context, userId string, opts fs.ListOptions) ([]fs.Metadata, error) {
+	q := newListQuery(userId, opts.Filter)
+
+	var expr, param string
+	switch opts.SortBy {
+	case fs.SortByUploaded:
+		expr, param = uploadedExpr, "%s::timestamptz"
+	case fs.SortByName:
+		expr, param = nameExpr, "lower(%s)"
+	case fs.SortBySize:
+		expr, param = sizeExpr, "%s::bigint"
+	default:
+		expr, param = takenExpr, "%s::timestamptz"
+	}
+
+	dir, cmp := "DESC", "<"
+	if opts.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+
+	if opts.After != nil {
+		var value any
+		switch opts.SortBy {
+		case fs.SortByName:
+			value = opts.After.Name
+		case fs.SortBySize:
+			value = opts.After.Size
+		default:
+			value = opts.After.Time
+		}
+		q.add(fmt.Sprintf("(%s, id) %s (%s, %%s::text)", expr, cmp, param), value, opts.After.Id)
+	}
+
+	query := q.sql(metadataColumns) + fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", expr, dir, dir, q.arg(opts.Limit))
+	rows, err := db.Conn.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []fs.Metadata
+	for rows.Next() {
+		var m Metadata
+		if err := rows.Scan(
+			&m.ID,
+			&m.FileName,
+			&m.ThumbName,
+			&m.UserID,
+			&m.ContentType,
+			&m.Size,
+			&m.UploadedAt,
+			&m.Width,
+			&m.Height,
+			&m.Duration,
+			&m.Sha256,
+			&m.TakenAt,
+			&m.Caption,
+			&m.DeletedAt,
+			&m.WrappedKey,
+			&m.EncryptedMetadata,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, toMetadata(m))
+	}
+
+	return results, rows.Err()
+}
+
+func (db *PostgresDB) Count(ctx context.Context, userId string, filter fs.Filter) (int64, error) {
+	q := newListQuery(userId, filter)
+
+	var n int64
+	err := db.Conn.DB.QueryRowContext(ctx, q.sql("COUNT(*)"), q.args...).Scan(&n)
+	return n, err
+}
+
+// escapeLike escapes the LIKE wildcards with the default \ escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,342 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+func (db *PostgresDB) Save(ctx context.Context, m *fs.Metadata) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bucket, err := insertMetadata(ctx, db.Queries.WithTx(tx), m)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.Bucket = bucket
+	return nil
+}
+
+func (db *PostgresDB) ReplacePending(ctx context.Context, m *fs.Metadata) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	n, err := qtx.DeletePendingMetadata(ctx, DeletePendingMetadataParams{ID: m.Id, UserID: m.UserId})
+	if err != nil {
+		return fmt.Errorf("delete pending row: %w", err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	bucket, err := insertMetadata(ctx, qtx, m)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.Bucket = bucket
+	return nil
+}
+
+// insertMetadata inserts the row and returns the bucket it ended up in.
+func insertMetadata(ctx context.Context, qtx *Queries, m *fs.Metadata) (string, error) {
+	// A file stored by content joins its blob wherever that already is.
+	bucket := m.Bucket
+	if m.SHA256 != "" && m.Object == m.SHA256 {
+		var err error
+		bucket, err = qtx.AcquireBlob(ctx, AcquireBlobParams{Sha256: m.SHA256, Bucket: m.Bucket})
+		if err != nil {
+			return "", fmt.Errorf("acquire blob: %w", err)
+		}
+	}
+
+	params := SaveMetadataParams{
+		ID:                m.Id,
+		FileName:          m.Filename,
+		ThumbName:         m.Thumbname,
+		UserID:            m.UserId,
+		ContentType:       m.ContentType,
+		Size:              m.Size,
+		UploadedAt:        m.UploadedAt,
+		Width:             int32(m.Width),
+		Height:            int32(m.Height),
+		Duration:          m.Duration,
+		Sha256:            m.SHA256,
+		TakenAt:           nullTime(m.TakenAt),
+		Pending:           m.Pending,
+		Bucket:            bucket,
+		ObjectName:        m.Object,
+		WrappedKey:        m.WrappedKey,
+		EncryptedMetadata: m.EncryptedMetadata,
+	}
+
+	if err := qtx.SaveMetadata(ctx, params); err != nil {
+		if isUniqueViolation(err) {
+			return "", fs.ErrFileExists
+		}
+		return "", err
+	}
+
+	if m.Exif != nil {
+		if err := qtx.SaveExif(ctx, toSaveExifParams(m.Id, m.Exif)); err != nil {
+			return "", fmt.Errorf("save exif: %w", err)
+		}
+	}
+
+	return bucket, nil
+}
+
+func (db *PostgresDB) Get(ctx context.Context, id, userId string) (*fs.Metadata, error) {
+	params := GetMetadataParams{
+		ID:     id,
+		UserID: userId,
+	}
+
+	m, err := db.Queries.GetMetadata(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := toMetadata(m)
+
+	e, err := db.Queries.GetExif(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("get exif: %w", err)
+	}
+	if err == nil {
+		meta.Exif = toExif(e)
+	}
+
+	return &meta, nil
+}
+
+func (db *PostgresDB) GetPending(ctx context.Context, id, userId string) (*fs.Metadata, error) {
+	m, err := db.Queries.GetPendingMetadata(ctx, GetPendingMetadataParams{ID: id, UserID: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := toMetadata(m)
+	return &meta, nil
+}
+
+func (db *PostgresDB) CommitUpload(ctx context.Context, id, userId string) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	n, err := qtx.CommitUpload(ctx, CommitUploadParams{ID: id, UserID: userId})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := qtx.MarkBlobStored(ctx, MarkBlobStoredParams{ID: id, UserID: userId}); err != nil {
+		return fmt.Errorf("mark blob stored: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (db *PostgresDB) GetStaleUploads(ctx context.Context, cutoff time.Time, limit int) ([]fs.Metadata, error) {
+	rows, err := db.Queries.ListStaleUploads(ctx, ListStaleUploadsParams{UploadedAt: cutoff, Limit: int32(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]fs.Metadata, len(rows))
+	for i, row := range rows {
+		files[i] = toMetadata(row)
+	}
+
+	return files, nil
+}
+
+func (db *PostgresDB) GetBlob(ctx context.Context, sha256 string) (*fs.Blob, error) {
+	b, err := db.Queries.GetBlob(ctx, sha256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fs.Blob{SHA256: b.Sha256, Bucket: b.Bucket, Refs: int(b.Refs), Stored: b.Stored}, nil
+}
+
+func (db *PostgresDB) UpdateCaption(ctx context.Context, id, userId, caption string) error {
+	params := UpdateCaptionParams{
+		Caption: caption,
+		ID:      id,
+		UserID:  userId,
+	}
+
+	n, err := db.Queries.UpdateCaption(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *PostgresDB) Delete(ctx context.Context, id, userId string) (bool, error) {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	row, err := qtx.DeleteMetadata(ctx, DeleteMetadataParams{ID: id, UserID: userId})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// Files from before blobs own their objects outright.
+	last := true
+	if row.Sha256 != "" && row.ObjectName == row.Sha256 {
+		refs, err := qtx.ReleaseBlob(ctx, row.Sha256)
+		if err != nil {
+			return false, fmt.Errorf("release blob: %w", err)
+		}
+
+		last = refs <= 0
+		if last {
+			if err := qtx.DeleteBlob(ctx, row.Sha256); err != nil {
+				return false, fmt.Errorf("delete blob: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return last, nil
+}
+
+func toMetadata(m Metadata) fs.Metadata {
+	return fs.Metadata{
+		Id:                m.ID,
+		Filename:          m.FileName,
+		Thumbname:         m.ThumbName,
+		UserId:            m.UserID,
+		ContentType:       m.ContentType,
+		Size:              m.Size,
+		UploadedAt:        m.UploadedAt,
+		Width:             int(m.Width),
+		Height:            int(m.Height),
+		Duration:          m.Duration,
+		SHA256:            m.Sha256,
+		TakenAt:           timePtr(m.TakenAt),
+		Caption:           m.Caption,
+		DeletedAt:         timePtr(m.DeletedAt),
+		Pending:           m.Pending,
+		Bucket:            m.Bucket,
+		Object:            m.ObjectName,
+		WrappedKey:        m.WrappedKey,
+		EncryptedMetadata: m.EncryptedMetadata,
+	}
+}
+
+func toSaveExifParams(fileId string, e *fs.Exif) SaveExifParams {
+	return SaveExifParams{
+		FileID:       fileId,
+		CameraMake:   e.CameraMake,
+		CameraModel:  e.CameraModel,
+		LensModel:    e.LensModel,
+		Orientation:  int32(e.Orientation),
+		ExposureTime: e.ExposureTime,
+		FNumber:      e.FNumber,
+		Iso:          int32(e.ISO),
+		FocalLength:  e.FocalLength,
+		Latitude:     nullFloat64(e.Latitude),
+		Longitude:    nullFloat64(e.Longitude),
+	}
+}
+
+func toExif(e Exif) *fs.Exif {
+	return &fs.Exif{
+		CameraMake:   e.CameraMake,
+		CameraModel:  e.CameraModel,
+		LensModel:    e.LensModel,
+		Orientation:  int(e.Orientation),
+		ExposureTime: e.ExposureTime,
+		FNumber:      e.FNumber,
+		ISO:          int(e.Iso),
+		FocalLength:  e.FocalLength,
+		Latitude:     float64Ptr(e.Latitude),
+		Longitude:    float64Ptr(e.Longitude),
+	}
+}
+
+// isUniqueViolation reports a unique_violation, SQLSTATE 23505.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func nullFloat64(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+func float64Ptr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+
+	return &f.Float64
+}
@@ -0,0 +1,3 @@
+DROP TABLE exif;
+DROP TABLE metadata;
+DROP TABLE users;
@@ -0,0 +1,37 @@
+CREATE TABLE users (
+		id TEXT NOT NULL PRIMARY KEY,
+		email TEXT NOT NULL UNIQUE,
+		bucket TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE metadata (
+		id TEXT NOT NULL PRIMARY KEY,
+		file_name TEXT NOT NULL,
+		thumb_name TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		content_type TEXT NOT NULL DEFAULT '',
+		size BIGINT NOT NULL DEFAULT 0,
+		uploaded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		width INTEGER NOT NULL DEFAULT 0,
+		height INTEGER NOT NULL DEFAULT 0,
+		duration DOUBLE PRECISION NOT NULL DEFAULT 0,
+		sha256 TEXT NOT NULL DEFAULT '',
+		taken_at TIMESTAMPTZ,
+		UNIQUE (file_name, user_id)
+);
+
+CREATE INDEX metadata_timeline_idx ON metadata (user_id, (COALESCE(taken_at, uploaded_at)) DESC);
+
+CREATE TABLE exif (
+		file_id TEXT NOT NULL PRIMARY KEY REFERENCES metadata (id) ON DELETE CASCADE,
+		camera_make TEXT NOT NULL,
+		camera_model TEXT NOT NULL,
+		lens_model TEXT NOT NULL,
+		orientation INTEGER NOT NULL,
+		exposure_time TEXT NOT NULL,
+		f_number DOUBLE PRECISION NOT NULL,
+		iso INTEGER NOT NULL,
+		focal_length DOUBLE PRECISION NOT NULL,
+		latitude DOUBLE PRECISION,
+		longitude DOUBLE PRECISION
+);
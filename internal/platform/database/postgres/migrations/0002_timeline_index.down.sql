@@ -0,0 +1,4 @@
+DROP INDEX metadata_uploaded_idx;
+DROP INDEX metadata_taken_idx;
+
+CREATE INDEX metadata_timeline_idx ON metadata (user_id, (COALESCE(taken_at, uploaded_at)) DESC);
@@ -0,0 +1,4 @@
+DROP INDEX metadata_timeline_idx;
+
+CREATE INDEX metadata_taken_idx ON metadata (user_id, (COALESCE(taken_at, uploaded_at)) DESC, id DESC);
+CREATE INDEX metadata_uploaded_idx ON metadata (user_id, uploaded_at DESC, id DESC);
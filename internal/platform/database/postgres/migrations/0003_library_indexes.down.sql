@@ -0,0 +1,3 @@
+DROP INDEX metadata_content_type_idx;
+DROP INDEX metadata_size_idx;
+DROP INDEX metadata_name_idx;
@@ -0,0 +1,7 @@
+DROP TRIGGER exif_search_refresh ON exif;
+DROP FUNCTION exif_search_trigger();
+DROP TRIGGER metadata_search_refresh ON metadata;
+DROP FUNCTION metadata_search_trigger();
+DROP FUNCTION refresh_metadata_search(TEXT);
+DROP TABLE metadata_search;
+ALTER TABLE metadata DROP COLUMN caption;
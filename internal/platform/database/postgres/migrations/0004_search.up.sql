@@ -0,0 +1,54 @@
+ALTER TABLE metadata ADD COLUMN caption TEXT NOT NULL DEFAULT '';
+
+-- body is the text results are highlighted in, document is what is matched.
+-- Both are derived from metadata and exif by refresh_metadata_search.
+CREATE TABLE metadata_search (
+		file_id TEXT NOT NULL PRIMARY KEY REFERENCES metadata (id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		document TSVECTOR NOT NULL
+);
+
+CREATE INDEX metadata_search_document_idx ON metadata_search USING GIN (document);
+
+CREATE FUNCTION refresh_metadata_search(target TEXT) RETURNS void AS $$
+	INSERT INTO metadata_search (file_id, body, document)
+	SELECT
+		m.id,
+		concat_ws(' ', m.file_name, m.caption, e.camera_make, e.camera_model, e.lens_model),
+		setweight(to_tsvector('simple', regexp_replace(m.file_name, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+		setweight(to_tsvector('simple', m.caption), 'A') ||
+		setweight(to_tsvector('simple', concat_ws(' ', e.camera_make, e.camera_model, e.lens_model)), 'C') ||
+		setweight(to_tsvector('simple', to_char(COALESCE(m.taken_at, m.uploaded_at) AT TIME ZONE 'UTC', 'YYYY MM DD')), 'D')
+	FROM metadata m
+	LEFT JOIN exif e ON e.file_id = m.id
+	WHERE m.id = target
+	ON CONFLICT (file_id) DO UPDATE SET body = EXCLUDED.body, document = EXCLUDED.document;
+$$ LANGUAGE sql;
+
+CREATE FUNCTION metadata_search_trigger() RETURNS trigger AS $$
+BEGIN
+	PERFORM refresh_metadata_search(NEW.id);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER metadata_search_refresh
+AFTER INSERT OR UPDATE OF file_name, caption, taken_at, uploaded_at ON metadata
+FOR EACH ROW EXECUTE FUNCTION metadata_search_trigger();
+
+CREATE FUNCTION exif_search_trigger() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM refresh_metadata_search(OLD.file_id);
+	ELSE
+		PERFORM refresh_metadata_search(NEW.file_id);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER exif_search_refresh
+AFTER INSERT OR UPDATE OR DELETE ON exif
+FOR EACH ROW EXECUTE FUNCTION exif_search_trigger();
+
+SELECT refresh_metadata_search(id) FROM metadata;
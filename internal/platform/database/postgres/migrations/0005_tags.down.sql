@@ -0,0 +1,21 @@
+DROP TRIGGER file_tags_search_refresh ON file_tags;
+DROP FUNCTION file_tags_search_trigger();
+DROP TABLE file_tags;
+DROP TABLE tags;
+
+CREATE OR REPLACE FUNCTION refresh_metadata_search(target TEXT) RETURNS void AS $$
+	INSERT INTO metadata_search (file_id, body, document)
+	SELECT
+		m.id,
+		concat_ws(' ', m.file_name, m.caption, e.camera_make, e.camera_model, e.lens_model),
+		setweight(to_tsvector('simple', regexp_replace(m.file_name, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+		setweight(to_tsvector('simple', m.caption), 'A') ||
+		setweight(to_tsvector('simple', concat_ws(' ', e.camera_make, e.camera_model, e.lens_model)), 'C') ||
+		setweight(to_tsvector('simple', to_char(COALESCE(m.taken_at, m.uploaded_at) AT TIME ZONE 'UTC', 'YYYY MM DD')), 'D')
+	FROM metadata m
+	LEFT JOIN exif e ON e.file_id = m.id
+	WHERE m.id = target
+	ON CONFLICT (file_id) DO UPDATE SET body = EXCLUDED.body, document = EXCLUDED.document;
+$$ LANGUAGE sql;
+
+SELECT refresh_metadata_search(id) FROM metadata;
@@ -0,0 +1,51 @@
+CREATE TABLE tags (
+		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		UNIQUE (user_id, name)
+);
+
+CREATE TABLE file_tags (
+		file_id TEXT NOT NULL REFERENCES metadata (id) ON DELETE CASCADE,
+		tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (file_id, tag_id)
+);
+
+CREATE INDEX file_tags_tag_idx ON file_tags (tag_id, file_id);
+
+CREATE OR REPLACE FUNCTION refresh_metadata_search(target TEXT) RETURNS void AS $$
+	INSERT INTO metadata_search (file_id, body, document)
+	SELECT
+		m.id,
+		concat_ws(' ', m.file_name, m.caption, tg.names, e.camera_make, e.camera_model, e.lens_model),
+		setweight(to_tsvector('simple', regexp_replace(m.file_name, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+		setweight(to_tsvector('simple', m.caption), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(tg.names, '')), 'B') ||
+		setweight(to_tsvector('simple', concat_ws(' ', e.camera_make, e.camera_model, e.lens_model)), 'C') ||
+		setweight(to_tsvector('simple', to_char(COALESCE(m.taken_at, m.uploaded_at) AT TIME ZONE 'UTC', 'YYYY MM DD')), 'D')
+	FROM metadata m
+	LEFT JOIN exif e ON e.file_id = m.id
+	LEFT JOIN LATERAL (
+		SELECT string_agg(t.name, ' ' ORDER BY t.name) AS names
+		FROM file_tags ft
+		JOIN tags t ON t.id = ft.tag_id
+		WHERE ft.file_id = m.id
+	) tg ON true
+	WHERE m.id = target
+	ON CONFLICT (file_id) DO UPDATE SET body = EXCLUDED.body, document = EXCLUDED.document;
+$$ LANGUAGE sql;
+
+CREATE FUNCTION file_tags_search_trigger() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM refresh_metadata_search(OLD.file_id);
+	ELSE
+		PERFORM refresh_metadata_search(NEW.file_id);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER file_tags_search_refresh
+AFTER INSERT OR DELETE ON file_tags
+FOR EACH ROW EXECUTE FUNCTION file_tags_search_trigger();
@@ -0,0 +1,30 @@
+DROP TRIGGER albums_search_refresh ON albums;
+DROP FUNCTION albums_search_trigger();
+DROP TRIGGER album_files_search_refresh ON album_files;
+DROP FUNCTION album_files_search_trigger();
+DROP TABLE album_files;
+DROP TABLE albums;
+
+CREATE OR REPLACE FUNCTION refresh_metadata_search(target TEXT) RETURNS void AS $$
+	INSERT INTO metadata_search (file_id, body, document)
+	SELECT
+		m.id,
+		concat_ws(' ', m.file_name, m.caption, tg.names, e.camera_make, e.camera_model, e.lens_model),
+		setweight(to_tsvector('simple', regexp_replace(m.file_name, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+		setweight(to_tsvector('simple', m.caption), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(tg.names, '')), 'B') ||
+		setweight(to_tsvector('simple', concat_ws(' ', e.camera_make, e.camera_model, e.lens_model)), 'C') ||
+		setweight(to_tsvector('simple', to_char(COALESCE(m.taken_at, m.uploaded_at) AT TIME ZONE 'UTC', 'YYYY MM DD')), 'D')
+	FROM metadata m
+	LEFT JOIN exif e ON e.file_id = m.id
+	LEFT JOIN LATERAL (
+		SELECT string_agg(t.name, ' ' ORDER BY t.name) AS names
+		FROM file_tags ft
+		JOIN tags t ON t.id = ft.tag_id
+		WHERE ft.file_id = m.id
+	) tg ON true
+	WHERE m.id = target
+	ON CONFLICT (file_id) DO UPDATE SET body = EXCLUDED.body, document = EXCLUDED.document;
+$$ LANGUAGE sql;
+
+SELECT refresh_metadata_search(id) FROM metadata;
@@ -0,0 +1,75 @@
+CREATE TABLE albums (
+		id TEXT NOT NULL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		cover_file_id TEXT REFERENCES metadata (id) ON DELETE SET NULL,
+		created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX albums_user_idx ON albums (user_id, created_at);
+
+-- Files are shared by reference, an album never copies the object.
+CREATE TABLE album_files (
+		album_id TEXT NOT NULL REFERENCES albums (id) ON DELETE CASCADE,
+		file_id TEXT NOT NULL REFERENCES metadata (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		PRIMARY KEY (album_id, file_id)
+);
+
+CREATE INDEX album_files_position_idx ON album_files (album_id, position);
+CREATE INDEX album_files_file_idx ON album_files (file_id);
+
+CREATE OR REPLACE FUNCTION refresh_metadata_search(target TEXT) RETURNS void AS $$
+	INSERT INTO metadata_search (file_id, body, document)
+	SELECT
+		m.id,
+		concat_ws(' ', m.file_name, m.caption, tg.names, al.names, e.camera_make, e.camera_model, e.lens_model),
+		setweight(to_tsvector('simple', regexp_replace(m.file_name, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+		setweight(to_tsvector('simple', m.caption), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(tg.names, '')), 'B') ||
+		setweight(to_tsvector('simple', COALESCE(al.names, '')), 'B') ||
+		setweight(to_tsvector('simple', concat_ws(' ', e.camera_make, e.camera_model, e.lens_model)), 'C') ||
+		setweight(to_tsvector('simple', to_char(COALESCE(m.taken_at, m.uploaded_at) AT TIME ZONE 'UTC', 'YYYY MM DD')), 'D')
+	FROM metadata m
+	LEFT JOIN exif e ON e.file_id = m.id
+	LEFT JOIN LATERAL (
+		SELECT string_agg(t.name, ' ' ORDER BY t.name) AS names
+		FROM file_tags ft
+		JOIN tags t ON t.id = ft.tag_id
+		WHERE ft.file_id = m.id
+	) tg ON true
+	LEFT JOIN LATERAL (
+		SELECT string_agg(a.name, ' ' ORDER BY a.name) AS names
+		FROM album_files af
+		JOIN albums a ON a.id = af.album_id
+		WHERE af.file_id = m.id
+	) al ON true
+	WHERE m.id = target
+	ON CONFLICT (file_id) DO UPDATE SET body = EXCLUDED.body, document = EXCLUDED.document;
+$$ LANGUAGE sql;
+
+CREATE FUNCTION album_files_search_trigger() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM refresh_metadata_search(OLD.file_id);
+	ELSE
+		PERFORM refresh_metadata_search(NEW.file_id);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER album_files_search_refresh
+AFTER INSERT OR DELETE ON album_files
+FOR EACH ROW EXECUTE FUNCTION album_files_search_trigger();
+
+CREATE FUNCTION albums_search_trigger() RETURNS trigger AS $$
+BEGIN
+	PERFORM refresh_metadata_search(file_id) FROM album_files WHERE album_id = NEW.id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER albums_search_refresh
+AFTER UPDATE OF name ON albums
+FOR EACH ROW EXECUTE FUNCTION albums_search_trigger();
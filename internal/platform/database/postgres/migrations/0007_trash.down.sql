@@ -0,0 +1,3 @@
+DROP INDEX metadata_expired_trash_idx;
+DROP INDEX metadata_trash_idx;
+ALTER TABLE metadata DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE metadata ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX metadata_trash_idx ON metadata (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX metadata_expired_trash_idx ON metadata (deleted_at) WHERE deleted_at IS NOT NULL;
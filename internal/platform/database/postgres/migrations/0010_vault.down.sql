@@ -0,0 +1,3 @@
+ALTER TABLE metadata DROP COLUMN encrypted_metadata;
+ALTER TABLE metadata DROP COLUMN wrapped_key;
+DROP TABLE vaults;
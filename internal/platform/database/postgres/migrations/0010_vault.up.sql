@@ -0,0 +1,15 @@
+-- A vault holds what a client needs to derive the key its files are sealed
+-- with from the user's passphrase. The server only ever sees wrapped keys.
+CREATE TABLE vaults (
+		user_id TEXT NOT NULL PRIMARY KEY,
+		kdf TEXT NOT NULL,
+		kdf_params TEXT NOT NULL,
+		wrapped_key TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Vault files keep their key, wrapped by the vault key, and their real
+-- metadata, sealed by the client, next to the rest of the row.
+ALTER TABLE metadata ADD COLUMN wrapped_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE metadata ADD COLUMN encrypted_metadata TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Fails if a trashed file shares its name with another file, as the old
+-- constraint would not allow that.
+DROP INDEX metadata_file_name_idx;
+ALTER TABLE metadata ADD CONSTRAINT metadata_file_name_user_id_key UNIQUE (file_name, user_id);
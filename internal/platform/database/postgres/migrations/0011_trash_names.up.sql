@@ -0,0 +1,4 @@
+-- A trashed file gives up its name, so the name can be uploaded again while
+-- the file waits to be purged.
+ALTER TABLE metadata DROP CONSTRAINT metadata_file_name_user_id_key;
+CREATE UNIQUE INDEX metadata_file_name_idx ON metadata (file_name, user_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/portbound/go-fs/internal/platform/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+const DriverName string = "pgx"
+
+// migrationLockId is an arbitrary key for the advisory lock replicas take
+// while migrating, so only the first one to start applies anything.
+const migrationLockId = 7265340
+
+type PostgresDB struct {
+	*Queries
+	Conn *database.DBConnection
+}
+
+func NewPostgresDB(connStr string) (*PostgresDB, error) {
+	conn, err := database.NewDBConnection(ConnectionDetails(connStr))
+	if err != nil {
+		return nil, fmt.Errorf("create new postgres connection: %w", err)
+	}
+	return &PostgresDB{Queries: New(conn.DB), Conn: conn}, nil
+}
+
+func ConnectionDetails(connStr string) *database.DBConnectionDetails {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	return &database.DBConnectionDetails{
+		DriverName: DriverName,
+		ConnStr:    connStr,
+		Migrations: sub,
+		MigrationLock: &database.MigrationLock{
+			Acquire: fmt.Sprintf("SELECT pg_advisory_lock(%d)", migrationLockId),
+			Release: fmt.Sprintf("SELECT pg_advisory_unlock(%d)", migrationLockId),
+		},
+	}
+}
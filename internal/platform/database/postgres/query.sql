@@ -0,0 +1,280 @@
+-- name: GetUser :one
+SELECT * FROM users 
+WHERE email = $1 LIMIT 1;
+
+-- name: SaveMetadata :exec
+INSERT INTO metadata (
+	id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, pending, bucket, object_name, wrapped_key, encrypted_metadata
+) VALUES (
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+);
+
+-- name: SaveExif :exec
+INSERT INTO exif (
+	file_id, camera_make, camera_model, lens_model, orientation, exposure_time, f_number, iso, focal_length, latitude, longitude
+) VALUES (
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+);
+
+-- name: GetMetadata :one
+SELECT * FROM metadata 
+WHERE id = $1 
+AND user_id = $2
+AND NOT pending
+AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetExif :one
+SELECT * FROM exif
+WHERE file_id = $1 LIMIT 1;
+
+-- name: UpdateCaption :execrows
+UPDATE metadata SET caption = $1
+WHERE id = $2
+AND user_id = $3
+AND deleted_at IS NULL
+AND NOT pending;
+
+-- name: UpsertTag :one
+INSERT INTO tags (user_id, name) VALUES ($1, $2)
+ON CONFLICT (user_id, name) DO UPDATE SET name = excluded.name
+RETURNING id;
+
+-- name: AddFileTag :exec
+INSERT INTO file_tags (file_id, tag_id) VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveFileTag :exec
+DELETE FROM file_tags
+WHERE file_id = $1
+AND tag_id IN (SELECT id FROM tags WHERE user_id = $2 AND name = $3);
+
+-- name: DeleteUnusedTags :exec
+DELETE FROM tags
+WHERE user_id = $1
+AND NOT EXISTS (SELECT 1 FROM file_tags WHERE file_tags.tag_id = tags.id);
+
+-- name: ListTags :many
+SELECT t.name, COUNT(*) AS file_count FROM tags t
+JOIN file_tags ft ON ft.tag_id = t.id
+JOIN metadata m ON m.id = ft.file_id
+WHERE t.user_id = $1
+AND m.deleted_at IS NULL
+GROUP BY t.id, t.name
+ORDER BY t.name;
+
+-- name: ListFileTags :many
+SELECT t.name FROM tags t
+JOIN file_tags ft ON ft.tag_id = t.id
+WHERE ft.file_id = $1
+ORDER BY t.name;
+
+-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE user_id = $1
+AND name = $2;
+
+-- name: CreateAlbum :exec
+INSERT INTO albums (id, user_id, name, created_at) VALUES ($1, $2, $3, $4);
+
+-- name: GetAlbum :one
+SELECT
+	a.id,
+	a.user_id,
+	a.name,
+	CAST(COALESCE(
+		(SELECT m.id FROM metadata m WHERE m.id = a.cover_file_id AND m.deleted_at IS NULL),
+		(SELECT af.file_id FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL ORDER BY af.position LIMIT 1),
+		''
+	) AS TEXT) AS cover_id,
+	(SELECT COUNT(*) FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL) AS file_count,
+	a.created_at
+FROM albums a
+WHERE a.id = $1
+AND a.user_id = $2;
+
+-- name: ListAlbums :many
+SELECT
+	a.id,
+	a.user_id,
+	a.name,
+	CAST(COALESCE(
+		(SELECT m.id FROM metadata m WHERE m.id = a.cover_file_id AND m.deleted_at IS NULL),
+		(SELECT af.file_id FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL ORDER BY af.position LIMIT 1),
+		''
+	) AS TEXT) AS cover_id,
+	(SELECT COUNT(*) FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL) AS file_count,
+	a.created_at
+FROM albums a
+WHERE a.user_id = $1
+ORDER BY a.created_at DESC, a.id;
+
+-- name: RenameAlbum :execrows
+UPDATE albums SET name = $1
+WHERE id = $2
+AND user_id = $3;
+
+-- name: SetAlbumCover :execrows
+UPDATE albums SET cover_file_id = $1
+WHERE id = $2
+AND user_id = $3;
+
+-- name: ClearRemovedAlbumCover :exec
+UPDATE albums SET cover_file_id = NULL
+WHERE id = $1
+AND NOT EXISTS (SELECT 1 FROM album_files af WHERE af.album_id = albums.id AND af.file_id = albums.cover_file_id);
+
+-- name: DeleteAlbum :execrows
+DELETE FROM albums
+WHERE id = $1
+AND user_id = $2;
+
+-- name: NextAlbumPosition :one
+SELECT CAST(COALESCE(MAX(position), -1) + 1 AS INTEGER) FROM album_files
+WHERE album_id = $1;
+
+-- name: AddAlbumFile :exec
+INSERT INTO album_files (album_id, file_id, position) VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveAlbumFile :exec
+DELETE FROM album_files
+WHERE album_id = $1
+AND file_id = $2;
+
+-- name: ListAlbumFileIDs :many
+SELECT af.file_id FROM album_files af
+JOIN metadata m ON m.id = af.file_id
+WHERE af.album_id = $1
+AND m.deleted_at IS NULL
+ORDER BY af.position;
+
+-- name: SetAlbumFilePosition :exec
+UPDATE album_files SET position = $1
+WHERE album_id = $2
+AND file_id = $3;
+
+-- name: ListAlbumFiles :many
+SELECT m.id, m.file_name, m.thumb_name, m.user_id, m.content_type, m.size, m.uploaded_at, m.width, m.height, m.duration, m.sha256, m.taken_at, m.caption, m.deleted_at, m.pending, m.bucket, m.object_name, m.wrapped_key, m.encrypted_metadata FROM metadata m
+JOIN album_files af ON af.file_id = m.id
+WHERE af.album_id = $1
+AND m.deleted_at IS NULL
+ORDER BY af.position;
+
+-- name: TrashMetadata :execrows
+UPDATE metadata SET deleted_at = $1
+WHERE id = $2
+AND user_id = $3
+AND deleted_at IS NULL
+AND NOT pending;
+
+-- name: RestoreMetadata :execrows
+UPDATE metadata SET deleted_at = NULL
+WHERE id = $1
+AND user_id = $2
+AND deleted_at IS NOT NULL;
+
+-- name: ListTrash :many
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata
+WHERE user_id = $1
+AND deleted_at IS NOT NULL
+AND NOT pending
+ORDER BY deleted_at DESC, id;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY id;
+
+-- name: CommitUpload :execrows
+UPDATE metadata SET pending = FALSE
+WHERE id = $1
+AND user_id = $2
+AND pending;
+
+-- name: DeleteMetadata :one
+DELETE FROM metadata
+WHERE id = $1
+AND user_id = $2
+RETURNING object_name, sha256;
+
+-- name: ListExpiredTrash :many
+SELECT * FROM metadata
+WHERE deleted_at IS NOT NULL
+AND deleted_at < $1
+ORDER BY deleted_at, id
+LIMIT $2;
+
+-- name: ListInventory :many
+SELECT * FROM metadata
+WHERE bucket = $1
+ORDER BY id;
+
+-- name: ListStaleUploads :many
+SELECT * FROM metadata
+WHERE pending
+AND uploaded_at < $1
+ORDER BY uploaded_at, id
+LIMIT $2;
+
+-- name: AcquireBlob :one
+INSERT INTO blobs (sha256, bucket, refs) VALUES ($1, $2, 1)
+ON CONFLICT (sha256) DO UPDATE SET refs = blobs.refs + 1
+RETURNING bucket;
+
+-- name: ReleaseBlob :one
+UPDATE blobs SET refs = refs - 1
+WHERE sha256 = $1
+RETURNING refs;
+
+-- name: DeleteBlob :exec
+DELETE FROM blobs
+WHERE sha256 = $1
+AND refs <= 0;
+
+-- name: GetBlob :one
+SELECT * FROM blobs
+WHERE sha256 = $1;
+
+-- name: MarkBlobStored :exec
+UPDATE blobs SET stored = TRUE
+WHERE sha256 = (
+	SELECT sha256 FROM metadata
+	WHERE id = $1
+	AND user_id = $2
+	AND object_name = sha256
+);
+
+-- name: GetVault :one
+SELECT * FROM vaults
+WHERE user_id = $1;
+
+-- name: GetPendingMetadata :one
+SELECT * FROM metadata 
+WHERE id = $1 
+AND user_id = $2
+AND pending LIMIT 1;
+
+-- name: DeletePendingMetadata :execrows
+DELETE FROM metadata
+WHERE id = $1
+AND user_id = $2
+AND pending;
+
+-- name: CreateVault :exec
+INSERT INTO vaults (user_id, kdf, kdf_params, wrapped_key, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: RewrapVault :execrows
+UPDATE vaults SET
+	kdf = sqlc.arg(kdf),
+	kdf_params = sqlc.arg(kdf_params),
+	wrapped_key = sqlc.arg(wrapped_key),
+	updated_at = sqlc.arg(updated_at)
+WHERE user_id = sqlc.arg(user_id)
+AND wrapped_key = sqlc.arg(previous_wrapped_key);
+
+-- name: GetTrashedMetadata :one
+SELECT * FROM metadata 
+WHERE id = $1 
+AND user_id = $2
+AND NOT pending
+AND deleted_at IS NOT NULL LIMIT 1;
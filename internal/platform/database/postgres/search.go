@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=1, MaxWords=16, MinWords=4", fs.HighlightStart, fs.HighlightEnd)
+
+func (db *PostgresDB) Search(ctx context.Context, userId, query string, limit, offset int) ([]fs.SearchResult, error) {
+	tsquery := prefixQuery(query)
+	if tsquery == "" {
+		return nil, nil
+	}
+
+	rows, err := db.Conn.DB.QueryContext(ctx, `SELECT `+prefixColumns("m", metadataColumns)+`,
+		ts_headline('simple', s.body, q, $1),
+		ts_rank(s.document, q)
+		FROM metadata_search s
+		JOIN metadata m ON m.id = s.file_id,
+		to_tsquery('simple', $2) q
+		WHERE s.document @@ q AND m.user_id = $3 AND m.deleted_at IS NULL AND NOT m.pending
+		ORDER BY ts_rank(s.document, q) DESC, m.id
+		LIMIT $4 OFFSET $5`,
+		headlineOptions, tsquery, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []fs.SearchResult
+	for rows.Next() {
+		var m Metadata
+		var r fs.SearchResult
+		if err := rows.Scan(
+			&m.ID,
+			&m.FileName,
+			&m.ThumbName,
+			&m.UserID,
+			&m.ContentType,
+			&m.Size,
+			&m.UploadedAt,
+			&m.Width,
+			&m.Height,
+			&m.Duration,
+			&m.Sha256,
+			&m.TakenAt,
+			&m.Caption,
+			&m.DeletedAt,
+			&m.WrappedKey,
+			&m.EncryptedMetadata,
+			&r.Snippet,
+			&r.Rank,
+		); err != nil {
+			return nil, err
+		}
+		r.Metadata = toMetadata(m)
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// prefixQuery turns what a user typed into a tsquery that matches every word
+// as a prefix, so "beach 2023" finds "Beaches" taken in 2023. Everything but
+// letters and digits is dropped, which keeps tsquery syntax out.
+func prefixQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = w + ":*"
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+func prefixColumns(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+
+	return strings.Join(cols, ", ")
+}
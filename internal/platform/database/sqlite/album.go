@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/portbound/go-fs/internal/album"
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+func (db *SQLiteDB) CreateAlbum(ctx context.Context, a *album.Album) error {
+	params := CreateAlbumParams{
+		ID:        a.Id,
+		UserID:    a.UserId,
+		Name:      a.Name,
+		CreatedAt: a.CreatedAt,
+	}
+
+	return db.Queries.CreateAlbum(ctx, params)
+}
+
+func (db *SQLiteDB) GetAlbum(ctx context.Context, albumId, userId string) (*album.Album, error) {
+	row, err := db.Queries.GetAlbum(ctx, GetAlbumParams{ID: albumId, UserID: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	a := toAlbum(ListAlbumsRow(row))
+	return &a, nil
+}
+
+func (db *SQLiteDB) GetAlbums(ctx context.Context, userId string) ([]album.Album, error) {
+	rows, err := db.Queries.ListAlbums(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	albums := make([]album.Album, len(rows))
+	for i, row := range rows {
+		albums[i] = toAlbum(row)
+	}
+
+	return albums, nil
+}
+
+func (db *SQLiteDB) RenameAlbum(ctx context.Context, albumId, userId, name string) error {
+	n, err := db.Queries.RenameAlbum(ctx, RenameAlbumParams{Name: name, ID: albumId, UserID: userId})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) SetAlbumCover(ctx context.Context, albumId, userId, fileId string) error {
+	if fileId != "" {
+		ids, err := albumFileIds(ctx, db.Queries, albumId, userId)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(ids, fileId) {
+			return fmt.Errorf("%w: %q", album.ErrNotInAlbum, fileId)
+		}
+	}
+
+	params := SetAlbumCoverParams{
+		CoverFileID: sql.NullString{String: fileId, Valid: fileId != ""},
+		ID:          albumId,
+		UserID:      userId,
+	}
+
+	n, err := db.Queries.SetAlbumCover(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) DeleteAlbum(ctx context.Context, albumId, userId string) error {
+	n, err := db.Queries.DeleteAlbum(ctx, DeleteAlbumParams{ID: albumId, UserID: userId})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateAlbumFiles(ctx context.Context, albumId, userId string, add, remove []string) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	if _, err := qtx.GetAlbum(ctx, GetAlbumParams{ID: albumId, UserID: userId}); err != nil {
+		return fmt.Errorf("get album: %w", err)
+	}
+
+	position, err := qtx.NextAlbumPosition(ctx, albumId)
+	if err != nil {
+		return fmt.Errorf("get next position: %w", err)
+	}
+
+	for _, id := range add {
+		if _, err := qtx.GetMetadata(ctx, GetMetadataParams{ID: id, UserID: userId}); err != nil {
+			return fmt.Errorf("get file %q: %w", id, err)
+		}
+
+		if err := qtx.AddAlbumFile(ctx, AddAlbumFileParams{AlbumID: albumId, FileID: id, Position: position}); err != nil {
+			return fmt.Errorf("add file %q: %w", id, err)
+		}
+		position++
+	}
+
+	for _, id := range remove {
+		if err := qtx.RemoveAlbumFile(ctx, RemoveAlbumFileParams{AlbumID: albumId, FileID: id}); err != nil {
+			return fmt.Errorf("remove file %q: %w", id, err)
+		}
+	}
+
+	if len(remove) > 0 {
+		if err := qtx.ClearRemovedAlbumCover(ctx, albumId); err != nil {
+			return fmt.Errorf("clear cover: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) ReorderAlbum(ctx context.Context, albumId, userId string, fileIds []string) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	current, err := albumFileIds(ctx, qtx, albumId, userId)
+	if err != nil {
+		return err
+	}
+
+	if len(current) != len(fileIds) {
+		return fmt.Errorf("%w: the album has %d files, %d were ordered", album.ErrNotInAlbum, len(current), len(fileIds))
+	}
+
+	for i, id := range fileIds {
+		if !slices.Contains(current, id) {
+			return fmt.Errorf("%w: %q", album.ErrNotInAlbum, id)
+		}
+
+		if err := qtx.SetAlbumFilePosition(ctx, SetAlbumFilePositionParams{Position: int64(i), AlbumID: albumId, FileID: id}); err != nil {
+			return fmt.Errorf("move file %q: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) GetAlbumFiles(ctx context.Context, albumId, userId string) ([]fs.Metadata, error) {
+	if _, err := db.Queries.GetAlbum(ctx, GetAlbumParams{ID: albumId, UserID: userId}); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Queries.ListAlbumFiles(ctx, albumId)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]fs.Metadata, len(rows))
+	for i, row := range rows {
+		files[i] = toMetadata(row)
+	}
+
+	return files, nil
+}
+
+// albumFileIds returns the files in an album in order, after checking the
+// album is the user's.
+func albumFileIds(ctx context.Context, q *Queries, albumId, userId string) ([]string, error) {
+	if _, err := q.GetAlbum(ctx, GetAlbumParams{ID: albumId, UserID: userId}); err != nil {
+		return nil, err
+	}
+
+	return q.ListAlbumFileIDs(ctx, albumId)
+}
+
+func toAlbum(row ListAlbumsRow) album.Album {
+	return album.Album{
+		Id:        row.ID,
+		UserId:    row.UserID,
+		Name:      row.Name,
+		CoverId:   row.CoverID,
+		FileCount: row.FileCount,
+		CreatedAt: row.CreatedAt,
+	}
+}
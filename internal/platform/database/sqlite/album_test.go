@@ -0,0 +1,140 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/album"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+func TestSQLiteDB_Albums(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	for _, m := range []fs.Metadata{
+		{Id: "f1", Filename: "a.jpg", UserId: "u1", UploadedAt: time.Now()},
+		{Id: "f2", Filename: "b.jpg", UserId: "u1", UploadedAt: time.Now()},
+		{Id: "f3", Filename: "c.jpg", UserId: "u1", UploadedAt: time.Now()},
+		{Id: "other", Filename: "d.jpg", UserId: "u2", UploadedAt: time.Now()},
+	} {
+		if err := db.Save(ctx, &m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, a := range []album.Album{
+		{Id: "a1", UserId: "u1", Name: "Hawaii", CreatedAt: created},
+		{Id: "a2", UserId: "u1", Name: "Best of", CreatedAt: created.Add(time.Hour)},
+	} {
+		if err := db.CreateAlbum(ctx, &a); err != nil {
+			t.Fatalf("CreateAlbum(%s): %v", a.Id, err)
+		}
+	}
+
+	fileIds := func(albumId string) []string {
+		t.Helper()
+		files, err := db.GetAlbumFiles(ctx, albumId, "u1")
+		if err != nil {
+			t.Fatalf("GetAlbumFiles(%s): %v", albumId, err)
+		}
+		var ids []string
+		for _, f := range files {
+			ids = append(ids, f.Id)
+		}
+		return ids
+	}
+
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", []string{"f2", "f1"}, nil); err != nil {
+		t.Fatalf("UpdateAlbumFiles(): %v", err)
+	}
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", []string{"f3", "f2"}, nil); err != nil {
+		t.Fatalf("UpdateAlbumFiles() again: %v", err)
+	}
+	if got := fileIds("a1"); !slices.Equal(got, []string{"f2", "f1", "f3"}) {
+		t.Errorf("album files = %v, want files appended once in order", got)
+	}
+
+	// The same file can be in several albums.
+	if err := db.UpdateAlbumFiles(ctx, "a2", "u1", []string{"f2"}, nil); err != nil {
+		t.Fatalf("UpdateAlbumFiles(a2): %v", err)
+	}
+
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", []string{"other"}, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("adding another user's file err = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u2", []string{"other"}, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("changing another user's album err = %v, want sql.ErrNoRows", err)
+	}
+
+	a, err := db.GetAlbum(ctx, "a1", "u1")
+	if err != nil || a.CoverId != "f2" || a.FileCount != 3 {
+		t.Errorf("GetAlbum() = %+v, %v, want the first file as cover and 3 files", a, err)
+	}
+
+	if err := db.SetAlbumCover(ctx, "a1", "u1", "f3"); err != nil {
+		t.Fatalf("SetAlbumCover(): %v", err)
+	}
+	if err := db.SetAlbumCover(ctx, "a2", "u1", "f3"); !errors.Is(err, album.ErrNotInAlbum) {
+		t.Errorf("SetAlbumCover() with a file outside the album err = %v, want ErrNotInAlbum", err)
+	}
+
+	if err := db.ReorderAlbum(ctx, "a1", "u1", []string{"f3", "f1", "f2"}); err != nil {
+		t.Fatalf("ReorderAlbum(): %v", err)
+	}
+	if got := fileIds("a1"); !slices.Equal(got, []string{"f3", "f1", "f2"}) {
+		t.Errorf("album files after reorder = %v", got)
+	}
+	if err := db.ReorderAlbum(ctx, "a1", "u1", []string{"f3", "f1"}); !errors.Is(err, album.ErrNotInAlbum) {
+		t.Errorf("ReorderAlbum() missing a file err = %v, want ErrNotInAlbum", err)
+	}
+
+	// Removing the cover falls back to the first file.
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", nil, []string{"f3"}); err != nil {
+		t.Fatalf("UpdateAlbumFiles() remove: %v", err)
+	}
+	if a, _ := db.GetAlbum(ctx, "a1", "u1"); a == nil || a.CoverId != "f1" || a.FileCount != 2 {
+		t.Errorf("GetAlbum() after removing the cover = %+v", a)
+	}
+
+	if err := db.RenameAlbum(ctx, "a1", "u1", "Maui"); err != nil {
+		t.Fatalf("RenameAlbum(): %v", err)
+	}
+	if err := db.RenameAlbum(ctx, "a1", "u2", "Mine"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RenameAlbum() by another user err = %v, want sql.ErrNoRows", err)
+	}
+
+	albums, err := db.GetAlbums(ctx, "u1")
+	if err != nil || len(albums) != 2 || albums[0].Id != "a2" || albums[1].Name != "Maui" {
+		t.Errorf("GetAlbums() = %+v, %v, want newest first", albums, err)
+	}
+
+	// Deleting a file takes it out of every album but leaves the albums.
+	if _, err := db.Delete(ctx, "f2", "u1"); err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+	if got := fileIds("a2"); got != nil {
+		t.Errorf("a2 files after deleting f2 = %v, want none", got)
+	}
+
+	if err := db.DeleteAlbum(ctx, "a1", "u1"); err != nil {
+		t.Fatalf("DeleteAlbum(): %v", err)
+	}
+	if _, err := db.Get(ctx, "f1", "u1"); err != nil {
+		t.Errorf("Get() of a file in a deleted album: %v", err)
+	}
+	if _, err := db.GetAlbumFiles(ctx, "a1", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetAlbumFiles() of a deleted album err = %v, want sql.ErrNoRows", err)
+	}
+}
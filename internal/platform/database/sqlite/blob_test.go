@@ -0,0 +1,72 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+func TestSQLiteDB_Blobs(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	// u1 and u2 upload the same picture, legacy predates blobs.
+	files := []*fs.Metadata{
+		{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", Object: "abc", SHA256: "abc", UploadedAt: time.Now(), Pending: true},
+		{Id: "f2", Filename: "b.jpg", UserId: "u2", Bucket: "bucket-b", Object: "abc", SHA256: "abc", UploadedAt: time.Now(), Pending: true},
+		{Id: "legacy", Filename: "c.jpg", UserId: "u1", Bucket: "bucket-a", Object: "c.jpg", SHA256: "abc", UploadedAt: time.Now()},
+	}
+	for _, m := range files {
+		if err := db.Save(ctx, m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+	if files[1].Bucket != "bucket-a" {
+		t.Errorf("second upload filed under %q, want the blob's bucket-a", files[1].Bucket)
+	}
+
+	blob, err := db.GetBlob(ctx, "abc")
+	if err != nil || blob.Bucket != "bucket-a" || blob.Refs != 2 || blob.Stored {
+		t.Fatalf("GetBlob() = %+v, %v, want 2 refs in bucket-a, not stored", blob, err)
+	}
+
+	if err := db.CommitUpload(ctx, "f1", "u1"); err != nil {
+		t.Fatalf("CommitUpload(): %v", err)
+	}
+	if blob, err := db.GetBlob(ctx, "abc"); err != nil || !blob.Stored {
+		t.Errorf("GetBlob() after commit = %+v, %v, want stored", blob, err)
+	}
+	if m, err := db.Get(ctx, "f1", "u1"); err != nil || m.Bucket != "bucket-a" || m.Object != "abc" {
+		t.Errorf("Get() = %+v, %v, want the original at bucket-a/abc", m, err)
+	}
+
+	tests := []struct {
+		id, userId string
+		last       bool
+	}{
+		{id: "f1", userId: "u1", last: false},
+		{id: "f1", userId: "u1", last: false},
+		{id: "legacy", userId: "u1", last: true},
+		{id: "f2", userId: "u2", last: true},
+	}
+	for _, tt := range tests {
+		last, err := db.Delete(ctx, tt.id, tt.userId)
+		if err != nil || last != tt.last {
+			t.Errorf("Delete(%s) = %v, %v, want %v", tt.id, last, err, tt.last)
+		}
+	}
+
+	if _, err := db.GetBlob(ctx, "abc"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetBlob() after the last reference err = %v, want sql.ErrNoRows", err)
+	}
+}
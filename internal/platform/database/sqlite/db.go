@@ -24,34 +24,227 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.acquireBlobStmt, err = db.PrepareContext(ctx, acquireBlob); err != nil {
+		return nil, fmt.Errorf("error preparing query AcquireBlob: %w", err)
+	}
+	if q.addAlbumFileStmt, err = db.PrepareContext(ctx, addAlbumFile); err != nil {
+		return nil, fmt.Errorf("error preparing query AddAlbumFile: %w", err)
+	}
+	if q.addFileTagStmt, err = db.PrepareContext(ctx, addFileTag); err != nil {
+		return nil, fmt.Errorf("error preparing query AddFileTag: %w", err)
+	}
+	if q.clearRemovedAlbumCoverStmt, err = db.PrepareContext(ctx, clearRemovedAlbumCover); err != nil {
+		return nil, fmt.Errorf("error preparing query ClearRemovedAlbumCover: %w", err)
+	}
+	if q.commitUploadStmt, err = db.PrepareContext(ctx, commitUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query CommitUpload: %w", err)
+	}
+	if q.createAlbumStmt, err = db.PrepareContext(ctx, createAlbum); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateAlbum: %w", err)
+	}
+	if q.createVaultStmt, err = db.PrepareContext(ctx, createVault); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateVault: %w", err)
+	}
+	if q.deleteAlbumStmt, err = db.PrepareContext(ctx, deleteAlbum); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteAlbum: %w", err)
+	}
+	if q.deleteBlobStmt, err = db.PrepareContext(ctx, deleteBlob); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteBlob: %w", err)
+	}
 	if q.deleteMetadataStmt, err = db.PrepareContext(ctx, deleteMetadata); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteMetadata: %w", err)
 	}
-	if q.getAllMetadataStmt, err = db.PrepareContext(ctx, getAllMetadata); err != nil {
-		return nil, fmt.Errorf("error preparing query GetAllMetadata: %w", err)
+	if q.deletePendingMetadataStmt, err = db.PrepareContext(ctx, deletePendingMetadata); err != nil {
+		return nil, fmt.Errorf("error preparing query DeletePendingMetadata: %w", err)
+	}
+	if q.deleteTagStmt, err = db.PrepareContext(ctx, deleteTag); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteTag: %w", err)
+	}
+	if q.deleteUnusedTagsStmt, err = db.PrepareContext(ctx, deleteUnusedTags); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteUnusedTags: %w", err)
+	}
+	if q.getAlbumStmt, err = db.PrepareContext(ctx, getAlbum); err != nil {
+		return nil, fmt.Errorf("error preparing query GetAlbum: %w", err)
+	}
+	if q.getBlobStmt, err = db.PrepareContext(ctx, getBlob); err != nil {
+		return nil, fmt.Errorf("error preparing query GetBlob: %w", err)
+	}
+	if q.getExifStmt, err = db.PrepareContext(ctx, getExif); err != nil {
+		return nil, fmt.Errorf("error preparing query GetExif: %w", err)
 	}
 	if q.getMetadataStmt, err = db.PrepareContext(ctx, getMetadata); err != nil {
 		return nil, fmt.Errorf("error preparing query GetMetadata: %w", err)
 	}
+	if q.getPendingMetadataStmt, err = db.PrepareContext(ctx, getPendingMetadata); err != nil {
+		return nil, fmt.Errorf("error preparing query GetPendingMetadata: %w", err)
+	}
+	if q.getTrashedMetadataStmt, err = db.PrepareContext(ctx, getTrashedMetadata); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTrashedMetadata: %w", err)
+	}
 	if q.getUserStmt, err = db.PrepareContext(ctx, getUser); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUser: %w", err)
 	}
+	if q.getVaultStmt, err = db.PrepareContext(ctx, getVault); err != nil {
+		return nil, fmt.Errorf("error preparing query GetVault: %w", err)
+	}
+	if q.listAlbumFileIDsStmt, err = db.PrepareContext(ctx, listAlbumFileIDs); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAlbumFileIDs: %w", err)
+	}
+	if q.listAlbumFilesStmt, err = db.PrepareContext(ctx, listAlbumFiles); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAlbumFiles: %w", err)
+	}
+	if q.listAlbumsStmt, err = db.PrepareContext(ctx, listAlbums); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAlbums: %w", err)
+	}
+	if q.listExpiredTrashStmt, err = db.PrepareContext(ctx, listExpiredTrash); err != nil {
+		return nil, fmt.Errorf("error preparing query ListExpiredTrash: %w", err)
+	}
+	if q.listFileTagsStmt, err = db.PrepareContext(ctx, listFileTags); err != nil {
+		return nil, fmt.Errorf("error preparing query ListFileTags: %w", err)
+	}
+	if q.listInventoryStmt, err = db.PrepareContext(ctx, listInventory); err != nil {
+		return nil, fmt.Errorf("error preparing query ListInventory: %w", err)
+	}
+	if q.listStaleUploadsStmt, err = db.PrepareContext(ctx, listStaleUploads); err != nil {
+		return nil, fmt.Errorf("error preparing query ListStaleUploads: %w", err)
+	}
+	if q.listTagsStmt, err = db.PrepareContext(ctx, listTags); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTags: %w", err)
+	}
+	if q.listTrashStmt, err = db.PrepareContext(ctx, listTrash); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTrash: %w", err)
+	}
+	if q.listUsersStmt, err = db.PrepareContext(ctx, listUsers); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsers: %w", err)
+	}
+	if q.markBlobStoredStmt, err = db.PrepareContext(ctx, markBlobStored); err != nil {
+		return nil, fmt.Errorf("error preparing query MarkBlobStored: %w", err)
+	}
+	if q.nextAlbumPositionStmt, err = db.PrepareContext(ctx, nextAlbumPosition); err != nil {
+		return nil, fmt.Errorf("error preparing query NextAlbumPosition: %w", err)
+	}
+	if q.releaseBlobStmt, err = db.PrepareContext(ctx, releaseBlob); err != nil {
+		return nil, fmt.Errorf("error preparing query ReleaseBlob: %w", err)
+	}
+	if q.removeAlbumFileStmt, err = db.PrepareContext(ctx, removeAlbumFile); err != nil {
+		return nil, fmt.Errorf("error preparing query RemoveAlbumFile: %w", err)
+	}
+	if q.removeFileTagStmt, err = db.PrepareContext(ctx, removeFileTag); err != nil {
+		return nil, fmt.Errorf("error preparing query RemoveFileTag: %w", err)
+	}
+	if q.renameAlbumStmt, err = db.PrepareContext(ctx, renameAlbum); err != nil {
+		return nil, fmt.Errorf("error preparing query RenameAlbum: %w", err)
+	}
+	if q.restoreMetadataStmt, err = db.PrepareContext(ctx, restoreMetadata); err != nil {
+		return nil, fmt.Errorf("error preparing query RestoreMetadata: %w", err)
+	}
+	if q.rewrapVaultStmt, err = db.PrepareContext(ctx, rewrapVault); err != nil {
+		return nil, fmt.Errorf("error preparing query RewrapVault: %w", err)
+	}
+	if q.saveExifStmt, err = db.PrepareContext(ctx, saveExif); err != nil {
+		return nil, fmt.Errorf("error preparing query SaveExif: %w", err)
+	}
 	if q.saveMetadataStmt, err = db.PrepareContext(ctx, saveMetadata); err != nil {
 		return nil, fmt.Errorf("error preparing query SaveMetadata: %w", err)
 	}
+	if q.setAlbumCoverStmt, err = db.PrepareContext(ctx, setAlbumCover); err != nil {
+		return nil, fmt.Errorf("error preparing query SetAlbumCover: %w", err)
+	}
+	if q.setAlbumFilePositionStmt, err = db.PrepareContext(ctx, setAlbumFilePosition); err != nil {
+		return nil, fmt.Errorf("error preparing query SetAlbumFilePosition: %w", err)
+	}
+	if q.trashMetadataStmt, err = db.PrepareContext(ctx, trashMetadata); err != nil {
+		return nil, fmt.Errorf("error preparing query TrashMetadata: %w", err)
+	}
+	if q.updateCaptionStmt, err = db.PrepareContext(ctx, updateCaption); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateCaption: %w", err)
+	}
+	if q.upsertTagStmt, err = db.PrepareContext(ctx, upsertTag); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertTag: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.acquireBlobStmt != nil {
+		if cerr := q.acquireBlobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing acquireBlobStmt: %w", cerr)
+		}
+	}
+	if q.addAlbumFileStmt != nil {
+		if cerr := q.addAlbumFileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addAlbumFileStmt: %w", cerr)
+		}
+	}
+	if q.addFileTagStmt != nil {
+		if cerr := q.addFileTagStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addFileTagStmt: %w", cerr)
+		}
+	}
+	if q.clearRemovedAlbumCoverStmt != nil {
+		if cerr := q.clearRemovedAlbumCoverStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing clearRemovedAlbumCoverStmt: %w", cerr)
+		}
+	}
+	if q.commitUploadStmt != nil {
+		if cerr := q.commitUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing commitUploadStmt: %w", cerr)
+		}
+	}
+	if q.createAlbumStmt != nil {
+		if cerr := q.createAlbumStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createAlbumStmt: %w", cerr)
+		}
+	}
+	if q.createVaultStmt != nil {
+		if cerr := q.createVaultStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createVaultStmt: %w", cerr)
+		}
+	}
+	if q.deleteAlbumStmt != nil {
+		if cerr := q.deleteAlbumStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteAlbumStmt: %w", cerr)
+		}
+	}
+	if q.deleteBlobStmt != nil {
+		if cerr := q.deleteBlobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteBlobStmt: %w", cerr)
+		}
+	}
 	if q.deleteMetadataStmt != nil {
 		if cerr := q.deleteMetadataStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteMetadataStmt: %w", cerr)
 		}
 	}
-	if q.getAllMetadataStmt != nil {
-		if cerr := q.getAllMetadataStmt.Close(); cerr != nil {
-			err = fmt.Errorf("error closing getAllMetadataStmt: %w", cerr)
+	if q.deletePendingMetadataStmt != nil {
+		if cerr := q.deletePendingMetadataStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deletePendingMetadataStmt: %w", cerr)
+		}
+	}
+	if q.deleteTagStmt != nil {
+		if cerr := q.deleteTagStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteTagStmt: %w", cerr)
+		}
+	}
+	if q.deleteUnusedTagsStmt != nil {
+		if cerr := q.deleteUnusedTagsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteUnusedTagsStmt: %w", cerr)
+		}
+	}
+	if q.getAlbumStmt != nil {
+		if cerr := q.getAlbumStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getAlbumStmt: %w", cerr)
+		}
+	}
+	if q.getBlobStmt != nil {
+		if cerr := q.getBlobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getBlobStmt: %w", cerr)
+		}
+	}
+	if q.getExifStmt != nil {
+		if cerr := q.getExifStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getExifStmt: %w", cerr)
 		}
 	}
 	if q.getMetadataStmt != nil {
@@ -59,16 +252,151 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getMetadataStmt: %w", cerr)
 		}
 	}
+	if q.getPendingMetadataStmt != nil {
+		if cerr := q.getPendingMetadataStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getPendingMetadataStmt: %w", cerr)
+		}
+	}
+	if q.getTrashedMetadataStmt != nil {
+		if cerr := q.getTrashedMetadataStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTrashedMetadataStmt: %w", cerr)
+		}
+	}
 	if q.getUserStmt != nil {
 		if cerr := q.getUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getUserStmt: %w", cerr)
 		}
 	}
+	if q.getVaultStmt != nil {
+		if cerr := q.getVaultStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getVaultStmt: %w", cerr)
+		}
+	}
+	if q.listAlbumFileIDsStmt != nil {
+		if cerr := q.listAlbumFileIDsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAlbumFileIDsStmt: %w", cerr)
+		}
+	}
+	if q.listAlbumFilesStmt != nil {
+		if cerr := q.listAlbumFilesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAlbumFilesStmt: %w", cerr)
+		}
+	}
+	if q.listAlbumsStmt != nil {
+		if cerr := q.listAlbumsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAlbumsStmt: %w", cerr)
+		}
+	}
+	if q.listExpiredTrashStmt != nil {
+		if cerr := q.listExpiredTrashStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listExpiredTrashStmt: %w", cerr)
+		}
+	}
+	if q.listFileTagsStmt != nil {
+		if cerr := q.listFileTagsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listFileTagsStmt: %w", cerr)
+		}
+	}
+	if q.listInventoryStmt != nil {
+		if cerr := q.listInventoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listInventoryStmt: %w", cerr)
+		}
+	}
+	if q.listStaleUploadsStmt != nil {
+		if cerr := q.listStaleUploadsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listStaleUploadsStmt: %w", cerr)
+		}
+	}
+	if q.listTagsStmt != nil {
+		if cerr := q.listTagsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTagsStmt: %w", cerr)
+		}
+	}
+	if q.listTrashStmt != nil {
+		if cerr := q.listTrashStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTrashStmt: %w", cerr)
+		}
+	}
+	if q.listUsersStmt != nil {
+		if cerr := q.listUsersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsersStmt: %w", cerr)
+		}
+	}
+	if q.markBlobStoredStmt != nil {
+		if cerr := q.markBlobStoredStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing markBlobStoredStmt: %w", cerr)
+		}
+	}
+	if q.nextAlbumPositionStmt != nil {
+		if cerr := q.nextAlbumPositionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing nextAlbumPositionStmt: %w", cerr)
+		}
+	}
+	if q.releaseBlobStmt != nil {
+		if cerr := q.releaseBlobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing releaseBlobStmt: %w", cerr)
+		}
+	}
+	if q.removeAlbumFileStmt != nil {
+		if cerr := q.removeAlbumFileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing removeAlbumFileStmt: %w", cerr)
+		}
+	}
+	if q.removeFileTagStmt != nil {
+		if cerr := q.removeFileTagStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing removeFileTagStmt: %w", cerr)
+		}
+	}
+	if q.renameAlbumStmt != nil {
+		if cerr := q.renameAlbumStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing renameAlbumStmt: %w", cerr)
+		}
+	}
+	if q.restoreMetadataStmt != nil {
+		if cerr := q.restoreMetadataStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing restoreMetadataStmt: %w", cerr)
+		}
+	}
+	if q.rewrapVaultStmt != nil {
+		if cerr := q.rewrapVaultStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing rewrapVaultStmt: %w", cerr)
+		}
+	}
+	if q.saveExifStmt != nil {
+		if cerr := q.saveExifStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing saveExifStmt: %w", cerr)
+		}
+	}
 	if q.saveMetadataStmt != nil {
 		if cerr := q.saveMetadataStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing saveMetadataStmt: %w", cerr)
 		}
 	}
+	if q.setAlbumCoverStmt != nil {
+		if cerr := q.setAlbumCoverStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setAlbumCoverStmt: %w", cerr)
+		}
+	}
+	if q.setAlbumFilePositionStmt != nil {
+		if cerr := q.setAlbumFilePositionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setAlbumFilePositionStmt: %w", cerr)
+		}
+	}
+	if q.trashMetadataStmt != nil {
+		if cerr := q.trashMetadataStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing trashMetadataStmt: %w", cerr)
+		}
+	}
+	if q.updateCaptionStmt != nil {
+		if cerr := q.updateCaptionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateCaptionStmt: %w", cerr)
+		}
+	}
+	if q.upsertTagStmt != nil {
+		if cerr := q.upsertTagStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertTagStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -106,23 +434,105 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                 DBTX
-	tx                 *sql.Tx
-	deleteMetadataStmt *sql.Stmt
-	getAllMetadataStmt *sql.Stmt
-	getMetadataStmt    *sql.Stmt
-	getUserStmt        *sql.Stmt
-	saveMetadataStmt   *sql.Stmt
+	db                         DBTX
+	tx                         *sql.Tx
+	acquireBlobStmt            *sql.Stmt
+	addAlbumFileStmt           *sql.Stmt
+	addFileTagStmt             *sql.Stmt
+	clearRemovedAlbumCoverStmt *sql.Stmt
+	commitUploadStmt           *sql.Stmt
+	createAlbumStmt            *sql.Stmt
+	createVaultStmt            *sql.Stmt
+	deleteAlbumStmt            *sql.Stmt
+	deleteBlobStmt             *sql.Stmt
+	deleteMetadataStmt         *sql.Stmt
+	deletePendingMetadataStmt  *sql.Stmt
+	deleteTagStmt              *sql.Stmt
+	deleteUnusedTagsStmt       *sql.Stmt
+	getAlbumStmt               *sql.Stmt
+	getBlobStmt                *sql.Stmt
+	getExifStmt                *sql.Stmt
+	getMetadataStmt            *sql.Stmt
+	getPendingMetadataStmt     *sql.Stmt
+	getTrashedMetadataStmt     *sql.Stmt
+	getUserStmt                *sql.Stmt
+	getVaultStmt               *sql.Stmt
+	listAlbumFileIDsStmt       *sql.Stmt
+	listAlbumFilesStmt         *sql.Stmt
+	listAlbumsStmt             *sql.Stmt
+	listExpiredTrashStmt       *sql.Stmt
+	listFileTagsStmt           *sql.Stmt
+	listInventoryStmt          *sql.Stmt
+	listStaleUploadsStmt       *sql.Stmt
+	listTagsStmt               *sql.Stmt
+	listTrashStmt              *sql.Stmt
+	listUsersStmt              *sql.Stmt
+	markBlobStoredStmt         *sql.Stmt
+	nextAlbumPositionStmt      *sql.Stmt
+	releaseBlobStmt            *sql.Stmt
+	removeAlbumFileStmt        *sql.Stmt
+	removeFileTagStmt          *sql.Stmt
+	renameAlbumStmt            *sql.Stmt
+	restoreMetadataStmt        *sql.Stmt
+	rewrapVaultStmt            *sql.Stmt
+	saveExifStmt               *sql.Stmt
+	saveMetadataStmt           *sql.Stmt
+	setAlbumCoverStmt          *sql.Stmt
+	setAlbumFilePositionStmt   *sql.Stmt
+	trashMetadataStmt          *sql.Stmt
+	updateCaptionStmt          *sql.Stmt
+	upsertTagStmt              *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                 tx,
-		tx:                 tx,
-		deleteMetadataStmt: q.deleteMetadataStmt,
-		getAllMetadataStmt: q.getAllMetadataStmt,
-		getMetadataStmt:    q.getMetadataStmt,
-		getUserStmt:        q.getUserStmt,
-		saveMetadataStmt:   q.saveMetadataStmt,
+		db:                         tx,
+		tx:                         tx,
+		acquireBlobStmt:            q.acquireBlobStmt,
+		addAlbumFileStmt:           q.addAlbumFileStmt,
+		addFileTagStmt:             q.addFileTagStmt,
+		clearRemovedAlbumCoverStmt: q.clearRemovedAlbumCoverStmt,
+		commitUploadStmt:           q.commitUploadStmt,
+		createAlbumStmt:            q.createAlbumStmt,
+		createVaultStmt:            q.createVaultStmt,
+		deleteAlbumStmt:            q.deleteAlbumStmt,
+		deleteBlobStmt:             q.deleteBlobStmt,
+		deleteMetadataStmt:         q.deleteMetadataStmt,
+		deletePendingMetadataStmt:  q.deletePendingMetadataStmt,
+		deleteTagStmt:              q.deleteTagStmt,
+		deleteUnusedTagsStmt:       q.deleteUnusedTagsStmt,
+		getAlbumStmt:               q.getAlbumStmt,
+		getBlobStmt:                q.getBlobStmt,
+		getExifStmt:                q.getExifStmt,
+		getMetadataStmt:            q.getMetadataStmt,
+		getPendingMetadataStmt:     q.getPendingMetadataStmt,
+		getTrashedMetadataStmt:     q.getTrashedMetadataStmt,
+		getUserStmt:                q.getUserStmt,
+		getVaultStmt:               q.getVaultStmt,
+		listAlbumFileIDsStmt:       q.listAlbumFileIDsStmt,
+		listAlbumFilesStmt:         q.listAlbumFilesStmt,
+		listAlbumsStmt:             q.listAlbumsStmt,
+		listExpiredTrashStmt:       q.listExpiredTrashStmt,
+		listFileTagsStmt:           q.listFileTagsStmt,
+		listInventoryStmt:          q.listInventoryStmt,
+		listStaleUploadsStmt:       q.listStaleUploadsStmt,
+		listTagsStmt:               q.listTagsStmt,
+		listTrashStmt:              q.listTrashStmt,
+		listUsersStmt:              q.listUsersStmt,
+		markBlobStoredStmt:         q.markBlobStoredStmt,
+		nextAlbumPositionStmt:      q.nextAlbumPositionStmt,
+		releaseBlobStmt:            q.releaseBlobStmt,
+		removeAlbumFileStmt:        q.removeAlbumFileStmt,
+		removeFileTagStmt:          q.removeFileTagStmt,
+		renameAlbumStmt:            q.renameAlbumStmt,
+		restoreMetadataStmt:        q.restoreMetadataStmt,
+		rewrapVaultStmt:            q.rewrapVaultStmt,
+		saveExifStmt:               q.saveExifStmt,
+		saveMetadataStmt:           q.saveMetadataStmt,
+		setAlbumCoverStmt:          q.setAlbumCoverStmt,
+		setAlbumFilePositionStmt:   q.setAlbumFilePositionStmt,
+		trashMetadataStmt:          q.trashMetadataStmt,
+		updateCaptionStmt:          q.updateCaptionStmt,
+		upsertTagStmt:              q.upsertTagStmt,
 	}
 }
@@ -0,0 +1,48 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+func TestSQLiteDB_Inventory(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.Conn.DB.Exec("INSERT INTO users (id, email, bucket) VALUES ('u2', 'b@example.com', 'bucket-b'), ('u1', 'a@example.com', 'bucket-a')"); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+	for _, m := range []fs.Metadata{
+		{Id: "f2", Filename: "b.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()},
+		{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()},
+		{Id: "f3", Filename: "c.jpg", UserId: "u2", Bucket: "bucket-b", UploadedAt: time.Now()},
+	} {
+		if err := db.Save(ctx, &m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+	if err := db.Trash(ctx, "f2", "u1", time.Now()); err != nil {
+		t.Fatalf("Trash(): %v", err)
+	}
+
+	buckets, err := db.GetBuckets(ctx)
+	want := []fs.UserBucket{{UserId: "u1", Bucket: "bucket-a"}, {UserId: "u2", Bucket: "bucket-b"}}
+	if err != nil || !slices.Equal(buckets, want) {
+		t.Errorf("GetBuckets() = %v, %v, want %v", buckets, err, want)
+	}
+
+	files, err := db.GetInventory(ctx, "bucket-a")
+	if err != nil || len(files) != 2 || files[0].Id != "f1" || files[1].Id != "f2" || files[1].DeletedAt == nil {
+		t.Errorf("GetInventory() = %+v, %v, want f1 and the trashed f2", files, err)
+	}
+}
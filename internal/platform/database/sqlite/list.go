@@ -0,0 +1,158 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// The listing queries are built by hand because sqlc cannot vary the WHERE
+// and ORDER BY clauses. Times are compared through strftime so rows written
+// by CURRENT_TIMESTAMP, which have no offset, sort alongside the ones the
+// driver writes. The sort expressions match the indexes in the migrations.
+const (
+	takenExpr    = "strftime('%Y-%m-%d %H:%M:%f', COALESCE(taken_at, uploaded_at))"
+	uploadedExpr = "strftime('%Y-%m-%d %H:%M:%f', uploaded_at)"
+	nameExpr     = "lower(file_name)"
+	sizeExpr     = "size"
+
+	metadataColumns = "id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, wrapped_key, encrypted_metadata"
+)
+
+type listQuery struct {
+	where []string
+	args  []any
+}
+
+func newListQuery(userId string, f fs.Filter) *listQuery {
+	q := &listQuery{}
+	q.add("user_id = ?", userId)
+	q.add("deleted_at IS NULL")
+	q.add("NOT pending")
+
+	if f.MediaType != "" {
+		// A range rather than LIKE so the index on content_type applies,
+		// '0' is the character after '/'.
+		q.add("content_type >= ? AND content_type < ?", f.MediaType+"/", f.MediaType+"0")
+	}
+	for _, name := range f.Names {
+		q.add(`file_name LIKE ? ESCAPE '\'`, "%"+escapeLike(name)+"%")
+	}
+	if f.Camera != "" {
+		q.add(`EXISTS (SELECT 1 FROM exif WHERE exif.file_id = metadata.id AND camera_make || ' ' || camera_model LIKE ? ESCAPE '\')`, "%"+escapeLike(f.Camera)+"%")
+	}
+	for _, name := range f.Tags {
+		q.add("EXISTS (SELECT 1 FROM file_tags JOIN tags ON tags.id = file_tags.tag_id WHERE file_tags.file_id = metadata.id AND tags.name = ?)", name)
+	}
+	q.timeRange(takenExpr, f.Taken)
+	q.timeRange(uploadedExpr, f.Uploaded)
+	if f.Size.Min != nil {
+		q.add("size >= ?", *f.Size.Min)
+	}
+	if f.Size.Max != nil {
+		q.add("size <= ?", *f.Size.Max)
+	}
+
+	return q
+}
+
+func (q *listQuery) add(cond string, args ...any) {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+}
+
+func (q *listQuery) timeRange(expr string, r fs.TimeRange) {
+	if r.From != nil {
+		q.add(expr+" >= strftime('%Y-%m-%d %H:%M:%f', ?)", r.From.UTC())
+	}
+	if r.To != nil {
+		q.add(expr+" < strftime('%Y-%m-%d %H:%M:%f', ?)", r.To.UTC())
+	}
+}
+
+func (q *listQuery) sql(selection string) string {
+	return "SELECT " + selection + " FROM metadata WHERE " + strings.Join(q.where, " AND ")
+}
+
+func (db *SQLiteDB) GetAll(ctx context.Context, userId string, opts fs.ListOptions) ([]fs.Metadata, error) {
+	q := newListQuery(userId, opts.Filter)
+
+	var expr, param string
+	var value any
+	switch opts.SortBy {
+	case fs.SortByUploaded:
+		expr, param = uploadedExpr, "strftime('%Y-%m-%d %H:%M:%f', ?)"
+	case fs.SortByName:
+		expr, param = nameExpr, "lower(?)"
+	case fs.SortBySize:
+		expr, param = sizeExpr, "?"
+	default:
+		expr, param = takenExpr, "strftime('%Y-%m-%d %H:%M:%f', ?)"
+	}
+
+	dir, cmp := "DESC", "<"
+	if opts.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+
+	if opts.After != nil {
+		switch opts.SortBy {
+		case fs.SortByName:
+			value = opts.After.Name
+		case fs.SortBySize:
+			value = opts.After.Size
+		default:
+			value = opts.After.Time.UTC()
+		}
+		q.add(fmt.Sprintf("(%s, id) %s (%s, ?)", expr, cmp, param), value, opts.After.Id)
+	}
+
+	query := q.sql(metadataColumns) + fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", expr, dir, dir)
+	rows, err := db.Conn.DB.QueryContext(ctx, query, append(q.args, opts.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []fs.Metadata
+	for rows.Next() {
+		var m Metadata
+		if err := rows.Scan(
+			&m.ID,
+			&m.FileName,
+			&m.ThumbName,
+			&m.UserID,
+			&m.ContentType,
+			&m.Size,
+			&m.UploadedAt,
+			&m.Width,
+			&m.Height,
+			&m.Duration,
+			&m.Sha256,
+			&m.TakenAt,
+			&m.Caption,
+			&m.DeletedAt,
+			&m.WrappedKey,
+			&m.EncryptedMetadata,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, toMetadata(m))
+	}
+
+	return results, rows.Err()
+}
+
+func (db *SQLiteDB) Count(ctx context.Context, userId string, filter fs.Filter) (int64, error) {
+	q := newListQuery(userId, filter)
+
+	var n int64
+	err := db.Conn.DB.QueryRowContext(ctx, q.sql("COUNT(*)"), q.args...).Scan(&n)
+	return n, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
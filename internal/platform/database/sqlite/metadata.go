@@ -2,19 +2,111 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/mattn/go-sqlite3"
 	"github.com/portbound/go-fs/internal/fs"
 )
 
 func (db *SQLiteDB) Save(ctx context.Context, m *fs.Metadata) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bucket, err := insertMetadata(ctx, db.Queries.WithTx(tx), m)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.Bucket = bucket
+	return nil
+}
+
+func (db *SQLiteDB) ReplacePending(ctx context.Context, m *fs.Metadata) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	n, err := qtx.DeletePendingMetadata(ctx, DeletePendingMetadataParams{ID: m.Id, UserID: m.UserId})
+	if err != nil {
+		return fmt.Errorf("delete pending row: %w", err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	bucket, err := insertMetadata(ctx, qtx, m)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.Bucket = bucket
+	return nil
+}
+
+// insertMetadata inserts the row and returns the bucket it ended up in.
+func insertMetadata(ctx context.Context, qtx *Queries, m *fs.Metadata) (string, error) {
+	// A file stored by content joins its blob wherever that already is.
+	bucket := m.Bucket
+	if m.SHA256 != "" && m.Object == m.SHA256 {
+		var err error
+		bucket, err = qtx.AcquireBlob(ctx, AcquireBlobParams{Sha256: m.SHA256, Bucket: m.Bucket})
+		if err != nil {
+			return "", fmt.Errorf("acquire blob: %w", err)
+		}
+	}
+
 	params := SaveMetadataParams{
-		ID:        m.Id,
-		FileName:  m.Filename,
-		ThumbName: m.Thumbname,
-		UserID:    m.UserId,
+		ID:                m.Id,
+		FileName:          m.Filename,
+		ThumbName:         m.Thumbname,
+		UserID:            m.UserId,
+		ContentType:       m.ContentType,
+		Size:              m.Size,
+		UploadedAt:        m.UploadedAt,
+		Width:             int64(m.Width),
+		Height:            int64(m.Height),
+		Duration:          m.Duration,
+		Sha256:            m.SHA256,
+		TakenAt:           nullTime(m.TakenAt),
+		Pending:           m.Pending,
+		Bucket:            bucket,
+		ObjectName:        m.Object,
+		WrappedKey:        m.WrappedKey,
+		EncryptedMetadata: m.EncryptedMetadata,
 	}
 
-	return db.Queries.SaveMetadata(ctx, params)
+	if err := qtx.SaveMetadata(ctx, params); err != nil {
+		if isUniqueViolation(err) {
+			return "", fs.ErrFileExists
+		}
+		return "", err
+	}
+
+	if m.Exif != nil {
+		if err := qtx.SaveExif(ctx, toSaveExifParams(m.Id, m.Exif)); err != nil {
+			return "", fmt.Errorf("save exif: %w", err)
+		}
+	}
+
+	return bucket, nil
 }
 
 func (db *SQLiteDB) Get(ctx context.Context, id, userId string) (*fs.Metadata, error) {
@@ -28,38 +120,225 @@ func (db *SQLiteDB) Get(ctx context.Context, id, userId string) (*fs.Metadata, e
 		return nil, err
 	}
 
-	return &fs.Metadata{
-		Id:        m.ID,
-		Filename:  m.FileName,
-		Thumbname: m.ThumbName,
-		UserId:    m.UserID,
-	}, nil
+	meta := toMetadata(m)
+
+	e, err := db.Queries.GetExif(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("get exif: %w", err)
+	}
+	if err == nil {
+		meta.Exif = toExif(e)
+	}
+
+	return &meta, nil
+}
+
+func (db *SQLiteDB) GetPending(ctx context.Context, id, userId string) (*fs.Metadata, error) {
+	m, err := db.Queries.GetPendingMetadata(ctx, GetPendingMetadataParams{ID: id, UserID: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := toMetadata(m)
+	return &meta, nil
+}
+
+func (db *SQLiteDB) CommitUpload(ctx context.Context, id, userId string) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	n, err := qtx.CommitUpload(ctx, CommitUploadParams{ID: id, UserID: userId})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := qtx.MarkBlobStored(ctx, MarkBlobStoredParams{ID: id, UserID: userId}); err != nil {
+		return fmt.Errorf("mark blob stored: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) GetStaleUploads(ctx context.Context, cutoff time.Time, limit int) ([]fs.Metadata, error) {
+	rows, err := db.Queries.ListStaleUploads(ctx, ListStaleUploadsParams{Cutoff: cutoff.UTC(), BatchSize: int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]fs.Metadata, len(rows))
+	for i, row := range rows {
+		files[i] = toMetadata(row)
+	}
+
+	return files, nil
 }
 
-func (db *SQLiteDB) GetAll(ctx context.Context, userId string) ([]fs.Metadata, error) {
-	rows, err := db.GetAllMetadata(ctx, userId)
+func (db *SQLiteDB) GetBlob(ctx context.Context, sha256 string) (*fs.Blob, error) {
+	b, err := db.Queries.GetBlob(ctx, sha256)
 	if err != nil {
 		return nil, err
 	}
 
-	results := make([]fs.Metadata, len(rows))
-	for i, m := range rows {
-		results[i] = fs.Metadata{
-			Id:        m.ID,
-			Filename:  m.FileName,
-			Thumbname: m.ThumbName,
-			UserId:    m.UserID,
+	return &fs.Blob{SHA256: b.Sha256, Bucket: b.Bucket, Refs: int(b.Refs), Stored: b.Stored}, nil
+}
+
+func (db *SQLiteDB) UpdateCaption(ctx context.Context, id, userId, caption string) error {
+	params := UpdateCaptionParams{
+		Caption: caption,
+		ID:      id,
+		UserID:  userId,
+	}
+
+	n, err := db.Queries.UpdateCaption(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) Delete(ctx context.Context, id, userId string) (bool, error) {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	row, err := qtx.DeleteMetadata(ctx, DeleteMetadataParams{ID: id, UserID: userId})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
 		}
+		return false, err
 	}
 
-	return results, nil
+	// Files from before blobs own their objects outright.
+	last := true
+	if row.Sha256 != "" && row.ObjectName == row.Sha256 {
+		refs, err := qtx.ReleaseBlob(ctx, row.Sha256)
+		if err != nil {
+			return false, fmt.Errorf("release blob: %w", err)
+		}
+
+		last = refs <= 0
+		if last {
+			if err := qtx.DeleteBlob(ctx, row.Sha256); err != nil {
+				return false, fmt.Errorf("delete blob: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return last, nil
 }
 
-func (db *SQLiteDB) Delete(ctx context.Context, id, email string) error {
-	params := DeleteMetadataParams{
-		ID:     id,
-		UserID: email,
+func toMetadata(m Metadata) fs.Metadata {
+	return fs.Metadata{
+		Id:                m.ID,
+		Filename:          m.FileName,
+		Thumbname:         m.ThumbName,
+		UserId:            m.UserID,
+		ContentType:       m.ContentType,
+		Size:              m.Size,
+		UploadedAt:        m.UploadedAt,
+		Width:             int(m.Width),
+		Height:            int(m.Height),
+		Duration:          m.Duration,
+		SHA256:            m.Sha256,
+		TakenAt:           timePtr(m.TakenAt),
+		Caption:           m.Caption,
+		DeletedAt:         timePtr(m.DeletedAt),
+		Pending:           m.Pending,
+		Bucket:            m.Bucket,
+		Object:            m.ObjectName,
+		WrappedKey:        m.WrappedKey,
+		EncryptedMetadata: m.EncryptedMetadata,
+	}
+}
+
+func toSaveExifParams(fileId string, e *fs.Exif) SaveExifParams {
+	return SaveExifParams{
+		FileID:       fileId,
+		CameraMake:   e.CameraMake,
+		CameraModel:  e.CameraModel,
+		LensModel:    e.LensModel,
+		Orientation:  int64(e.Orientation),
+		ExposureTime: e.ExposureTime,
+		FNumber:      e.FNumber,
+		Iso:          int64(e.ISO),
+		FocalLength:  e.FocalLength,
+		Latitude:     nullFloat64(e.Latitude),
+		Longitude:    nullFloat64(e.Longitude),
+	}
+}
+
+func toExif(e Exif) *fs.Exif {
+	return &fs.Exif{
+		CameraMake:   e.CameraMake,
+		CameraModel:  e.CameraModel,
+		LensModel:    e.LensModel,
+		Orientation:  int(e.Orientation),
+		ExposureTime: e.ExposureTime,
+		FNumber:      e.FNumber,
+		ISO:          int(e.Iso),
+		FocalLength:  e.FocalLength,
+		Latitude:     float64Ptr(e.Latitude),
+		Longitude:    float64Ptr(e.Longitude),
+	}
+}
+
+// isUniqueViolation also matches primary keys, which SQLite reports apart
+// from other unique constraints.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func nullFloat64(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+func float64Ptr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
 	}
 
-	return db.Queries.DeleteMetadata(ctx, params)
+	return &f.Float64
 }
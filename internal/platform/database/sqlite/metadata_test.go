@@ -0,0 +1,161 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+func TestSQLiteDB_GetAll(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+
+	// Rows from before uploaded_at existed share one CURRENT_TIMESTAMP value
+	// with no offset, they must page by id rather than repeat or go missing.
+	_, err = db.Conn.DB.Exec(`INSERT INTO metadata (id, file_name, thumb_name, user_id, content_type, size, uploaded_at) VALUES
+		('legacy-a', 'a.jpg', 'thumb-a.jpg', 'u1', 'image/jpeg', 300, '2025-01-01 00:00:00'),
+		('legacy-b', 'B.jpg', 'thumb-b.jpg', 'u1', 'image/jpeg', 100, '2025-01-01 00:00:00'),
+		('legacy-c', 'c_1.jpg', 'thumb-c.jpg', 'u1', 'image/jpeg', 200, '2025-01-01 00:00:00'),
+		('other', 'd.jpg', 'thumb-d.jpg', 'u2', 'image/jpeg', 100, '2025-01-01 00:00:00')`)
+	if err != nil {
+		t.Fatalf("insert legacy rows: %v", err)
+	}
+
+	takenAt := time.Date(2020, 5, 1, 8, 30, 0, 0, time.UTC)
+	est := time.FixedZone("EST", -5*60*60)
+	files := []fs.Metadata{
+		{Id: "new", Filename: "new.mp4", Thumbname: "thumb-new.jpg", UserId: "u1", ContentType: "video/mp4", Size: 5000, UploadedAt: time.Date(2025, 3, 1, 12, 0, 0, 500, est)},
+		{Id: "old-photo", Filename: "old.jpg", Thumbname: "thumb-old.jpg", UserId: "u1", ContentType: "image/jpeg", Size: 400, UploadedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), TakenAt: &takenAt,
+			Exif: &fs.Exif{CameraMake: "Canon", CameraModel: "EOS R5"}},
+	}
+	for i := range files {
+		if err := db.Save(ctx, &files[i]); err != nil {
+			t.Fatalf("Save(%s): %v", files[i].Id, err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		sortBy    fs.SortBy
+		ascending bool
+		query     string
+		want      []string
+	}{
+		{
+			name:   "by capture time",
+			sortBy: fs.SortByTaken,
+			want:   []string{"new", "legacy-c", "legacy-b", "legacy-a", "old-photo"},
+		},
+		{
+			name:   "by upload time",
+			sortBy: fs.SortByUploaded,
+			want:   []string{"new", "old-photo", "legacy-c", "legacy-b", "legacy-a"},
+		},
+		{
+			name:      "by name ignoring case",
+			sortBy:    fs.SortByName,
+			ascending: true,
+			want:      []string{"legacy-a", "legacy-b", "legacy-c", "new", "old-photo"},
+		},
+		{
+			name:   "by size",
+			sortBy: fs.SortBySize,
+			want:   []string{"new", "old-photo", "legacy-a", "legacy-c", "legacy-b"},
+		},
+		{
+			name:   "videos only",
+			sortBy: fs.SortByTaken,
+			query:  "type:video",
+			want:   []string{"new"},
+		},
+		{
+			name:   "images taken in 2025",
+			sortBy: fs.SortByTaken,
+			query:  "type:image taken:2025",
+			want:   []string{"legacy-c", "legacy-b", "legacy-a"},
+		},
+		{
+			name:   "taken before 2021",
+			sortBy: fs.SortByTaken,
+			query:  "taken:<2021-01-01",
+			want:   []string{"old-photo"},
+		},
+		{
+			name:   "uploaded range",
+			sortBy: fs.SortByUploaded,
+			query:  "uploaded:2025-02..2025-03-01",
+			want:   []string{"new", "old-photo"},
+		},
+		{
+			name:   "filename with a LIKE wildcard",
+			sortBy: fs.SortByTaken,
+			query:  "c_",
+			want:   []string{"legacy-c"},
+		},
+		{
+			name:   "camera",
+			sortBy: fs.SortByTaken,
+			query:  `camera:"canon eos"`,
+			want:   []string{"old-photo"},
+		},
+		{
+			name:   "size range",
+			sortBy: fs.SortBySize,
+			query:  "size:200..400",
+			want:   []string{"old-photo", "legacy-a", "legacy-c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := fs.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+			}
+
+			var got []string
+			opts := fs.ListOptions{Filter: filter, SortBy: tt.sortBy, Ascending: tt.ascending, Limit: 2}
+			for range 10 {
+				page, err := db.GetAll(ctx, "u1", opts)
+				if err != nil {
+					t.Fatalf("GetAll(): %v", err)
+				}
+				if len(page) == 0 {
+					break
+				}
+
+				for _, m := range page {
+					got = append(got, m.Id)
+				}
+				opts.After = position(tt.sortBy, page[len(page)-1])
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GetAll() pages = %v, want %v", got, tt.want)
+			}
+
+			n, err := db.Count(ctx, "u1", filter)
+			if err != nil || n != int64(len(tt.want)) {
+				t.Errorf("Count() = %d, %v, want %d", n, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func position(sortBy fs.SortBy, m fs.Metadata) *fs.Position {
+	p := &fs.Position{Id: m.Id, Time: m.UploadedAt, Name: m.Filename, Size: m.Size}
+	if sortBy == fs.SortByTaken && m.TakenAt != nil {
+		p.Time = *m.TakenAt
+	}
+	return p
+}
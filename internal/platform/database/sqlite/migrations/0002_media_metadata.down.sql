@@ -0,0 +1,7 @@
+ALTER TABLE metadata DROP COLUMN sha256;
+ALTER TABLE metadata DROP COLUMN duration;
+ALTER TABLE metadata DROP COLUMN height;
+ALTER TABLE metadata DROP COLUMN width;
+ALTER TABLE metadata DROP COLUMN uploaded_at;
+ALTER TABLE metadata DROP COLUMN size;
+ALTER TABLE metadata DROP COLUMN content_type;
@@ -0,0 +1,11 @@
+ALTER TABLE metadata ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE metadata ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE metadata ADD COLUMN uploaded_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE metadata ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE metadata ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE metadata ADD COLUMN duration REAL NOT NULL DEFAULT 0;
+ALTER TABLE metadata ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
+
+-- The real upload time of existing files is unknown, the time of the upgrade
+-- keeps them together at the top of the timeline rather than in 1970.
+UPDATE metadata SET uploaded_at = CURRENT_TIMESTAMP;
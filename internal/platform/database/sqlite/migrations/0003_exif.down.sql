@@ -0,0 +1,2 @@
+DROP TABLE exif;
+ALTER TABLE metadata DROP COLUMN taken_at;
@@ -0,0 +1,15 @@
+ALTER TABLE metadata ADD COLUMN taken_at DATETIME;
+
+CREATE TABLE exif (
+		file_id TEXT NOT NULL PRIMARY KEY REFERENCES metadata (id) ON DELETE CASCADE,
+		camera_make TEXT NOT NULL,
+		camera_model TEXT NOT NULL,
+		lens_model TEXT NOT NULL,
+		orientation INTEGER NOT NULL,
+		exposure_time TEXT NOT NULL,
+		f_number REAL NOT NULL,
+		iso INTEGER NOT NULL,
+		focal_length REAL NOT NULL,
+		latitude REAL,
+		longitude REAL
+);
@@ -0,0 +1,2 @@
+DROP INDEX metadata_uploaded_idx;
+DROP INDEX metadata_taken_idx;
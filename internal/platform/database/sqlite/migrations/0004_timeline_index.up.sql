@@ -0,0 +1,2 @@
+CREATE INDEX metadata_taken_idx ON metadata (user_id, strftime('%Y-%m-%d %H:%M:%f', COALESCE(taken_at, uploaded_at)) DESC, id DESC);
+CREATE INDEX metadata_uploaded_idx ON metadata (user_id, strftime('%Y-%m-%d %H:%M:%f', uploaded_at) DESC, id DESC);
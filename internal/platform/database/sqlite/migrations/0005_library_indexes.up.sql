@@ -0,0 +1,3 @@
+CREATE INDEX metadata_name_idx ON metadata (user_id, lower(file_name), id);
+CREATE INDEX metadata_size_idx ON metadata (user_id, size, id);
+CREATE INDEX metadata_content_type_idx ON metadata (user_id, content_type);
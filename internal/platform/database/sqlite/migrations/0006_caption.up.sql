@@ -0,0 +1 @@
+ALTER TABLE metadata ADD COLUMN caption TEXT NOT NULL DEFAULT '';
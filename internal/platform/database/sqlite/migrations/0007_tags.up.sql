@@ -0,0 +1,14 @@
+CREATE TABLE tags (
+		id INTEGER NOT NULL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		UNIQUE (user_id, name)
+);
+
+CREATE TABLE file_tags (
+		file_id TEXT NOT NULL REFERENCES metadata (id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (file_id, tag_id)
+);
+
+CREATE INDEX file_tags_tag_idx ON file_tags (tag_id, file_id);
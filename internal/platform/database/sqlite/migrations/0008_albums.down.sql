@@ -0,0 +1,2 @@
+DROP TABLE album_files;
+DROP TABLE albums;
@@ -0,0 +1,20 @@
+CREATE TABLE albums (
+		id TEXT NOT NULL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		cover_file_id TEXT REFERENCES metadata (id) ON DELETE SET NULL,
+		created_at DATETIME NOT NULL
+);
+
+CREATE INDEX albums_user_idx ON albums (user_id, created_at);
+
+-- Files are shared by reference, an album never copies the object.
+CREATE TABLE album_files (
+		album_id TEXT NOT NULL REFERENCES albums (id) ON DELETE CASCADE,
+		file_id TEXT NOT NULL REFERENCES metadata (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		PRIMARY KEY (album_id, file_id)
+);
+
+CREATE INDEX album_files_position_idx ON album_files (album_id, position);
+CREATE INDEX album_files_file_idx ON album_files (file_id);
@@ -0,0 +1,2 @@
+DROP INDEX metadata_trash_idx;
+ALTER TABLE metadata DROP COLUMN deleted_at;
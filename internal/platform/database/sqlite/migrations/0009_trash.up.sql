@@ -0,0 +1,2 @@
+ALTER TABLE metadata ADD COLUMN deleted_at DATETIME;
+CREATE INDEX metadata_trash_idx ON metadata (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
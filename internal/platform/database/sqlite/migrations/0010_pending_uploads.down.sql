@@ -0,0 +1,2 @@
+DROP INDEX metadata_pending_idx;
+ALTER TABLE metadata DROP COLUMN pending;
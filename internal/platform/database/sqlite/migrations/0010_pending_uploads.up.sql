@@ -0,0 +1,4 @@
+-- Uploads write a pending row before any object, so one that dies part way
+-- leaves a row to clean up from instead of objects nothing points to.
+ALTER TABLE metadata ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX metadata_pending_idx ON metadata (uploaded_at) WHERE pending;
@@ -0,0 +1,4 @@
+DROP TABLE blobs;
+DROP INDEX metadata_bucket_idx;
+ALTER TABLE metadata DROP COLUMN object_name;
+ALTER TABLE metadata DROP COLUMN bucket;
@@ -0,0 +1,16 @@
+-- Files are stored by content, the original under its SHA-256, in the bucket
+-- of whoever uploaded that content first. blobs counts the files sharing each
+-- copy. Older files keep their objects under their filename and have no blob.
+ALTER TABLE metadata ADD COLUMN bucket TEXT NOT NULL DEFAULT '';
+ALTER TABLE metadata ADD COLUMN object_name TEXT NOT NULL DEFAULT '';
+UPDATE metadata SET
+	object_name = file_name,
+	bucket = COALESCE((SELECT bucket FROM users WHERE users.id = metadata.user_id), '');
+CREATE INDEX metadata_bucket_idx ON metadata (bucket);
+
+CREATE TABLE blobs (
+		sha256 TEXT NOT NULL PRIMARY KEY,
+		bucket TEXT NOT NULL,
+		refs INTEGER NOT NULL,
+		stored BOOLEAN NOT NULL DEFAULT FALSE
+);
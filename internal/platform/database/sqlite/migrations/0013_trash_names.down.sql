@@ -0,0 +1,70 @@
+-- Fails if a trashed file shares its name with another file, as the old
+-- constraint would not allow that.
+
+-- Migrations run in a transaction, where foreign keys cannot be turned off,
+-- so dropping the old table deletes whatever references it. That is put
+-- aside first and put back after.
+CREATE TEMP TABLE saved_exif AS SELECT * FROM exif;
+CREATE TEMP TABLE saved_file_tags AS SELECT * FROM file_tags;
+CREATE TEMP TABLE saved_album_files AS SELECT * FROM album_files;
+CREATE TEMP TABLE saved_album_covers AS SELECT id, cover_file_id FROM albums WHERE cover_file_id IS NOT NULL;
+
+-- The search triggers would go with the table. Dropping the index version
+-- has the next start rebuild the index along with them.
+DROP TRIGGER IF EXISTS metadata_fts_insert;
+DROP TRIGGER IF EXISTS metadata_fts_update;
+DROP TRIGGER IF EXISTS metadata_fts_delete;
+DROP TRIGGER IF EXISTS metadata_fts_exif_insert;
+DROP TRIGGER IF EXISTS metadata_fts_exif_delete;
+DROP TRIGGER IF EXISTS metadata_fts_tags_insert;
+DROP TRIGGER IF EXISTS metadata_fts_tags_delete;
+DROP TRIGGER IF EXISTS metadata_fts_albums_insert;
+DROP TRIGGER IF EXISTS metadata_fts_albums_delete;
+DROP TRIGGER IF EXISTS metadata_fts_albums_rename;
+DROP TABLE IF EXISTS search_index;
+
+CREATE TABLE metadata_new (
+		id TEXT NOT NULL PRIMARY KEY,
+		file_name TEXT NOT NULL,
+		thumb_name TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		content_type TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT 0,
+		uploaded_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+		width INTEGER NOT NULL DEFAULT 0,
+		height INTEGER NOT NULL DEFAULT 0,
+		duration REAL NOT NULL DEFAULT 0,
+		sha256 TEXT NOT NULL DEFAULT '',
+		taken_at DATETIME,
+		caption TEXT NOT NULL DEFAULT '',
+		deleted_at DATETIME,
+		pending BOOLEAN NOT NULL DEFAULT FALSE,
+		bucket TEXT NOT NULL DEFAULT '',
+		object_name TEXT NOT NULL DEFAULT '',
+		wrapped_key TEXT NOT NULL DEFAULT '',
+		encrypted_metadata TEXT NOT NULL DEFAULT '',
+		UNIQUE (file_name, user_id)
+);
+INSERT INTO metadata_new SELECT * FROM metadata;
+DROP TABLE metadata;
+ALTER TABLE metadata_new RENAME TO metadata;
+
+CREATE INDEX metadata_taken_idx ON metadata (user_id, strftime('%Y-%m-%d %H:%M:%f', COALESCE(taken_at, uploaded_at)) DESC, id DESC);
+CREATE INDEX metadata_uploaded_idx ON metadata (user_id, strftime('%Y-%m-%d %H:%M:%f', uploaded_at) DESC, id DESC);
+CREATE INDEX metadata_name_idx ON metadata (user_id, lower(file_name), id);
+CREATE INDEX metadata_size_idx ON metadata (user_id, size, id);
+CREATE INDEX metadata_content_type_idx ON metadata (user_id, content_type);
+CREATE INDEX metadata_trash_idx ON metadata (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX metadata_pending_idx ON metadata (uploaded_at) WHERE pending;
+CREATE INDEX metadata_bucket_idx ON metadata (bucket);
+
+INSERT INTO exif SELECT * FROM saved_exif;
+INSERT INTO file_tags SELECT * FROM saved_file_tags;
+INSERT INTO album_files SELECT * FROM saved_album_files;
+UPDATE albums SET cover_file_id = (SELECT cover_file_id FROM saved_album_covers c WHERE c.id = albums.id)
+WHERE id IN (SELECT id FROM saved_album_covers);
+
+DROP TABLE saved_exif;
+DROP TABLE saved_file_tags;
+DROP TABLE saved_album_files;
+DROP TABLE saved_album_covers;
@@ -0,0 +1,203 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/album"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+// baselineSchema is what NewSQLiteDB created before migrations existed.
+const baselineSchema = `
+CREATE TABLE IF NOT EXISTS users (
+		id TEXT NOT NULL PRIMARY KEY,
+		email TEXT NOT NULL UNIQUE,
+		bucket TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS metadata (
+		id TEXT NOT NULL PRIMARY KEY, 
+		file_name TEXT NOT NULL, 
+		thumb_name TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		UNIQUE (file_name, user_id)
+);
+
+INSERT INTO users (id, email, bucket) VALUES ('u1', 'a@example.com', 'bucket-a');
+INSERT INTO metadata (id, file_name, thumb_name, user_id) VALUES ('f1', 'old.jpg', 'thumb-old.jpg', 'u1');
+`
+
+func TestMigrations_UpgradeBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+
+	conn, err := database.Open(sqlite.ConnectionDetails(path))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := conn.DB.Exec(baselineSchema); err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	conn.Close()
+
+	db, err := sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	defer db.Conn.Close()
+
+	m, err := db.Get(context.Background(), "f1", "u1")
+	if err != nil {
+		t.Fatalf("get existing file: %v", err)
+	}
+	if m.Filename != "old.jpg" {
+		t.Errorf("filename = %q, want %q", m.Filename, "old.jpg")
+	}
+	if m.Bucket != "bucket-a" || m.Object != "old.jpg" {
+		t.Errorf("objects at %s/%s, want the owner's bucket and the filename", m.Bucket, m.Object)
+	}
+	if time.Since(m.UploadedAt) > time.Hour {
+		t.Errorf("uploaded_at = %v, want the time of the upgrade", m.UploadedAt)
+	}
+	if m.ContentType != "" || m.Size != 0 || m.Width != 0 || m.Height != 0 || m.Duration != 0 || m.SHA256 != "" {
+		t.Errorf("existing file has media details %+v, want them left empty", m)
+	}
+
+	u, err := db.GetUser(context.Background(), "a@example.com")
+	if err != nil || u.Bucket != "bucket-a" {
+		t.Errorf("get existing user = %+v, %v", u, err)
+	}
+
+	saved := &fs.Metadata{Id: "f2", Filename: "new.jpg", Thumbname: "thumb-new.jpg", UserId: "u1", UploadedAt: time.Now(),
+		ContentType: "image/jpeg", Size: 1234, Width: 640, Height: 480}
+	if err := db.Save(context.Background(), saved); err != nil {
+		t.Fatalf("save after upgrade: %v", err)
+	}
+	m, err = db.Get(context.Background(), "f2", "u1")
+	if err != nil {
+		t.Fatalf("get new file: %v", err)
+	}
+	if m.ContentType != saved.ContentType || m.Size != saved.Size || m.Width != saved.Width || m.Height != saved.Height {
+		t.Errorf("new file = %+v, want the media details it was saved with", m)
+	}
+}
+
+func TestMigrations_DownUp(t *testing.T) {
+	conn, err := database.NewDBConnection(sqlite.ConnectionDetails(filepath.Join(t.TempDir(), "sqlite.db")))
+	if err != nil {
+		t.Fatalf("new connection: %v", err)
+	}
+	defer conn.Close()
+
+	migrator, err := conn.Migrator()
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+
+	ctx := context.Background()
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %04d_%s not applied", s.Version, s.Name)
+		}
+	}
+
+	if err := migrator.Down(ctx, len(statuses)); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+
+	statuses, err = migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			t.Errorf("migration %04d_%s still applied after down", s.Version, s.Name)
+		}
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+}
+
+func TestMigrations_TrashNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+	db, err := sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := db.Conn.DB.Exec("INSERT INTO users (id, email, bucket) VALUES ('u1', 'a@example.com', 'bucket-a')"); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	for _, m := range []fs.Metadata{
+		{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now(), Exif: &fs.Exif{CameraMake: "Canon"}},
+		{Id: "f2", Filename: "b.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()},
+	} {
+		if err := db.Save(ctx, &m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+	if err := db.TagFiles(ctx, "u1", []string{"f1"}, []string{"beach"}, nil); err != nil {
+		t.Fatalf("TagFiles(): %v", err)
+	}
+	if err := db.CreateAlbum(ctx, &album.Album{Id: "a1", UserId: "u1", Name: "Trip", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateAlbum(): %v", err)
+	}
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", []string{"f1", "f2"}, nil); err != nil {
+		t.Fatalf("UpdateAlbumFiles(): %v", err)
+	}
+	if err := db.SetAlbumCover(ctx, "a1", "u1", "f2"); err != nil {
+		t.Fatalf("SetAlbumCover(): %v", err)
+	}
+
+	// Rolling the rebuild back and forth keeps everything pointing at the
+	// files.
+	migrator, err := db.Conn.Migrator()
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+	if err := migrator.Down(ctx, 1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	db.Conn.Close()
+
+	db, err = sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Conn.Close()
+
+	m, err := db.Get(ctx, "f1", "u1")
+	if err != nil || m.Exif == nil || m.Exif.CameraMake != "Canon" {
+		t.Errorf("Get(f1) = %+v, %v, want its exif", m, err)
+	}
+	if tags, err := db.GetFileTags(ctx, "f1", "u1"); err != nil || len(tags) != 1 || tags[0] != "beach" {
+		t.Errorf("GetFileTags(f1) = %v, %v, want beach", tags, err)
+	}
+	a, err := db.GetAlbum(ctx, "a1", "u1")
+	if err != nil || a.FileCount != 2 || a.CoverId != "f2" {
+		t.Errorf("GetAlbum() = %+v, %v, want both files and f2 as the cover", a, err)
+	}
+
+	results, err := db.Search(ctx, "u1", "beach", 10, 0)
+	if errors.Is(err, fs.ErrSearchUnavailable) {
+		return
+	}
+	if err != nil || len(results) != 1 || results[0].Id != "f1" {
+		t.Errorf("Search() = %+v, %v, want f1", results, err)
+	}
+}
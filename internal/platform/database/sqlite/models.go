@@ -4,11 +4,77 @@
 
 package sqlite
 
+import (
+	"database/sql"
+	"time"
+)
+
+type Album struct {
+	ID          string         `json:"id"`
+	UserID      string         `json:"user_id"`
+	Name        string         `json:"name"`
+	CoverFileID sql.NullString `json:"cover_file_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type AlbumFile struct {
+	AlbumID  string `json:"album_id"`
+	FileID   string `json:"file_id"`
+	Position int64  `json:"position"`
+}
+
+type Blob struct {
+	Sha256 string `json:"sha256"`
+	Bucket string `json:"bucket"`
+	Refs   int64  `json:"refs"`
+	Stored bool   `json:"stored"`
+}
+
+type Exif struct {
+	FileID       string          `json:"file_id"`
+	CameraMake   string          `json:"camera_make"`
+	CameraModel  string          `json:"camera_model"`
+	LensModel    string          `json:"lens_model"`
+	Orientation  int64           `json:"orientation"`
+	ExposureTime string          `json:"exposure_time"`
+	FNumber      float64         `json:"f_number"`
+	Iso          int64           `json:"iso"`
+	FocalLength  float64         `json:"focal_length"`
+	Latitude     sql.NullFloat64 `json:"latitude"`
+	Longitude    sql.NullFloat64 `json:"longitude"`
+}
+
+type FileTag struct {
+	FileID string `json:"file_id"`
+	TagID  int64  `json:"tag_id"`
+}
+
 type Metadata struct {
-	ID        string `json:"id"`
-	FileName  string `json:"file_name"`
-	ThumbName string `json:"thumb_name"`
-	UserID    string `json:"user_id"`
+	ID                string       `json:"id"`
+	FileName          string       `json:"file_name"`
+	ThumbName         string       `json:"thumb_name"`
+	UserID            string       `json:"user_id"`
+	ContentType       string       `json:"content_type"`
+	Size              int64        `json:"size"`
+	UploadedAt        time.Time    `json:"uploaded_at"`
+	Width             int64        `json:"width"`
+	Height            int64        `json:"height"`
+	Duration          float64      `json:"duration"`
+	Sha256            string       `json:"sha256"`
+	TakenAt           sql.NullTime `json:"taken_at"`
+	Caption           string       `json:"caption"`
+	DeletedAt         sql.NullTime `json:"deleted_at"`
+	Pending           bool         `json:"pending"`
+	Bucket            string       `json:"bucket"`
+	ObjectName        string       `json:"object_name"`
+	WrappedKey        string       `json:"wrapped_key"`
+	EncryptedMetadata string       `json:"encrypted_metadata"`
+}
+
+type Tag struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
 }
 
 type User struct {
@@ -16,3 +82,12 @@ type User struct {
 	Email  string `json:"email"`
 	Bucket string `json:"bucket"`
 }
+
+type Vault struct {
+	UserID     string    `json:"user_id"`
+	Kdf        string    `json:"kdf"`
+	KdfParams  string    `json:"kdf_params"`
+	WrappedKey string    `json:"wrapped_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -9,11 +9,52 @@ import (
 )
 
 type Querier interface {
-	DeleteMetadata(ctx context.Context, arg DeleteMetadataParams) error
-	GetAllMetadata(ctx context.Context, userID string) ([]Metadata, error)
+	AcquireBlob(ctx context.Context, arg AcquireBlobParams) (string, error)
+	AddAlbumFile(ctx context.Context, arg AddAlbumFileParams) error
+	AddFileTag(ctx context.Context, arg AddFileTagParams) error
+	ClearRemovedAlbumCover(ctx context.Context, id string) error
+	CommitUpload(ctx context.Context, arg CommitUploadParams) (int64, error)
+	CreateAlbum(ctx context.Context, arg CreateAlbumParams) error
+	CreateVault(ctx context.Context, arg CreateVaultParams) error
+	DeleteAlbum(ctx context.Context, arg DeleteAlbumParams) (int64, error)
+	DeleteBlob(ctx context.Context, sha256 string) error
+	DeleteMetadata(ctx context.Context, arg DeleteMetadataParams) (DeleteMetadataRow, error)
+	DeletePendingMetadata(ctx context.Context, arg DeletePendingMetadataParams) (int64, error)
+	DeleteTag(ctx context.Context, arg DeleteTagParams) (int64, error)
+	DeleteUnusedTags(ctx context.Context, userID string) error
+	GetAlbum(ctx context.Context, arg GetAlbumParams) (GetAlbumRow, error)
+	GetBlob(ctx context.Context, sha256 string) (Blob, error)
+	GetExif(ctx context.Context, fileID string) (Exif, error)
 	GetMetadata(ctx context.Context, arg GetMetadataParams) (Metadata, error)
+	GetPendingMetadata(ctx context.Context, arg GetPendingMetadataParams) (Metadata, error)
+	GetTrashedMetadata(ctx context.Context, arg GetTrashedMetadataParams) (Metadata, error)
 	GetUser(ctx context.Context, email string) (User, error)
+	GetVault(ctx context.Context, userID string) (Vault, error)
+	ListAlbumFileIDs(ctx context.Context, albumID string) ([]string, error)
+	ListAlbumFiles(ctx context.Context, albumID string) ([]Metadata, error)
+	ListAlbums(ctx context.Context, userID string) ([]ListAlbumsRow, error)
+	ListExpiredTrash(ctx context.Context, arg ListExpiredTrashParams) ([]Metadata, error)
+	ListFileTags(ctx context.Context, fileID string) ([]string, error)
+	ListInventory(ctx context.Context, bucket string) ([]Metadata, error)
+	ListStaleUploads(ctx context.Context, arg ListStaleUploadsParams) ([]Metadata, error)
+	ListTags(ctx context.Context, userID string) ([]ListTagsRow, error)
+	ListTrash(ctx context.Context, userID string) ([]Metadata, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	MarkBlobStored(ctx context.Context, arg MarkBlobStoredParams) error
+	NextAlbumPosition(ctx context.Context, albumID string) (int64, error)
+	ReleaseBlob(ctx context.Context, sha256 string) (int64, error)
+	RemoveAlbumFile(ctx context.Context, arg RemoveAlbumFileParams) error
+	RemoveFileTag(ctx context.Context, arg RemoveFileTagParams) error
+	RenameAlbum(ctx context.Context, arg RenameAlbumParams) (int64, error)
+	RestoreMetadata(ctx context.Context, arg RestoreMetadataParams) (int64, error)
+	RewrapVault(ctx context.Context, arg RewrapVaultParams) (int64, error)
+	SaveExif(ctx context.Context, arg SaveExifParams) error
 	SaveMetadata(ctx context.Context, arg SaveMetadataParams) error
+	SetAlbumCover(ctx context.Context, arg SetAlbumCoverParams) (int64, error)
+	SetAlbumFilePosition(ctx context.Context, arg SetAlbumFilePositionParams) error
+	TrashMetadata(ctx context.Context, arg TrashMetadataParams) (int64, error)
+	UpdateCaption(ctx context.Context, arg UpdateCaptionParams) (int64, error)
+	UpsertTag(ctx context.Context, arg UpsertTagParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -4,21 +4,277 @@ WHERE email = ? LIMIT 1;
 
 -- name: SaveMetadata :exec
 INSERT INTO metadata (
-	id, file_name, thumb_name, user_id
+	id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, pending, bucket, object_name, wrapped_key, encrypted_metadata
 ) VALUES (
-	?, ?, ?, ?
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: SaveExif :exec
+INSERT INTO exif (
+	file_id, camera_make, camera_model, lens_model, orientation, exposure_time, f_number, iso, focal_length, latitude, longitude
+) VALUES (
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 );
 
 -- name: GetMetadata :one
 SELECT * FROM metadata 
 WHERE id = ? 
-AND user_id = ? LIMIT 1;
+AND user_id = ?
+AND NOT pending
+AND deleted_at IS NULL LIMIT 1;
 
--- name: GetAllMetadata :many
-SELECT * FROM metadata 
-WHERE user_id = ?;
+-- name: GetExif :one
+SELECT * FROM exif
+WHERE file_id = ? LIMIT 1;
 
--- name: DeleteMetadata :exec
-DELETE FROM metadata 
+-- name: UpdateCaption :execrows
+UPDATE metadata SET caption = ?
+WHERE id = ?
+AND user_id = ?
+AND deleted_at IS NULL
+AND NOT pending;
+
+-- name: UpsertTag :one
+INSERT INTO tags (user_id, name) VALUES (?, ?)
+ON CONFLICT (user_id, name) DO UPDATE SET name = excluded.name
+RETURNING id;
+
+-- name: AddFileTag :exec
+INSERT INTO file_tags (file_id, tag_id) VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveFileTag :exec
+DELETE FROM file_tags
+WHERE file_id = ?
+AND tag_id IN (SELECT id FROM tags WHERE user_id = ? AND name = ?);
+
+-- name: DeleteUnusedTags :exec
+DELETE FROM tags
+WHERE user_id = ?
+AND NOT EXISTS (SELECT 1 FROM file_tags WHERE file_tags.tag_id = tags.id);
+
+-- name: ListTags :many
+SELECT t.name, COUNT(*) AS file_count FROM tags t
+JOIN file_tags ft ON ft.tag_id = t.id
+JOIN metadata m ON m.id = ft.file_id
+WHERE t.user_id = ?
+AND m.deleted_at IS NULL
+GROUP BY t.id, t.name
+ORDER BY t.name;
+
+-- name: ListFileTags :many
+SELECT t.name FROM tags t
+JOIN file_tags ft ON ft.tag_id = t.id
+WHERE ft.file_id = ?
+ORDER BY t.name;
+
+-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE user_id = ?
+AND name = ?;
+
+-- name: CreateAlbum :exec
+INSERT INTO albums (id, user_id, name, created_at) VALUES (?, ?, ?, ?);
+
+-- name: GetAlbum :one
+SELECT
+	a.id,
+	a.user_id,
+	a.name,
+	CAST(COALESCE(
+		(SELECT m.id FROM metadata m WHERE m.id = a.cover_file_id AND m.deleted_at IS NULL),
+		(SELECT af.file_id FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL ORDER BY af.position LIMIT 1),
+		''
+	) AS TEXT) AS cover_id,
+	(SELECT COUNT(*) FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL) AS file_count,
+	a.created_at
+FROM albums a
+WHERE a.id = ?
+AND a.user_id = ?;
+
+-- name: ListAlbums :many
+SELECT
+	a.id,
+	a.user_id,
+	a.name,
+	CAST(COALESCE(
+		(SELECT m.id FROM metadata m WHERE m.id = a.cover_file_id AND m.deleted_at IS NULL),
+		(SELECT af.file_id FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL ORDER BY af.position LIMIT 1),
+		''
+	) AS TEXT) AS cover_id,
+	(SELECT COUNT(*) FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL) AS file_count,
+	a.created_at
+FROM albums a
+WHERE a.user_id = ?
+ORDER BY a.created_at DESC, a.id;
+
+-- name: RenameAlbum :execrows
+UPDATE albums SET name = ?
+WHERE id = ?
+AND user_id = ?;
+
+-- name: SetAlbumCover :execrows
+UPDATE albums SET cover_file_id = ?
 WHERE id = ?
 AND user_id = ?;
+
+-- name: ClearRemovedAlbumCover :exec
+UPDATE albums SET cover_file_id = NULL
+WHERE id = ?
+AND NOT EXISTS (SELECT 1 FROM album_files af WHERE af.album_id = albums.id AND af.file_id = albums.cover_file_id);
+
+-- name: DeleteAlbum :execrows
+DELETE FROM albums
+WHERE id = ?
+AND user_id = ?;
+
+-- name: NextAlbumPosition :one
+SELECT CAST(COALESCE(MAX(position), -1) + 1 AS INTEGER) FROM album_files
+WHERE album_id = ?;
+
+-- name: AddAlbumFile :exec
+INSERT INTO album_files (album_id, file_id, position) VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveAlbumFile :exec
+DELETE FROM album_files
+WHERE album_id = ?
+AND file_id = ?;
+
+-- name: ListAlbumFileIDs :many
+SELECT af.file_id FROM album_files af
+JOIN metadata m ON m.id = af.file_id
+WHERE af.album_id = ?
+AND m.deleted_at IS NULL
+ORDER BY af.position;
+
+-- name: SetAlbumFilePosition :exec
+UPDATE album_files SET position = ?
+WHERE album_id = ?
+AND file_id = ?;
+
+-- name: ListAlbumFiles :many
+SELECT m.id, m.file_name, m.thumb_name, m.user_id, m.content_type, m.size, m.uploaded_at, m.width, m.height, m.duration, m.sha256, m.taken_at, m.caption, m.deleted_at, m.pending, m.bucket, m.object_name, m.wrapped_key, m.encrypted_metadata FROM metadata m
+JOIN album_files af ON af.file_id = m.id
+WHERE af.album_id = ?
+AND m.deleted_at IS NULL
+ORDER BY af.position;
+
+-- name: TrashMetadata :execrows
+UPDATE metadata SET deleted_at = ?
+WHERE id = ?
+AND user_id = ?
+AND deleted_at IS NULL
+AND NOT pending;
+
+-- name: RestoreMetadata :execrows
+UPDATE metadata SET deleted_at = NULL
+WHERE id = ?
+AND user_id = ?
+AND deleted_at IS NOT NULL;
+
+-- name: ListTrash :many
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata
+WHERE user_id = ?
+AND deleted_at IS NOT NULL
+AND NOT pending
+ORDER BY deleted_at DESC, id;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY id;
+
+-- name: CommitUpload :execrows
+UPDATE metadata SET pending = FALSE
+WHERE id = ?
+AND user_id = ?
+AND pending;
+
+-- name: DeleteMetadata :one
+DELETE FROM metadata
+WHERE id = ?
+AND user_id = ?
+RETURNING object_name, sha256;
+
+-- name: ListExpiredTrash :many
+SELECT * FROM metadata
+WHERE deleted_at IS NOT NULL
+AND strftime('%Y-%m-%d %H:%M:%f', deleted_at) < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg(cutoff))
+ORDER BY deleted_at, id
+LIMIT sqlc.arg(batch_size);
+
+-- name: ListInventory :many
+SELECT * FROM metadata
+WHERE bucket = ?
+ORDER BY id;
+
+-- name: ListStaleUploads :many
+SELECT * FROM metadata
+WHERE pending
+AND strftime('%Y-%m-%d %H:%M:%f', uploaded_at) < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg(cutoff))
+ORDER BY uploaded_at, id
+LIMIT sqlc.arg(batch_size);
+
+-- name: AcquireBlob :one
+INSERT INTO blobs (sha256, bucket, refs) VALUES (?, ?, 1)
+ON CONFLICT (sha256) DO UPDATE SET refs = blobs.refs + 1
+RETURNING bucket;
+
+-- name: ReleaseBlob :one
+UPDATE blobs SET refs = refs - 1
+WHERE sha256 = ?
+RETURNING refs;
+
+-- name: DeleteBlob :exec
+DELETE FROM blobs
+WHERE sha256 = ?
+AND refs <= 0;
+
+-- name: GetBlob :one
+SELECT * FROM blobs
+WHERE sha256 = ?;
+
+-- name: MarkBlobStored :exec
+UPDATE blobs SET stored = TRUE
+WHERE sha256 = (
+	SELECT sha256 FROM metadata
+	WHERE id = ?
+	AND user_id = ?
+	AND object_name = sha256
+);
+
+-- name: GetVault :one
+SELECT * FROM vaults
+WHERE user_id = ?;
+
+-- name: GetPendingMetadata :one
+SELECT * FROM metadata 
+WHERE id = ? 
+AND user_id = ?
+AND pending LIMIT 1;
+
+-- name: DeletePendingMetadata :execrows
+DELETE FROM metadata
+WHERE id = ?
+AND user_id = ?
+AND pending;
+
+-- name: CreateVault :exec
+INSERT INTO vaults (user_id, kdf, kdf_params, wrapped_key, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: RewrapVault :execrows
+UPDATE vaults SET
+	kdf = sqlc.arg(kdf),
+	kdf_params = sqlc.arg(kdf_params),
+	wrapped_key = sqlc.arg(wrapped_key),
+	updated_at = sqlc.arg(updated_at)
+WHERE user_id = sqlc.arg(user_id)
+AND wrapped_key = sqlc.arg(previous_wrapped_key);
+
+-- name: GetTrashedMetadata :one
+SELECT * FROM metadata 
+WHERE id = ? 
+AND user_id = ?
+AND NOT pending
+AND deleted_at IS NOT NULL LIMIT 1;
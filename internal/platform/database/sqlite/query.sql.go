@@ -7,31 +7,842 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
-const deleteMetadata = `-- name: DeleteMetadata :exec
-DELETE FROM metadata 
+const acquireBlob = `-- name: AcquireBlob :one
+INSERT INTO blobs (sha256, bucket, refs) VALUES (?, ?, 1)
+ON CONFLICT (sha256) DO UPDATE SET refs = blobs.refs + 1
+RETURNING bucket
+`
+
+type AcquireBlobParams struct {
+	Sha256 string `json:"sha256"`
+	Bucket string `json:"bucket"`
+}
+
+func (q *Queries) AcquireBlob(ctx context.Context, arg AcquireBlobParams) (string, error) {
+	row := q.queryRow(ctx, q.acquireBlobStmt, acquireBlob, arg.Sha256, arg.Bucket)
+	var bucket string
+	err := row.Scan(&bucket)
+	return bucket, err
+}
+
+const addAlbumFile = `-- name: AddAlbumFile :exec
+INSERT INTO album_files (album_id, file_id, position) VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING
+`
+
+type AddAlbumFileParams struct {
+	AlbumID  string `json:"album_id"`
+	FileID   string `json:"file_id"`
+	Position int64  `json:"position"`
+}
+
+func (q *Queries) AddAlbumFile(ctx context.Context, arg AddAlbumFileParams) error {
+	_, err := q.exec(ctx, q.addAlbumFileStmt, addAlbumFile, arg.AlbumID, arg.FileID, arg.Position)
+	return err
+}
+
+const addFileTag = `-- name: AddFileTag :exec
+INSERT INTO file_tags (file_id, tag_id) VALUES (?, ?)
+ON CONFLICT DO NOTHING
+`
+
+type AddFileTagParams struct {
+	FileID string `json:"file_id"`
+	TagID  int64  `json:"tag_id"`
+}
+
+func (q *Queries) AddFileTag(ctx context.Context, arg AddFileTagParams) error {
+	_, err := q.exec(ctx, q.addFileTagStmt, addFileTag, arg.FileID, arg.TagID)
+	return err
+}
+
+const clearRemovedAlbumCover = `-- name: ClearRemovedAlbumCover :exec
+UPDATE albums SET cover_file_id = NULL
+WHERE id = ?
+AND NOT EXISTS (SELECT 1 FROM album_files af WHERE af.album_id = albums.id AND af.file_id = albums.cover_file_id)
+`
+
+func (q *Queries) ClearRemovedAlbumCover(ctx context.Context, id string) error {
+	_, err := q.exec(ctx, q.clearRemovedAlbumCoverStmt, clearRemovedAlbumCover, id)
+	return err
+}
+
+const commitUpload = `-- name: CommitUpload :execrows
+UPDATE metadata SET pending = FALSE
+WHERE id = ?
+AND user_id = ?
+AND pending
+`
+
+type CommitUploadParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) CommitUpload(ctx context.Context, arg CommitUploadParams) (int64, error) {
+	result, err := q.exec(ctx, q.commitUploadStmt, commitUpload, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createAlbum = `-- name: CreateAlbum :exec
+INSERT INTO albums (id, user_id, name, created_at) VALUES (?, ?, ?, ?)
+`
+
+type CreateAlbumParams struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) CreateAlbum(ctx context.Context, arg CreateAlbumParams) error {
+	_, err := q.exec(ctx, q.createAlbumStmt, createAlbum, arg.ID, arg.UserID, arg.Name, arg.CreatedAt)
+	return err
+}
+
+const createVault = `-- name: CreateVault :exec
+INSERT INTO vaults (user_id, kdf, kdf_params, wrapped_key, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateVaultParams struct {
+	UserID     string    `json:"user_id"`
+	Kdf        string    `json:"kdf"`
+	KdfParams  string    `json:"kdf_params"`
+	WrappedKey string    `json:"wrapped_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (q *Queries) CreateVault(ctx context.Context, arg CreateVaultParams) error {
+	_, err := q.exec(ctx, q.createVaultStmt, createVault, arg.UserID, arg.Kdf, arg.KdfParams, arg.WrappedKey, arg.CreatedAt, arg.UpdatedAt)
+	return err
+}
+
+const deleteAlbum = `-- name: DeleteAlbum :execrows
+DELETE FROM albums
 WHERE id = ?
 AND user_id = ?
 `
 
-type DeleteMetadataParams struct {
-	ID     string `json:"id"`
-	UserID string `json:"user_id"`
+type DeleteAlbumParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) DeleteAlbum(ctx context.Context, arg DeleteAlbumParams) (int64, error) {
+	result, err := q.exec(ctx, q.deleteAlbumStmt, deleteAlbum, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteBlob = `-- name: DeleteBlob :exec
+DELETE FROM blobs
+WHERE sha256 = ?
+AND refs <= 0
+`
+
+func (q *Queries) DeleteBlob(ctx context.Context, sha256 string) error {
+	_, err := q.exec(ctx, q.deleteBlobStmt, deleteBlob, sha256)
+	return err
+}
+
+const deleteMetadata = `-- name: DeleteMetadata :one
+DELETE FROM metadata
+WHERE id = ?
+AND user_id = ?
+RETURNING object_name, sha256
+`
+
+type DeleteMetadataParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+type DeleteMetadataRow struct {
+	ObjectName string `json:"object_name"`
+	Sha256     string `json:"sha256"`
+}
+
+func (q *Queries) DeleteMetadata(ctx context.Context, arg DeleteMetadataParams) (DeleteMetadataRow, error) {
+	row := q.queryRow(ctx, q.deleteMetadataStmt, deleteMetadata, arg.ID, arg.UserID)
+	var i DeleteMetadataRow
+	err := row.Scan(&i.ObjectName, &i.Sha256)
+	return i, err
+}
+
+const deletePendingMetadata = `-- name: DeletePendingMetadata :execrows
+DELETE FROM metadata
+WHERE id = ?
+AND user_id = ?
+AND pending
+`
+
+type DeletePendingMetadataParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) DeletePendingMetadata(ctx context.Context, arg DeletePendingMetadataParams) (int64, error) {
+	result, err := q.exec(ctx, q.deletePendingMetadataStmt, deletePendingMetadata, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteTag = `-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE user_id = ?
+AND name = ?
+`
+
+type DeleteTagParams struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) DeleteTag(ctx context.Context, arg DeleteTagParams) (int64, error) {
+	result, err := q.exec(ctx, q.deleteTagStmt, deleteTag, arg.UserID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUnusedTags = `-- name: DeleteUnusedTags :exec
+DELETE FROM tags
+WHERE user_id = ?
+AND NOT EXISTS (SELECT 1 FROM file_tags WHERE file_tags.tag_id = tags.id)
+`
+
+func (q *Queries) DeleteUnusedTags(ctx context.Context, userID string) error {
+	_, err := q.exec(ctx, q.deleteUnusedTagsStmt, deleteUnusedTags, userID)
+	return err
+}
+
+const getAlbum = `-- name: GetAlbum :one
+SELECT
+	a.id,
+	a.user_id,
+	a.name,
+	CAST(COALESCE(
+		(SELECT m.id FROM metadata m WHERE m.id = a.cover_file_id AND m.deleted_at IS NULL),
+		(SELECT af.file_id FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL ORDER BY af.position LIMIT 1),
+		''
+	) AS TEXT) AS cover_id,
+	(SELECT COUNT(*) FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL) AS file_count,
+	a.created_at
+FROM albums a
+WHERE a.id = ?
+AND a.user_id = ?
+`
+
+type GetAlbumParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+type GetAlbumRow struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CoverID   string    `json:"cover_id"`
+	FileCount int64     `json:"file_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) GetAlbum(ctx context.Context, arg GetAlbumParams) (GetAlbumRow, error) {
+	row := q.queryRow(ctx, q.getAlbumStmt, getAlbum, arg.ID, arg.UserID)
+	var i GetAlbumRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.CoverID,
+		&i.FileCount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getBlob = `-- name: GetBlob :one
+SELECT sha256, bucket, refs, stored FROM blobs
+WHERE sha256 = ?
+`
+
+func (q *Queries) GetBlob(ctx context.Context, sha256 string) (Blob, error) {
+	row := q.queryRow(ctx, q.getBlobStmt, getBlob, sha256)
+	var i Blob
+	err := row.Scan(
+		&i.Sha256,
+		&i.Bucket,
+		&i.Refs,
+		&i.Stored,
+	)
+	return i, err
+}
+
+const getExif = `-- name: GetExif :one
+SELECT file_id, camera_make, camera_model, lens_model, orientation, exposure_time, f_number, iso, focal_length, latitude, longitude FROM exif
+WHERE file_id = ? LIMIT 1
+`
+
+func (q *Queries) GetExif(ctx context.Context, fileID string) (Exif, error) {
+	row := q.queryRow(ctx, q.getExifStmt, getExif, fileID)
+	var i Exif
+	err := row.Scan(
+		&i.FileID,
+		&i.CameraMake,
+		&i.CameraModel,
+		&i.LensModel,
+		&i.Orientation,
+		&i.ExposureTime,
+		&i.FNumber,
+		&i.Iso,
+		&i.FocalLength,
+		&i.Latitude,
+		&i.Longitude,
+	)
+	return i, err
+}
+
+const getMetadata = `-- name: GetMetadata :one
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata 
+WHERE id = ? 
+AND user_id = ?
+AND NOT pending
+AND deleted_at IS NULL LIMIT 1
+`
+
+type GetMetadataParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetMetadata(ctx context.Context, arg GetMetadataParams) (Metadata, error) {
+	row := q.queryRow(ctx, q.getMetadataStmt, getMetadata, arg.ID, arg.UserID)
+	var i Metadata
+	err := row.Scan(
+		&i.ID,
+		&i.FileName,
+		&i.ThumbName,
+		&i.UserID,
+		&i.ContentType,
+		&i.Size,
+		&i.UploadedAt,
+		&i.Width,
+		&i.Height,
+		&i.Duration,
+		&i.Sha256,
+		&i.TakenAt,
+		&i.Caption,
+		&i.DeletedAt,
+		&i.Pending,
+		&i.Bucket,
+		&i.ObjectName,
+		&i.WrappedKey,
+		&i.EncryptedMetadata,
+	)
+	return i, err
+}
+
+const getPendingMetadata = `-- name: GetPendingMetadata :one
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata 
+WHERE id = ? 
+AND user_id = ?
+AND pending LIMIT 1
+`
+
+type GetPendingMetadataParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetPendingMetadata(ctx context.Context, arg GetPendingMetadataParams) (Metadata, error) {
+	row := q.queryRow(ctx, q.getPendingMetadataStmt, getPendingMetadata, arg.ID, arg.UserID)
+	var i Metadata
+	err := row.Scan(
+		&i.ID,
+		&i.FileName,
+		&i.ThumbName,
+		&i.UserID,
+		&i.ContentType,
+		&i.Size,
+		&i.UploadedAt,
+		&i.Width,
+		&i.Height,
+		&i.Duration,
+		&i.Sha256,
+		&i.TakenAt,
+		&i.Caption,
+		&i.DeletedAt,
+		&i.Pending,
+		&i.Bucket,
+		&i.ObjectName,
+		&i.WrappedKey,
+		&i.EncryptedMetadata,
+	)
+	return i, err
+}
+
+const getTrashedMetadata = `-- name: GetTrashedMetadata :one
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata 
+WHERE id = ? 
+AND user_id = ?
+AND NOT pending
+AND deleted_at IS NOT NULL LIMIT 1
+`
+
+type GetTrashedMetadataParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetTrashedMetadata(ctx context.Context, arg GetTrashedMetadataParams) (Metadata, error) {
+	row := q.queryRow(ctx, q.getTrashedMetadataStmt, getTrashedMetadata, arg.ID, arg.UserID)
+	var i Metadata
+	err := row.Scan(
+		&i.ID,
+		&i.FileName,
+		&i.ThumbName,
+		&i.UserID,
+		&i.ContentType,
+		&i.Size,
+		&i.UploadedAt,
+		&i.Width,
+		&i.Height,
+		&i.Duration,
+		&i.Sha256,
+		&i.TakenAt,
+		&i.Caption,
+		&i.DeletedAt,
+		&i.Pending,
+		&i.Bucket,
+		&i.ObjectName,
+		&i.WrappedKey,
+		&i.EncryptedMetadata,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, email, bucket FROM users 
+WHERE email = ? LIMIT 1
+`
+
+func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
+	row := q.queryRow(ctx, q.getUserStmt, getUser, email)
+	var i User
+	err := row.Scan(&i.ID, &i.Email, &i.Bucket)
+	return i, err
+}
+
+const getVault = `-- name: GetVault :one
+SELECT user_id, kdf, kdf_params, wrapped_key, created_at, updated_at FROM vaults
+WHERE user_id = ?
+`
+
+func (q *Queries) GetVault(ctx context.Context, userID string) (Vault, error) {
+	row := q.queryRow(ctx, q.getVaultStmt, getVault, userID)
+	var i Vault
+	err := row.Scan(
+		&i.UserID,
+		&i.Kdf,
+		&i.KdfParams,
+		&i.WrappedKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listAlbumFileIDs = `-- name: ListAlbumFileIDs :many
+SELECT af.file_id FROM album_files af
+JOIN metadata m ON m.id = af.file_id
+WHERE af.album_id = ?
+AND m.deleted_at IS NULL
+ORDER BY af.position
+`
+
+func (q *Queries) ListAlbumFileIDs(ctx context.Context, albumID string) ([]string, error) {
+	rows, err := q.query(ctx, q.listAlbumFileIDsStmt, listAlbumFileIDs, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var file_id string
+		if err := rows.Scan(&file_id); err != nil {
+			return nil, err
+		}
+		items = append(items, file_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAlbumFiles = `-- name: ListAlbumFiles :many
+SELECT m.id, m.file_name, m.thumb_name, m.user_id, m.content_type, m.size, m.uploaded_at, m.width, m.height, m.duration, m.sha256, m.taken_at, m.caption, m.deleted_at, m.pending, m.bucket, m.object_name, m.wrapped_key, m.encrypted_metadata FROM metadata m
+JOIN album_files af ON af.file_id = m.id
+WHERE af.album_id = ?
+AND m.deleted_at IS NULL
+ORDER BY af.position
+`
+
+func (q *Queries) ListAlbumFiles(ctx context.Context, albumID string) ([]Metadata, error) {
+	rows, err := q.query(ctx, q.listAlbumFilesStmt, listAlbumFiles, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Metadata
+	for rows.Next() {
+		var i Metadata
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileName,
+			&i.ThumbName,
+			&i.UserID,
+			&i.ContentType,
+			&i.Size,
+			&i.UploadedAt,
+			&i.Width,
+			&i.Height,
+			&i.Duration,
+			&i.Sha256,
+			&i.TakenAt,
+			&i.Caption,
+			&i.DeletedAt,
+			&i.Pending,
+			&i.Bucket,
+			&i.ObjectName,
+			&i.WrappedKey,
+			&i.EncryptedMetadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAlbums = `-- name: ListAlbums :many
+SELECT
+	a.id,
+	a.user_id,
+	a.name,
+	CAST(COALESCE(
+		(SELECT m.id FROM metadata m WHERE m.id = a.cover_file_id AND m.deleted_at IS NULL),
+		(SELECT af.file_id FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL ORDER BY af.position LIMIT 1),
+		''
+	) AS TEXT) AS cover_id,
+	(SELECT COUNT(*) FROM album_files af JOIN metadata m ON m.id = af.file_id WHERE af.album_id = a.id AND m.deleted_at IS NULL) AS file_count,
+	a.created_at
+FROM albums a
+WHERE a.user_id = ?
+ORDER BY a.created_at DESC, a.id
+`
+
+type ListAlbumsRow struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CoverID   string    `json:"cover_id"`
+	FileCount int64     `json:"file_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) ListAlbums(ctx context.Context, userID string) ([]ListAlbumsRow, error) {
+	rows, err := q.query(ctx, q.listAlbumsStmt, listAlbums, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAlbumsRow
+	for rows.Next() {
+		var i ListAlbumsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.CoverID,
+			&i.FileCount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExpiredTrash = `-- name: ListExpiredTrash :many
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata
+WHERE deleted_at IS NOT NULL
+AND strftime('%Y-%m-%d %H:%M:%f', deleted_at) < strftime('%Y-%m-%d %H:%M:%f', ?)
+ORDER BY deleted_at, id
+LIMIT ?
+`
+
+type ListExpiredTrashParams struct {
+	Cutoff    interface{} `json:"cutoff"`
+	BatchSize int64       `json:"batch_size"`
+}
+
+func (q *Queries) ListExpiredTrash(ctx context.Context, arg ListExpiredTrashParams) ([]Metadata, error) {
+	rows, err := q.query(ctx, q.listExpiredTrashStmt, listExpiredTrash, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Metadata
+	for rows.Next() {
+		var i Metadata
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileName,
+			&i.ThumbName,
+			&i.UserID,
+			&i.ContentType,
+			&i.Size,
+			&i.UploadedAt,
+			&i.Width,
+			&i.Height,
+			&i.Duration,
+			&i.Sha256,
+			&i.TakenAt,
+			&i.Caption,
+			&i.DeletedAt,
+			&i.Pending,
+			&i.Bucket,
+			&i.ObjectName,
+			&i.WrappedKey,
+			&i.EncryptedMetadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFileTags = `-- name: ListFileTags :many
+SELECT t.name FROM tags t
+JOIN file_tags ft ON ft.tag_id = t.id
+WHERE ft.file_id = ?
+ORDER BY t.name
+`
+
+func (q *Queries) ListFileTags(ctx context.Context, fileID string) ([]string, error) {
+	rows, err := q.query(ctx, q.listFileTagsStmt, listFileTags, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) DeleteMetadata(ctx context.Context, arg DeleteMetadataParams) error {
-	_, err := q.exec(ctx, q.deleteMetadataStmt, deleteMetadata, arg.ID, arg.UserID)
-	return err
+const listInventory = `-- name: ListInventory :many
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata
+WHERE bucket = ?
+ORDER BY id
+`
+
+func (q *Queries) ListInventory(ctx context.Context, bucket string) ([]Metadata, error) {
+	rows, err := q.query(ctx, q.listInventoryStmt, listInventory, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Metadata
+	for rows.Next() {
+		var i Metadata
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileName,
+			&i.ThumbName,
+			&i.UserID,
+			&i.ContentType,
+			&i.Size,
+			&i.UploadedAt,
+			&i.Width,
+			&i.Height,
+			&i.Duration,
+			&i.Sha256,
+			&i.TakenAt,
+			&i.Caption,
+			&i.DeletedAt,
+			&i.Pending,
+			&i.Bucket,
+			&i.ObjectName,
+			&i.WrappedKey,
+			&i.EncryptedMetadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStaleUploads = `-- name: ListStaleUploads :many
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata
+WHERE pending
+AND strftime('%Y-%m-%d %H:%M:%f', uploaded_at) < strftime('%Y-%m-%d %H:%M:%f', ?)
+ORDER BY uploaded_at, id
+LIMIT ?
+`
+
+type ListStaleUploadsParams struct {
+	Cutoff    interface{} `json:"cutoff"`
+	BatchSize int64       `json:"batch_size"`
+}
+
+func (q *Queries) ListStaleUploads(ctx context.Context, arg ListStaleUploadsParams) ([]Metadata, error) {
+	rows, err := q.query(ctx, q.listStaleUploadsStmt, listStaleUploads, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Metadata
+	for rows.Next() {
+		var i Metadata
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileName,
+			&i.ThumbName,
+			&i.UserID,
+			&i.ContentType,
+			&i.Size,
+			&i.UploadedAt,
+			&i.Width,
+			&i.Height,
+			&i.Duration,
+			&i.Sha256,
+			&i.TakenAt,
+			&i.Caption,
+			&i.DeletedAt,
+			&i.Pending,
+			&i.Bucket,
+			&i.ObjectName,
+			&i.WrappedKey,
+			&i.EncryptedMetadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTags = `-- name: ListTags :many
+SELECT t.name, COUNT(*) AS file_count FROM tags t
+JOIN file_tags ft ON ft.tag_id = t.id
+JOIN metadata m ON m.id = ft.file_id
+WHERE t.user_id = ?
+AND m.deleted_at IS NULL
+GROUP BY t.id, t.name
+ORDER BY t.name
+`
+
+type ListTagsRow struct {
+	Name      string `json:"name"`
+	FileCount int64  `json:"file_count"`
+}
+
+func (q *Queries) ListTags(ctx context.Context, userID string) ([]ListTagsRow, error) {
+	rows, err := q.query(ctx, q.listTagsStmt, listTags, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagsRow
+	for rows.Next() {
+		var i ListTagsRow
+		if err := rows.Scan(&i.Name, &i.FileCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getAllMetadata = `-- name: GetAllMetadata :many
-SELECT id, file_name, thumb_name, user_id FROM metadata 
+const listTrash = `-- name: ListTrash :many
+SELECT id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, caption, deleted_at, pending, bucket, object_name, wrapped_key, encrypted_metadata FROM metadata
 WHERE user_id = ?
+AND deleted_at IS NOT NULL
+AND NOT pending
+ORDER BY deleted_at DESC, id
 `
 
-func (q *Queries) GetAllMetadata(ctx context.Context, userID string) ([]Metadata, error) {
-	rows, err := q.query(ctx, q.getAllMetadataStmt, getAllMetadata, userID)
+func (q *Queries) ListTrash(ctx context.Context, userID string) ([]Metadata, error) {
+	rows, err := q.query(ctx, q.listTrashStmt, listTrash, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -44,6 +855,21 @@ func (q *Queries) GetAllMetadata(ctx context.Context, userID string) ([]Metadata
 			&i.FileName,
 			&i.ThumbName,
 			&i.UserID,
+			&i.ContentType,
+			&i.Size,
+			&i.UploadedAt,
+			&i.Width,
+			&i.Height,
+			&i.Duration,
+			&i.Sha256,
+			&i.TakenAt,
+			&i.Caption,
+			&i.DeletedAt,
+			&i.Pending,
+			&i.Bucket,
+			&i.ObjectName,
+			&i.WrappedKey,
+			&i.EncryptedMetadata,
 		); err != nil {
 			return nil, err
 		}
@@ -58,54 +884,244 @@ func (q *Queries) GetAllMetadata(ctx context.Context, userID string) ([]Metadata
 	return items, nil
 }
 
-const getMetadata = `-- name: GetMetadata :one
-SELECT id, file_name, thumb_name, user_id FROM metadata 
-WHERE id = ? 
-AND user_id = ? LIMIT 1
+const listUsers = `-- name: ListUsers :many
+SELECT id, email, bucket FROM users
+ORDER BY id
 `
 
-type GetMetadataParams struct {
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.query(ctx, q.listUsersStmt, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(&i.ID, &i.Email, &i.Bucket); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markBlobStored = `-- name: MarkBlobStored :exec
+UPDATE blobs SET stored = TRUE
+WHERE sha256 = (
+	SELECT sha256 FROM metadata
+	WHERE id = ?
+	AND user_id = ?
+	AND object_name = sha256
+)
+`
+
+type MarkBlobStoredParams struct {
 	ID     string `json:"id"`
 	UserID string `json:"user_id"`
 }
 
-func (q *Queries) GetMetadata(ctx context.Context, arg GetMetadataParams) (Metadata, error) {
-	row := q.queryRow(ctx, q.getMetadataStmt, getMetadata, arg.ID, arg.UserID)
-	var i Metadata
-	err := row.Scan(
-		&i.ID,
-		&i.FileName,
-		&i.ThumbName,
-		&i.UserID,
-	)
-	return i, err
+func (q *Queries) MarkBlobStored(ctx context.Context, arg MarkBlobStoredParams) error {
+	_, err := q.exec(ctx, q.markBlobStoredStmt, markBlobStored, arg.ID, arg.UserID)
+	return err
 }
 
-const getUser = `-- name: GetUser :one
-SELECT id, email, bucket FROM users 
-WHERE email = ? LIMIT 1
+const nextAlbumPosition = `-- name: NextAlbumPosition :one
+SELECT CAST(COALESCE(MAX(position), -1) + 1 AS INTEGER) FROM album_files
+WHERE album_id = ?
 `
 
-func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
-	row := q.queryRow(ctx, q.getUserStmt, getUser, email)
-	var i User
-	err := row.Scan(&i.ID, &i.Email, &i.Bucket)
-	return i, err
+func (q *Queries) NextAlbumPosition(ctx context.Context, albumID string) (int64, error) {
+	row := q.queryRow(ctx, q.nextAlbumPositionStmt, nextAlbumPosition, albumID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const releaseBlob = `-- name: ReleaseBlob :one
+UPDATE blobs SET refs = refs - 1
+WHERE sha256 = ?
+RETURNING refs
+`
+
+func (q *Queries) ReleaseBlob(ctx context.Context, sha256 string) (int64, error) {
+	row := q.queryRow(ctx, q.releaseBlobStmt, releaseBlob, sha256)
+	var refs int64
+	err := row.Scan(&refs)
+	return refs, err
+}
+
+const removeAlbumFile = `-- name: RemoveAlbumFile :exec
+DELETE FROM album_files
+WHERE album_id = ?
+AND file_id = ?
+`
+
+type RemoveAlbumFileParams struct {
+	AlbumID string `json:"album_id"`
+	FileID  string `json:"file_id"`
+}
+
+func (q *Queries) RemoveAlbumFile(ctx context.Context, arg RemoveAlbumFileParams) error {
+	_, err := q.exec(ctx, q.removeAlbumFileStmt, removeAlbumFile, arg.AlbumID, arg.FileID)
+	return err
+}
+
+const removeFileTag = `-- name: RemoveFileTag :exec
+DELETE FROM file_tags
+WHERE file_id = ?
+AND tag_id IN (SELECT id FROM tags WHERE user_id = ? AND name = ?)
+`
+
+type RemoveFileTagParams struct {
+	FileID string `json:"file_id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) RemoveFileTag(ctx context.Context, arg RemoveFileTagParams) error {
+	_, err := q.exec(ctx, q.removeFileTagStmt, removeFileTag, arg.FileID, arg.UserID, arg.Name)
+	return err
+}
+
+const renameAlbum = `-- name: RenameAlbum :execrows
+UPDATE albums SET name = ?
+WHERE id = ?
+AND user_id = ?
+`
+
+type RenameAlbumParams struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) RenameAlbum(ctx context.Context, arg RenameAlbumParams) (int64, error) {
+	result, err := q.exec(ctx, q.renameAlbumStmt, renameAlbum, arg.Name, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreMetadata = `-- name: RestoreMetadata :execrows
+UPDATE metadata SET deleted_at = NULL
+WHERE id = ?
+AND user_id = ?
+AND deleted_at IS NOT NULL
+`
+
+type RestoreMetadataParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) RestoreMetadata(ctx context.Context, arg RestoreMetadataParams) (int64, error) {
+	result, err := q.exec(ctx, q.restoreMetadataStmt, restoreMetadata, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rewrapVault = `-- name: RewrapVault :execrows
+UPDATE vaults SET
+	kdf = ?,
+	kdf_params = ?,
+	wrapped_key = ?,
+	updated_at = ?
+WHERE user_id = ?
+AND wrapped_key = ?
+`
+
+type RewrapVaultParams struct {
+	Kdf                string    `json:"kdf"`
+	KdfParams          string    `json:"kdf_params"`
+	WrappedKey         string    `json:"wrapped_key"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	UserID             string    `json:"user_id"`
+	PreviousWrappedKey string    `json:"previous_wrapped_key"`
+}
+
+func (q *Queries) RewrapVault(ctx context.Context, arg RewrapVaultParams) (int64, error) {
+	result, err := q.exec(ctx, q.rewrapVaultStmt, rewrapVault, arg.Kdf, arg.KdfParams, arg.WrappedKey, arg.UpdatedAt, arg.UserID, arg.PreviousWrappedKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const saveExif = `-- name: SaveExif :exec
+INSERT INTO exif (
+	file_id, camera_make, camera_model, lens_model, orientation, exposure_time, f_number, iso, focal_length, latitude, longitude
+) VALUES (
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type SaveExifParams struct {
+	FileID       string          `json:"file_id"`
+	CameraMake   string          `json:"camera_make"`
+	CameraModel  string          `json:"camera_model"`
+	LensModel    string          `json:"lens_model"`
+	Orientation  int64           `json:"orientation"`
+	ExposureTime string          `json:"exposure_time"`
+	FNumber      float64         `json:"f_number"`
+	Iso          int64           `json:"iso"`
+	FocalLength  float64         `json:"focal_length"`
+	Latitude     sql.NullFloat64 `json:"latitude"`
+	Longitude    sql.NullFloat64 `json:"longitude"`
+}
+
+func (q *Queries) SaveExif(ctx context.Context, arg SaveExifParams) error {
+	_, err := q.exec(ctx, q.saveExifStmt, saveExif,
+		arg.FileID,
+		arg.CameraMake,
+		arg.CameraModel,
+		arg.LensModel,
+		arg.Orientation,
+		arg.ExposureTime,
+		arg.FNumber,
+		arg.Iso,
+		arg.FocalLength,
+		arg.Latitude,
+		arg.Longitude,
+	)
+	return err
 }
 
 const saveMetadata = `-- name: SaveMetadata :exec
 INSERT INTO metadata (
-	id, file_name, thumb_name, user_id
+	id, file_name, thumb_name, user_id, content_type, size, uploaded_at, width, height, duration, sha256, taken_at, pending, bucket, object_name, wrapped_key, encrypted_metadata
 ) VALUES (
-	?, ?, ?, ?
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 `
 
 type SaveMetadataParams struct {
-	ID        string `json:"id"`
-	FileName  string `json:"file_name"`
-	ThumbName string `json:"thumb_name"`
-	UserID    string `json:"user_id"`
+	ID                string       `json:"id"`
+	FileName          string       `json:"file_name"`
+	ThumbName         string       `json:"thumb_name"`
+	UserID            string       `json:"user_id"`
+	ContentType       string       `json:"content_type"`
+	Size              int64        `json:"size"`
+	UploadedAt        time.Time    `json:"uploaded_at"`
+	Width             int64        `json:"width"`
+	Height            int64        `json:"height"`
+	Duration          float64      `json:"duration"`
+	Sha256            string       `json:"sha256"`
+	TakenAt           sql.NullTime `json:"taken_at"`
+	Pending           bool         `json:"pending"`
+	Bucket            string       `json:"bucket"`
+	ObjectName        string       `json:"object_name"`
+	WrappedKey        string       `json:"wrapped_key"`
+	EncryptedMetadata string       `json:"encrypted_metadata"`
 }
 
 func (q *Queries) SaveMetadata(ctx context.Context, arg SaveMetadataParams) error {
@@ -114,6 +1130,118 @@ func (q *Queries) SaveMetadata(ctx context.Context, arg SaveMetadataParams) erro
 		arg.FileName,
 		arg.ThumbName,
 		arg.UserID,
+		arg.ContentType,
+		arg.Size,
+		arg.UploadedAt,
+		arg.Width,
+		arg.Height,
+		arg.Duration,
+		arg.Sha256,
+		arg.TakenAt,
+		arg.Pending,
+		arg.Bucket,
+		arg.ObjectName,
+		arg.WrappedKey,
+		arg.EncryptedMetadata,
 	)
 	return err
 }
+
+const setAlbumCover = `-- name: SetAlbumCover :execrows
+UPDATE albums SET cover_file_id = ?
+WHERE id = ?
+AND user_id = ?
+`
+
+type SetAlbumCoverParams struct {
+	CoverFileID sql.NullString `json:"cover_file_id"`
+	ID          string         `json:"id"`
+	UserID      string         `json:"user_id"`
+}
+
+func (q *Queries) SetAlbumCover(ctx context.Context, arg SetAlbumCoverParams) (int64, error) {
+	result, err := q.exec(ctx, q.setAlbumCoverStmt, setAlbumCover, arg.CoverFileID, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setAlbumFilePosition = `-- name: SetAlbumFilePosition :exec
+UPDATE album_files SET position = ?
+WHERE album_id = ?
+AND file_id = ?
+`
+
+type SetAlbumFilePositionParams struct {
+	Position int64  `json:"position"`
+	AlbumID  string `json:"album_id"`
+	FileID   string `json:"file_id"`
+}
+
+func (q *Queries) SetAlbumFilePosition(ctx context.Context, arg SetAlbumFilePositionParams) error {
+	_, err := q.exec(ctx, q.setAlbumFilePositionStmt, setAlbumFilePosition, arg.Position, arg.AlbumID, arg.FileID)
+	return err
+}
+
+const trashMetadata = `-- name: TrashMetadata :execrows
+UPDATE metadata SET deleted_at = ?
+WHERE id = ?
+AND user_id = ?
+AND deleted_at IS NULL
+AND NOT pending
+`
+
+type TrashMetadataParams struct {
+	DeletedAt sql.NullTime `json:"deleted_at"`
+	ID        string       `json:"id"`
+	UserID    string       `json:"user_id"`
+}
+
+func (q *Queries) TrashMetadata(ctx context.Context, arg TrashMetadataParams) (int64, error) {
+	result, err := q.exec(ctx, q.trashMetadataStmt, trashMetadata, arg.DeletedAt, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateCaption = `-- name: UpdateCaption :execrows
+UPDATE metadata SET caption = ?
+WHERE id = ?
+AND user_id = ?
+AND deleted_at IS NULL
+AND NOT pending
+`
+
+type UpdateCaptionParams struct {
+	Caption string `json:"caption"`
+	ID      string `json:"id"`
+	UserID  string `json:"user_id"`
+}
+
+func (q *Queries) UpdateCaption(ctx context.Context, arg UpdateCaptionParams) (int64, error) {
+	result, err := q.exec(ctx, q.updateCaptionStmt, updateCaption, arg.Caption, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertTag = `-- name: UpsertTag :one
+INSERT INTO tags (user_id, name) VALUES (?, ?)
+ON CONFLICT (user_id, name) DO UPDATE SET name = excluded.name
+RETURNING id
+`
+
+type UpsertTagParams struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) UpsertTag(ctx context.Context, arg UpsertTagParams) (int64, error) {
+	row := q.queryRow(ctx, q.upsertTagStmt, upsertTag, arg.UserID, arg.Name)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
@@ -0,0 +1,268 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// searchIndexVersion is bumped whenever searchSchema changes. The index only
+// holds copies of other tables, so a version mismatch drops and rebuilds it
+// rather than migrating it.
+const searchIndexVersion = 3
+
+// fileTagNames and fileAlbumNames are completed with a WHERE on the file_id
+// of ft and af.
+const (
+	fileTagNames   = `SELECT group_concat(t.name, ' ') FROM file_tags ft JOIN tags t ON t.id = ft.tag_id`
+	fileAlbumNames = `SELECT group_concat(a.name, ' ') FROM album_files af JOIN albums a ON a.id = af.album_id`
+)
+
+// The FTS5 table is kept out of the migrations because go-sqlite3 only has
+// FTS5 when built with the sqlite_fts5 tag, and a build without it should
+// still be able to use the database.
+const searchSchema = `
+CREATE VIRTUAL TABLE metadata_fts USING fts5(
+	file_id UNINDEXED,
+	file_name,
+	caption,
+	tags,
+	albums,
+	camera,
+	taken,
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER metadata_fts_insert AFTER INSERT ON metadata BEGIN
+	INSERT INTO metadata_fts (file_id, file_name, caption, tags, albums, camera, taken)
+	VALUES (NEW.id, NEW.file_name, NEW.caption, '', '', '', strftime('%Y-%m-%d', COALESCE(NEW.taken_at, NEW.uploaded_at)));
+END;
+
+CREATE TRIGGER metadata_fts_update AFTER UPDATE OF file_name, caption, taken_at, uploaded_at ON metadata BEGIN
+	UPDATE metadata_fts SET
+		file_name = NEW.file_name,
+		caption = NEW.caption,
+		taken = strftime('%Y-%m-%d', COALESCE(NEW.taken_at, NEW.uploaded_at))
+	WHERE file_id = NEW.id;
+END;
+
+CREATE TRIGGER metadata_fts_delete AFTER DELETE ON metadata BEGIN
+	DELETE FROM metadata_fts WHERE file_id = OLD.id;
+END;
+
+CREATE TRIGGER metadata_fts_exif_insert AFTER INSERT ON exif BEGIN
+	UPDATE metadata_fts SET camera = NEW.camera_make || ' ' || NEW.camera_model || ' ' || NEW.lens_model
+	WHERE file_id = NEW.file_id;
+END;
+
+CREATE TRIGGER metadata_fts_exif_delete AFTER DELETE ON exif BEGIN
+	UPDATE metadata_fts SET camera = '' WHERE file_id = OLD.file_id;
+END;
+
+CREATE TRIGGER metadata_fts_tags_insert AFTER INSERT ON file_tags BEGIN
+	UPDATE metadata_fts SET tags = (` + fileTagNames + ` WHERE ft.file_id = NEW.file_id)
+	WHERE file_id = NEW.file_id;
+END;
+
+CREATE TRIGGER metadata_fts_tags_delete AFTER DELETE ON file_tags BEGIN
+	UPDATE metadata_fts SET tags = COALESCE((` + fileTagNames + ` WHERE ft.file_id = OLD.file_id), '')
+	WHERE file_id = OLD.file_id;
+END;
+
+CREATE TRIGGER metadata_fts_albums_insert AFTER INSERT ON album_files BEGIN
+	UPDATE metadata_fts SET albums = (` + fileAlbumNames + ` WHERE af.file_id = NEW.file_id)
+	WHERE file_id = NEW.file_id;
+END;
+
+CREATE TRIGGER metadata_fts_albums_delete AFTER DELETE ON album_files BEGIN
+	UPDATE metadata_fts SET albums = COALESCE((` + fileAlbumNames + ` WHERE af.file_id = OLD.file_id), '')
+	WHERE file_id = OLD.file_id;
+END;
+
+CREATE TRIGGER metadata_fts_albums_rename AFTER UPDATE OF name ON albums BEGIN
+	UPDATE metadata_fts SET albums = COALESCE((` + fileAlbumNames + ` WHERE af.file_id = metadata_fts.file_id), '')
+	WHERE file_id IN (SELECT file_id FROM album_files WHERE album_id = NEW.id);
+END;
+
+INSERT INTO metadata_fts (file_id, file_name, caption, tags, albums, camera, taken)
+SELECT
+	m.id,
+	m.file_name,
+	m.caption,
+	COALESCE((` + fileTagNames + ` WHERE ft.file_id = m.id), ''),
+	COALESCE((` + fileAlbumNames + ` WHERE af.file_id = m.id), ''),
+	COALESCE(e.camera_make || ' ' || e.camera_model || ' ' || e.lens_model, ''),
+	strftime('%Y-%m-%d', COALESCE(m.taken_at, m.uploaded_at))
+FROM metadata m
+LEFT JOIN exif e ON e.file_id = m.id;
+`
+
+// dropSearchTriggers leaves the index to itself. Unlike the index, the
+// triggers can be dropped without FTS5, and have to be for a build without it
+// to write to the tables they are on.
+const dropSearchTriggers = `
+DROP TRIGGER IF EXISTS metadata_fts_insert;
+DROP TRIGGER IF EXISTS metadata_fts_update;
+DROP TRIGGER IF EXISTS metadata_fts_delete;
+DROP TRIGGER IF EXISTS metadata_fts_exif_insert;
+DROP TRIGGER IF EXISTS metadata_fts_exif_delete;
+DROP TRIGGER IF EXISTS metadata_fts_tags_insert;
+DROP TRIGGER IF EXISTS metadata_fts_tags_delete;
+DROP TRIGGER IF EXISTS metadata_fts_albums_insert;
+DROP TRIGGER IF EXISTS metadata_fts_albums_delete;
+DROP TRIGGER IF EXISTS metadata_fts_albums_rename;
+`
+
+const dropSearchSchema = dropSearchTriggers + `
+DROP TABLE IF EXISTS metadata_fts;
+`
+
+// setupSearch builds the search index if this binary has FTS5 and the index
+// is missing or out of date. It reports whether search is available.
+func setupSearch(ctx context.Context, db *sql.DB) (bool, error) {
+	var enabled bool
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return false, fmt.Errorf("check for fts5: %w", err)
+	}
+	if !enabled {
+		return false, dropSearch(ctx, db)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS search_index (version INTEGER NOT NULL)"); err != nil {
+		return false, fmt.Errorf("create search_index: %w", err)
+	}
+
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM search_index").Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("read search index version: %w", err)
+	}
+	if version == searchIndexVersion {
+		return true, nil
+	}
+
+	for _, stmt := range []string{dropSearchSchema, searchSchema, "DELETE FROM search_index"} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return false, fmt.Errorf("build search index: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO search_index (version) VALUES (?)", searchIndexVersion); err != nil {
+		return false, fmt.Errorf("record search index version: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// dropSearch takes down what a build with FTS5 left behind, so a build
+// without it can write. The index misses those writes from then on, clearing
+// search_index has the next build with FTS5 rebuild it.
+func dropSearch(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{dropSearchTriggers, "DROP TABLE IF EXISTS search_index"} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("drop search index: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) Search(ctx context.Context, userId, query string, limit, offset int) ([]fs.SearchResult, error) {
+	if !db.search {
+		return nil, fs.ErrSearchUnavailable
+	}
+
+	match := matchExpression(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	// bm25 takes a weight per column, file_id first. A match in the name or
+	// caption counts for more than one in the camera or date.
+	const rank = "bm25(metadata_fts, 0, 10, 8, 6, 6, 3, 2)"
+	rows, err := db.Conn.DB.QueryContext(ctx, `SELECT `+prefixColumns("m", metadataColumns)+`,
+		snippet(metadata_fts, -1, ?, ?, '…', 12), -`+rank+`
+		FROM metadata_fts
+		JOIN metadata m ON m.id = metadata_fts.file_id
+		WHERE metadata_fts MATCH ? AND m.user_id = ? AND m.deleted_at IS NULL AND NOT m.pending
+		ORDER BY `+rank+`, m.id
+		LIMIT ? OFFSET ?`,
+		fs.HighlightStart, fs.HighlightEnd, match, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []fs.SearchResult
+	for rows.Next() {
+		var m Metadata
+		var r fs.SearchResult
+		if err := rows.Scan(
+			&m.ID,
+			&m.FileName,
+			&m.ThumbName,
+			&m.UserID,
+			&m.ContentType,
+			&m.Size,
+			&m.UploadedAt,
+			&m.Width,
+			&m.Height,
+			&m.Duration,
+			&m.Sha256,
+			&m.TakenAt,
+			&m.Caption,
+			&m.DeletedAt,
+			&m.WrappedKey,
+			&m.EncryptedMetadata,
+			&r.Snippet,
+			&r.Rank,
+		); err != nil {
+			return nil, err
+		}
+		r.Metadata = toMetadata(m)
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// matchExpression turns what a user typed into an FTS5 query that matches
+// every word as a prefix, so "beach 2023" finds "Beaches" taken in 2023.
+// Everything but letters and digits is dropped, which keeps FTS5 syntax out.
+func matchExpression(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = `"` + w + `"*`
+	}
+
+	return strings.Join(terms, " ")
+}
+
+func prefixColumns(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+
+	return strings.Join(cols, ", ")
+}
@@ -0,0 +1,177 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/album"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+// Search needs FTS5, run with: go test -tags sqlite_fts5 ./...
+func TestSQLiteDB_Search(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+	db, err := sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.Search(ctx, "u1", "anything", 10, 0); errors.Is(err, fs.ErrSearchUnavailable) {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+
+	taken2023 := time.Date(2023, 7, 4, 10, 0, 0, 0, time.UTC)
+	files := []fs.Metadata{
+		{Id: "f1", Filename: "IMG_0001.jpg", UserId: "u1", UploadedAt: time.Now(), TakenAt: &taken2023,
+			Exif: &fs.Exif{CameraMake: "Apple", CameraModel: "iPhone 15 Pro"}},
+		{Id: "f2", Filename: "beaches-2024.jpg", UserId: "u1", UploadedAt: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{Id: "f3", Filename: "beach.jpg", UserId: "u2", UploadedAt: time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for i := range files {
+		if err := db.Save(ctx, &files[i]); err != nil {
+			t.Fatalf("Save(%s): %v", files[i].Id, err)
+		}
+	}
+
+	if err := db.UpdateCaption(ctx, "f1", "u1", "Sunset at the beach"); err != nil {
+		t.Fatalf("UpdateCaption(): %v", err)
+	}
+	if err := db.TagFiles(ctx, "u1", []string{"f2"}, []string{"vacation"}, nil); err != nil {
+		t.Fatalf("TagFiles(): %v", err)
+	}
+	if err := db.CreateAlbum(ctx, &album.Album{Id: "a1", UserId: "u1", Name: "Hawaii", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateAlbum(): %v", err)
+	}
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", []string{"f2"}, nil); err != nil {
+		t.Fatalf("UpdateAlbumFiles(): %v", err)
+	}
+
+	search := func(query string) []fs.SearchResult {
+		t.Helper()
+		results, err := db.Search(ctx, "u1", query, 10, 0)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		return results
+	}
+	ids := func(results []fs.SearchResult) []string {
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.Id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "beach", want: []string{"f1", "f2"}},
+		{query: "beach 2023", want: []string{"f1"}},
+		{query: "iphone", want: []string{"f1"}},
+		{query: "vacation", want: []string{"f2"}},
+		{query: "hawaii", want: []string{"f2"}},
+		{query: `"beach*) NEAR(`, want: nil},
+		{query: `beach*) (`, want: []string{"f1", "f2"}},
+		{query: "  ", want: nil},
+	}
+	for _, tt := range tests {
+		if got := ids(search(tt.query)); !slices.Equal(sortedCopy(got), tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	if err := db.RenameAlbum(ctx, "a1", "u1", "Maui"); err != nil {
+		t.Fatalf("RenameAlbum(): %v", err)
+	}
+	if got := ids(search("maui")); !slices.Equal(got, []string{"f2"}) {
+		t.Errorf("Search(maui) after rename = %v, want [f2]", got)
+	}
+
+	if err := db.Trash(ctx, "f2", "u1", time.Now()); err != nil {
+		t.Fatalf("Trash(): %v", err)
+	}
+	if got := ids(search("maui")); got != nil {
+		t.Errorf("Search(maui) after trash = %v, want none", got)
+	}
+	if err := db.Restore(ctx, "f2", "u1"); err != nil {
+		t.Fatalf("Restore(): %v", err)
+	}
+
+	results := search("sunset")
+	if len(results) != 1 || !strings.Contains(results[0].Snippet, fs.HighlightStart+"Sunset"+fs.HighlightEnd) {
+		t.Errorf("Search(sunset) snippet = %+v", results)
+	}
+
+	if _, err := db.Delete(ctx, "f1", "u1"); err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+	if got := ids(search("beach")); !slices.Equal(got, []string{"f2"}) {
+		t.Errorf("Search() after delete = %v, want [f2]", got)
+	}
+
+	// Reopening must keep the index rather than rebuild or duplicate it.
+	db.Conn.Close()
+	db, err = sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Conn.Close()
+	if got := ids(search("beach")); !slices.Equal(got, []string{"f2"}) {
+		t.Errorf("Search() after reopen = %v, want [f2]", got)
+	}
+}
+
+func sortedCopy(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+func TestSQLiteDB_WithoutSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+	db, err := sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := db.Search(ctx, "u1", "anything", 10, 0); !errors.Is(err, fs.ErrSearchUnavailable) {
+		db.Conn.Close()
+		t.Skip("built with the sqlite_fts5 tag")
+	}
+
+	// What a build with FTS5 leaves behind, a trigger into an index this
+	// build cannot write to.
+	if _, err := db.Conn.DB.Exec(`
+		CREATE TABLE search_index (version INTEGER NOT NULL);
+		INSERT INTO search_index (version) VALUES (3);
+		CREATE TRIGGER metadata_fts_insert AFTER INSERT ON metadata BEGIN
+			INSERT INTO metadata_fts (file_id) VALUES (NEW.id);
+		END;`); err != nil {
+		t.Fatalf("create search leftovers: %v", err)
+	}
+	db.Conn.Close()
+
+	db, err = sqlite.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Conn.Close()
+
+	if err := db.Save(ctx, &fs.Metadata{Id: "f1", Filename: "a.jpg", UserId: "u1", UploadedAt: time.Now()}); err != nil {
+		t.Errorf("Save() after opening without FTS5: %v", err)
+	}
+	var n int
+	if err := db.Conn.DB.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'search_index'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("search_index left in place (%d, %v), an FTS5 build would not rebuild the index", n, err)
+	}
+}
@@ -1,31 +1,66 @@
 package sqlite
 
 import (
-	_ "embed"
+	"context"
+	"embed"
 	"fmt"
+	"io/fs"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/portbound/go-fs/internal/platform/database"
 )
 
-//go:embed schema.sql
-var schema string
+//go:embed migrations/*.sql
+var migrations embed.FS
 
 const DriverName string = "sqlite3"
 
 type SQLiteDB struct {
 	*Queries
 	Conn *database.DBConnection
+	// search is false when the binary was built without the sqlite_fts5 tag.
+	search bool
 }
 
 func NewSQLiteDB(connStr string) (*SQLiteDB, error) {
-	conn, err := database.NewDBConnection(&database.DBConnectionDetails{
-		DriverName: DriverName,
-		ConnStr:    connStr,
-		Schema:     schema,
-	})
+	conn, err := database.NewDBConnection(ConnectionDetails(connStr))
 	if err != nil {
 		return nil, fmt.Errorf("create new sqlite connection: %w", err)
 	}
-	return &SQLiteDB{Queries: New(conn.DB), Conn: conn}, nil
+
+	search, err := setupSearch(context.Background(), conn.DB)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("set up search: %w", err)
+	}
+
+	return &SQLiteDB{Queries: New(conn.DB), Conn: conn, search: search}, nil
+}
+
+func ConnectionDetails(connStr string) *database.DBConnectionDetails {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	return &database.DBConnectionDetails{
+		DriverName: DriverName,
+		ConnStr:    withForeignKeys(connStr),
+		Migrations: sub,
+	}
+}
+
+// withForeignKeys turns on foreign key enforcement, which SQLite leaves off per
+// connection, so ON DELETE CASCADE clauses in the schema take effect.
+func withForeignKeys(connStr string) string {
+	if strings.Contains(connStr, "_foreign_keys=") || strings.Contains(connStr, "_fk=") {
+		return connStr
+	}
+
+	if strings.Contains(connStr, "?") {
+		return connStr + "&_foreign_keys=on"
+	}
+
+	return connStr + "?_foreign_keys=on"
 }
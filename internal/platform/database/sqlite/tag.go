@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/portbound/go-fs/internal/tag"
+)
+
+func (db *SQLiteDB) TagFiles(ctx context.Context, userId string, fileIds, add, remove []string) error {
+	tx, err := db.Conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.Queries.WithTx(tx)
+	for _, id := range fileIds {
+		if _, err := qtx.GetMetadata(ctx, GetMetadataParams{ID: id, UserID: userId}); err != nil {
+			return fmt.Errorf("get file %q: %w", id, err)
+		}
+	}
+
+	for _, name := range add {
+		tagId, err := qtx.UpsertTag(ctx, UpsertTagParams{UserID: userId, Name: name})
+		if err != nil {
+			return fmt.Errorf("create tag %q: %w", name, err)
+		}
+
+		for _, id := range fileIds {
+			if err := qtx.AddFileTag(ctx, AddFileTagParams{FileID: id, TagID: tagId}); err != nil {
+				return fmt.Errorf("tag file %q: %w", id, err)
+			}
+		}
+	}
+
+	for _, name := range remove {
+		for _, id := range fileIds {
+			if err := qtx.RemoveFileTag(ctx, RemoveFileTagParams{FileID: id, UserID: userId, Name: name}); err != nil {
+				return fmt.Errorf("untag file %q: %w", id, err)
+			}
+		}
+	}
+
+	if len(remove) > 0 {
+		if err := qtx.DeleteUnusedTags(ctx, userId); err != nil {
+			return fmt.Errorf("delete unused tags: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) GetTags(ctx context.Context, userId string) ([]tag.Tag, error) {
+	rows, err := db.Queries.ListTags(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]tag.Tag, len(rows))
+	for i, row := range rows {
+		tags[i] = tag.Tag{Name: row.Name, Count: row.FileCount}
+	}
+
+	return tags, nil
+}
+
+func (db *SQLiteDB) GetFileTags(ctx context.Context, fileId, userId string) ([]string, error) {
+	if _, err := db.Queries.GetMetadata(ctx, GetMetadataParams{ID: fileId, UserID: userId}); err != nil {
+		return nil, err
+	}
+
+	return db.Queries.ListFileTags(ctx, fileId)
+}
+
+func (db *SQLiteDB) DeleteTag(ctx context.Context, userId, name string) error {
+	n, err := db.Queries.DeleteTag(ctx, DeleteTagParams{UserID: userId, Name: name})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+	"github.com/portbound/go-fs/internal/tag"
+)
+
+func TestSQLiteDB_Tags(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	for _, m := range []fs.Metadata{
+		{Id: "f1", Filename: "a.jpg", UserId: "u1", UploadedAt: time.Now()},
+		{Id: "f2", Filename: "b.jpg", UserId: "u1", UploadedAt: time.Now()},
+		{Id: "f3", Filename: "c.jpg", UserId: "u2", UploadedAt: time.Now()},
+	} {
+		if err := db.Save(ctx, &m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+
+	if err := db.TagFiles(ctx, "u1", []string{"f1", "f2"}, []string{"beach", "road trip"}, nil); err != nil {
+		t.Fatalf("TagFiles(): %v", err)
+	}
+	if err := db.TagFiles(ctx, "u1", []string{"f2"}, []string{"beach"}, []string{"road trip"}); err != nil {
+		t.Fatalf("TagFiles() again: %v", err)
+	}
+	if err := db.TagFiles(ctx, "u2", []string{"f3"}, []string{"beach"}, nil); err != nil {
+		t.Fatalf("TagFiles() for u2: %v", err)
+	}
+
+	if err := db.TagFiles(ctx, "u1", []string{"f1", "f3"}, []string{"stolen"}, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("TagFiles() with another user's file err = %v, want sql.ErrNoRows", err)
+	}
+
+	tags, err := db.GetTags(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetTags(): %v", err)
+	}
+	want := []tag.Tag{{Name: "beach", Count: 2}, {Name: "road trip", Count: 1}}
+	if !slices.Equal(tags, want) {
+		t.Errorf("GetTags() = %v, want %v", tags, want)
+	}
+
+	names, err := db.GetFileTags(ctx, "f1", "u1")
+	if err != nil || !slices.Equal(names, []string{"beach", "road trip"}) {
+		t.Errorf("GetFileTags(f1) = %v, %v", names, err)
+	}
+	if _, err := db.GetFileTags(ctx, "f3", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFileTags() of another user's file err = %v, want sql.ErrNoRows", err)
+	}
+
+	ids := func(filter fs.Filter) []string {
+		t.Helper()
+		files, err := db.GetAll(ctx, "u1", fs.ListOptions{Filter: filter, SortBy: fs.SortByName, Ascending: true, Limit: 10})
+		if err != nil {
+			t.Fatalf("GetAll(): %v", err)
+		}
+		var ids []string
+		for _, f := range files {
+			ids = append(ids, f.Id)
+		}
+		return ids
+	}
+	if got := ids(fs.Filter{Tags: []string{"beach"}}); !slices.Equal(got, []string{"f1", "f2"}) {
+		t.Errorf("GetAll(tag:beach) = %v, want [f1 f2]", got)
+	}
+	if got := ids(fs.Filter{Tags: []string{"beach", "road trip"}}); !slices.Equal(got, []string{"f1"}) {
+		t.Errorf("GetAll(tag:beach tag:road trip) = %v, want [f1]", got)
+	}
+
+	// Removing the last use of a tag deletes it.
+	if err := db.TagFiles(ctx, "u1", []string{"f1"}, nil, []string{"road trip"}); err != nil {
+		t.Fatalf("TagFiles() remove: %v", err)
+	}
+	if tags, _ := db.GetTags(ctx, "u1"); !slices.Equal(tags, []tag.Tag{{Name: "beach", Count: 2}}) {
+		t.Errorf("GetTags() after removal = %v", tags)
+	}
+
+	if err := db.DeleteTag(ctx, "u1", "beach"); err != nil {
+		t.Fatalf("DeleteTag(): %v", err)
+	}
+	if err := db.DeleteTag(ctx, "u1", "beach"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteTag() twice err = %v, want sql.ErrNoRows", err)
+	}
+	if got := ids(fs.Filter{Tags: []string{"beach"}}); got != nil {
+		t.Errorf("GetAll(tag:beach) after delete = %v, want none", got)
+	}
+	if tags, _ := db.GetTags(ctx, "u2"); !slices.Equal(tags, []tag.Tag{{Name: "beach", Count: 1}}) {
+		t.Errorf("GetTags(u2) = %v, other users' tags must be untouched", tags)
+	}
+
+	// Deleting a file drops its tags with it.
+	if _, err := db.Delete(ctx, "f3", "u2"); err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+	var n int
+	if err := db.Conn.DB.QueryRow("SELECT COUNT(*) FROM file_tags WHERE file_id = 'f3'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("file_tags rows after delete = %d, %v, want 0", n, err)
+	}
+}
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+func (db *SQLiteDB) Trash(ctx context.Context, id, userId string, at time.Time) error {
+	params := TrashMetadataParams{
+		DeletedAt: sql.NullTime{Time: at.UTC(), Valid: true},
+		ID:        id,
+		UserID:    userId,
+	}
+
+	n, err := db.Queries.TrashMetadata(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) Restore(ctx context.Context, id, userId string) error {
+	n, err := db.Queries.RestoreMetadata(ctx, RestoreMetadataParams{ID: id, UserID: userId})
+	if err != nil {
+		// Another file took the name while this one was in the trash.
+		if isUniqueViolation(err) {
+			return fs.ErrFileExists
+		}
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) GetTrashed(ctx context.Context, id, userId string) (*fs.Metadata, error) {
+	m, err := db.Queries.GetTrashedMetadata(ctx, GetTrashedMetadataParams{ID: id, UserID: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := toMetadata(m)
+	return &meta, nil
+}
+
+func (db *SQLiteDB) GetTrash(ctx context.Context, userId string) ([]fs.Metadata, error) {
+	rows, err := db.Queries.ListTrash(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]fs.Metadata, len(rows))
+	for i, row := range rows {
+		files[i] = toMetadata(row)
+	}
+
+	return files, nil
+}
+
+func (db *SQLiteDB) GetExpiredTrash(ctx context.Context, cutoff time.Time, limit int) ([]fs.Metadata, error) {
+	rows, err := db.Queries.ListExpiredTrash(ctx, ListExpiredTrashParams{Cutoff: cutoff.UTC(), BatchSize: int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]fs.Metadata, len(rows))
+	for i, row := range rows {
+		files[i] = toMetadata(row)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,142 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/album"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+func TestSQLiteDB_Trash(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.Conn.DB.Exec("INSERT INTO users (id, email, bucket) VALUES ('u1', 'a@example.com', 'bucket-a')"); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	for _, m := range []fs.Metadata{
+		{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()},
+		{Id: "f2", Filename: "b.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()},
+		{Id: "f3", Filename: "c.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()},
+	} {
+		if err := db.Save(ctx, &m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+	if err := db.TagFiles(ctx, "u1", []string{"f1"}, []string{"beach"}, nil); err != nil {
+		t.Fatalf("TagFiles(): %v", err)
+	}
+	if err := db.CreateAlbum(ctx, &album.Album{Id: "a1", UserId: "u1", Name: "Trip", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateAlbum(): %v", err)
+	}
+	if err := db.UpdateAlbumFiles(ctx, "a1", "u1", []string{"f1", "f2"}, nil); err != nil {
+		t.Fatalf("UpdateAlbumFiles(): %v", err)
+	}
+
+	trashedAt := time.Now().Add(-48 * time.Hour)
+	if err := db.Trash(ctx, "f1", "u1", trashedAt); err != nil {
+		t.Fatalf("Trash(f1): %v", err)
+	}
+	if err := db.Trash(ctx, "f2", "u1", time.Now()); err != nil {
+		t.Fatalf("Trash(f2): %v", err)
+	}
+	if err := db.Trash(ctx, "f1", "u1", time.Now()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Trash() of a trashed file err = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.Trash(ctx, "f3", "u2", time.Now()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Trash() of another user's file err = %v, want sql.ErrNoRows", err)
+	}
+
+	// A pending upload is not a file yet, it cannot be trashed or changed.
+	// p2 was trashed before that was refused, and stays out of the trash.
+	for _, m := range []fs.Metadata{
+		{Id: "p1", Filename: "p1.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now(), Pending: true},
+		{Id: "p2", Filename: "p2.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now(), Pending: true},
+	} {
+		if err := db.Save(ctx, &m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+	if err := db.Trash(ctx, "p1", "u1", time.Now()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Trash() of a pending file err = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := db.Conn.DB.Exec("UPDATE metadata SET deleted_at = ? WHERE id = 'p2'", time.Now().UTC()); err != nil {
+		t.Fatalf("trash p2: %v", err)
+	}
+	for _, id := range []string{"f1", "p1"} {
+		if err := db.UpdateCaption(ctx, id, "u1", "caption"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UpdateCaption(%s) err = %v, want sql.ErrNoRows", id, err)
+		}
+	}
+
+	if _, err := db.Get(ctx, "f1", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get() of a trashed file err = %v, want sql.ErrNoRows", err)
+	}
+	m, err := db.GetTrashed(ctx, "f1", "u1")
+	if err != nil || m.DeletedAt == nil || !m.DeletedAt.Equal(trashedAt) {
+		t.Errorf("GetTrashed(f1) = %+v, %v, want DeletedAt %v", m, err, trashedAt)
+	}
+	if _, err := db.GetTrashed(ctx, "f3", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTrashed() of a file outside the trash err = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.TagFiles(ctx, "u1", []string{"f1"}, []string{"sea"}, nil); err == nil {
+		t.Error("TagFiles() of a trashed file succeeded")
+	}
+
+	files, err := db.GetAll(ctx, "u1", fs.ListOptions{Limit: 10})
+	if err != nil || len(files) != 1 || files[0].Id != "f3" {
+		t.Errorf("GetAll() = %v, %v, want only f3", files, err)
+	}
+	if n, err := db.Count(ctx, "u1", fs.Filter{}); err != nil || n != 1 {
+		t.Errorf("Count() = %d, %v, want 1", n, err)
+	}
+	if tags, err := db.GetTags(ctx, "u1"); err != nil || len(tags) != 0 {
+		t.Errorf("GetTags() = %v, %v, want none for trashed files", tags, err)
+	}
+	a, err := db.GetAlbum(ctx, "a1", "u1")
+	if err != nil || a.FileCount != 0 || a.CoverId != "" {
+		t.Errorf("GetAlbum() = %+v, %v, want no visible files", a, err)
+	}
+
+	trash, err := db.GetTrash(ctx, "u1")
+	if err != nil || len(trash) != 2 || trash[0].Id != "f2" || trash[1].Id != "f1" {
+		t.Errorf("GetTrash() = %v, %v, want f2 then f1", trash, err)
+	}
+
+	expired, err := db.GetExpiredTrash(ctx, time.Now().Add(-24*time.Hour), 10)
+	if err != nil || len(expired) != 1 || expired[0].Id != "f1" || expired[0].Bucket != "bucket-a" {
+		t.Errorf("GetExpiredTrash() = %+v, %v, want f1 in bucket-a", expired, err)
+	}
+
+	if err := db.Restore(ctx, "f2", "u1"); err != nil {
+		t.Fatalf("Restore(f2): %v", err)
+	}
+	if err := db.Restore(ctx, "f3", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Restore() of a file outside the trash err = %v, want sql.ErrNoRows", err)
+	}
+	a, err = db.GetAlbum(ctx, "a1", "u1")
+	if err != nil || a.FileCount != 1 || a.CoverId != "f2" {
+		t.Errorf("GetAlbum() after restore = %+v, %v, want f2 as the only file", a, err)
+	}
+
+	// The trashed file's name is free to take, which keeps it in the trash.
+	if err := db.Save(ctx, &fs.Metadata{Id: "f4", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()}); err != nil {
+		t.Fatalf("Save() of a trashed file's name: %v", err)
+	}
+	if err := db.Save(ctx, &fs.Metadata{Id: "f5", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now()}); !errors.Is(err, fs.ErrFileExists) {
+		t.Errorf("Save() of a taken name err = %v, want %v", err, fs.ErrFileExists)
+	}
+	if err := db.Restore(ctx, "f1", "u1"); !errors.Is(err, fs.ErrFileExists) {
+		t.Errorf("Restore() of a file whose name was taken err = %v, want %v", err, fs.ErrFileExists)
+	}
+}
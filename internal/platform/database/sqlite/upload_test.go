@@ -0,0 +1,106 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+)
+
+func TestSQLiteDB_PendingUploads(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.Conn.DB.Exec("INSERT INTO users (id, email, bucket) VALUES ('u1', 'a@example.com', 'bucket-a')"); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	stale := fs.Metadata{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", UploadedAt: time.Now().Add(-2 * time.Hour), Pending: true}
+	fresh := fs.Metadata{Id: "f2", Filename: "b.jpg", UserId: "u1", UploadedAt: time.Now(), Pending: true}
+	for _, m := range []*fs.Metadata{&stale, &fresh} {
+		if err := db.Save(ctx, m); err != nil {
+			t.Fatalf("Save(%s): %v", m.Id, err)
+		}
+	}
+
+	if err := db.Save(ctx, &fs.Metadata{Id: "f3", Filename: "a.jpg", UserId: "u1", UploadedAt: time.Now()}); !errors.Is(err, fs.ErrFileExists) {
+		t.Errorf("Save() of a taken name err = %v, want ErrFileExists", err)
+	}
+
+	if _, err := db.Get(ctx, "f2", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get() of a pending upload err = %v, want sql.ErrNoRows", err)
+	}
+	if n, err := db.Count(ctx, "u1", fs.Filter{}); err != nil || n != 0 {
+		t.Errorf("Count() = %d, %v, want pending uploads left out", n, err)
+	}
+
+	if m, err := db.GetPending(ctx, "f2", "u1"); err != nil || m.Filename != "b.jpg" || !m.Pending {
+		t.Errorf("GetPending() = %+v, %v", m, err)
+	}
+	if _, err := db.GetPending(ctx, "f2", "u2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPending() by another user err = %v, want sql.ErrNoRows", err)
+	}
+
+	files, err := db.GetStaleUploads(ctx, time.Now().Add(-time.Hour), 10)
+	if err != nil || len(files) != 1 || files[0].Id != "f1" || files[0].Bucket != "bucket-a" || !files[0].Pending {
+		t.Errorf("GetStaleUploads() = %+v, %v, want f1 in bucket-a", files, err)
+	}
+
+	if err := db.CommitUpload(ctx, "f2", "u1"); err != nil {
+		t.Fatalf("CommitUpload(): %v", err)
+	}
+	if err := db.CommitUpload(ctx, "f2", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CommitUpload() twice err = %v, want sql.ErrNoRows", err)
+	}
+	if m, err := db.Get(ctx, "f2", "u1"); err != nil || m.Pending {
+		t.Errorf("Get() after commit = %+v, %v", m, err)
+	}
+	if _, err := db.GetPending(ctx, "f2", "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPending() after commit err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDB_ReplacePending(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.Conn.DB.Exec("INSERT INTO users (id, email, bucket) VALUES ('u1', 'a@example.com', 'bucket-a')"); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	placeholder := fs.Metadata{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", Object: "incoming-f1", Size: 10, UploadedAt: time.Now(), Pending: true}
+	if err := db.Save(ctx, &placeholder); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+
+	done := fs.Metadata{Id: "f1", Filename: "a.jpg", UserId: "u1", Bucket: "bucket-a", Object: "incoming-f1", Size: 10, Width: 3, Height: 2,
+		UploadedAt: time.Now(), Exif: &fs.Exif{CameraMake: "Canon"}}
+	if err := db.ReplacePending(ctx, &fs.Metadata{Id: "f1", UserId: "u2"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ReplacePending() by another user err = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.ReplacePending(ctx, &done); err != nil {
+		t.Fatalf("ReplacePending(): %v", err)
+	}
+
+	m, err := db.Get(ctx, "f1", "u1")
+	if err != nil || m.Width != 3 || m.Exif == nil || m.Exif.CameraMake != "Canon" {
+		t.Errorf("Get() = %+v, %v, want the replacement", m, err)
+	}
+
+	if err := db.ReplacePending(ctx, &done); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ReplacePending() of a committed file err = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/portbound/go-fs/internal/vault"
+)
+
+func (db *SQLiteDB) GetVault(ctx context.Context, userId string) (*vault.Vault, error) {
+	row, err := db.Queries.GetVault(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vault.Vault{
+		UserId:     row.UserID,
+		KDF:        row.Kdf,
+		KDFParams:  json.RawMessage(row.KdfParams),
+		WrappedKey: row.WrappedKey,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+	}, nil
+}
+
+func (db *SQLiteDB) CreateVault(ctx context.Context, v *vault.Vault) error {
+	params := CreateVaultParams{
+		UserID:     v.UserId,
+		Kdf:        v.KDF,
+		KdfParams:  string(v.KDFParams),
+		WrappedKey: v.WrappedKey,
+		CreatedAt:  v.CreatedAt,
+		UpdatedAt:  v.UpdatedAt,
+	}
+
+	if err := db.Queries.CreateVault(ctx, params); err != nil {
+		if isUniqueViolation(err) {
+			return vault.ErrVaultExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) RewrapVault(ctx context.Context, v *vault.Vault, previousWrappedKey string) error {
+	params := RewrapVaultParams{
+		Kdf:                v.KDF,
+		KdfParams:          string(v.KDFParams),
+		WrappedKey:         v.WrappedKey,
+		UpdatedAt:          v.UpdatedAt,
+		UserID:             v.UserId,
+		PreviousWrappedKey: previousWrappedKey,
+	}
+
+	n, err := db.Queries.RewrapVault(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/database/sqlite"
+	"github.com/portbound/go-fs/internal/vault"
+)
+
+func TestSQLiteDB_Vault(t *testing.T) {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "sqlite.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db: %v", err)
+	}
+	defer db.Conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.GetVault(ctx, "u1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetVault() before setup err = %v, want sql.ErrNoRows", err)
+	}
+
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	v := &vault.Vault{UserId: "u1", KDF: vault.KDFArgon2id, KDFParams: json.RawMessage(`{"salt":"c2FsdA=="}`), WrappedKey: "old", CreatedAt: created, UpdatedAt: created}
+	if err := db.CreateVault(ctx, v); err != nil {
+		t.Fatalf("CreateVault(): %v", err)
+	}
+	if err := db.CreateVault(ctx, v); !errors.Is(err, vault.ErrVaultExists) {
+		t.Errorf("second CreateVault() err = %v, want %v", err, vault.ErrVaultExists)
+	}
+
+	updated := time.Now().UTC().Truncate(time.Second)
+	v = &vault.Vault{UserId: "u1", KDF: vault.KDFPBKDF2, KDFParams: json.RawMessage(`{"salt":"bmV3"}`), WrappedKey: "new", UpdatedAt: updated}
+	if err := db.RewrapVault(ctx, v, "stale"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RewrapVault() of a replaced key err = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.RewrapVault(ctx, v, "old"); err != nil {
+		t.Fatalf("RewrapVault(): %v", err)
+	}
+
+	got, err := db.GetVault(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetVault(): %v", err)
+	}
+	if got.KDF != vault.KDFPBKDF2 || string(got.KDFParams) != `{"salt":"bmV3"}` || got.WrappedKey != "new" || !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(updated) {
+		t.Errorf("GetVault() = %+v, want the new key material created at %v", got, created)
+	}
+
+	// Vault files carry their wrapped key and sealed metadata everywhere a
+	// file is read.
+	m := fs.Metadata{Id: "f1", Filename: "f1", UserId: "u1", Bucket: "bucket-a", ContentType: "application/octet-stream", UploadedAt: time.Now(), WrappedKey: "key", EncryptedMetadata: "sealed"}
+	if err := db.Save(ctx, &m); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	file, err := db.Get(ctx, "f1", "u1")
+	if err != nil || file.WrappedKey != "key" || file.EncryptedMetadata != "sealed" {
+		t.Errorf("Get() = %+v, %v, want the wrapped key and sealed metadata", file, err)
+	}
+	files, err := db.GetAll(ctx, "u1", fs.ListOptions{SortBy: fs.SortByUploaded, Limit: 10})
+	if err != nil || len(files) != 1 || files[0].WrappedKey != "key" || files[0].EncryptedMetadata != "sealed" {
+		t.Errorf("GetAll() = %+v, %v, want the wrapped key and sealed metadata", files, err)
+	}
+}
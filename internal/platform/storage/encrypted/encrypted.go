@@ -0,0 +1,552 @@
+package encrypted
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+const (
+	// KeySize is the size of the master key and of every data key.
+	KeySize = 32
+
+	// Objects are sealed in chunks of chunkSize bytes, each with its own
+	// tag, so a range only needs the chunks covering it.
+	chunkSize = 64 << 10
+	tagSize   = 16
+
+	// The header is the magic, the version, the chunk size, the id of the
+	// master key and the data key wrapped by it.
+	magic          = "GOFSENC"
+	version        = 1
+	prefixSize     = len(magic) + 1 + 4 + 4
+	wrappedKeySize = 12 + KeySize + tagSize
+	headerSize     = int64(prefixSize + wrappedKeySize)
+)
+
+// Encrypted encrypts objects on their way into store and decrypts them on
+// the way out, so whoever hosts the buckets only ever holds ciphertext. Every
+// object has a data key of its own, wrapped by the master key and kept in the
+// object's header. The wrap is bound to the object's bucket and name, so
+// ciphertext moved to another object fails to open. Objects without a header
+// are refused, unless AllowPlaintextBefore says they predate encryption.
+type Encrypted struct {
+	store  fs.MediaStore
+	master cipher.AEAD
+	keyId  [4]byte
+	// plaintextBefore is when encryption was turned on, see
+	// AllowPlaintextBefore.
+	plaintextBefore time.Time
+}
+
+func New(store fs.MediaStore, masterKey []byte) (*Encrypted, error) {
+	if len(masterKey) != KeySize {
+		return nil, fmt.Errorf("master key is %d bytes, want %d", len(masterKey), KeySize)
+	}
+
+	master, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The id tells a wrong master key apart from a corrupted object.
+	e := &Encrypted{store: store, master: master}
+	sum := sha256.Sum256(masterKey)
+	copy(e.keyId[:], sum[:])
+
+	return e, nil
+}
+
+// AllowPlaintextBefore serves objects without a header as they are if the
+// backend created them before t, which should be when encryption was turned
+// on. Anything stored in the clear since then was not stored by the server.
+func (e *Encrypted) AllowPlaintextBefore(t time.Time) {
+	e.plaintextBefore = t
+}
+
+func (e *Encrypted) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("generate data key: %w", err)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	header, err := e.seal(key, name, bucket)
+	if err != nil {
+		return fmt.Errorf("wrap data key: %w", err)
+	}
+
+	s := &sealer{aead: aead, src: bufio.NewReaderSize(src, chunkSize)}
+	return e.store.Upload(ctx, name, bucket, io.MultiReader(bytes.NewReader(header), s))
+}
+
+func (e *Encrypted) Download(ctx context.Context, name, bucket string) (*fs.ObjectInfo, io.ReadSeekCloser, error) {
+	info, r, err := e.store.Download(ctx, name, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		r.Close()
+		return nil, nil, fmt.Errorf("read header of %q: %w", name, err)
+	}
+
+	aead, ok, err := e.open(header[:n], name, bucket)
+	if err != nil {
+		r.Close()
+		return nil, nil, fmt.Errorf("open %q: %w", name, err)
+	}
+
+	if !ok {
+		if err := e.checkPlaintext(name, info); err != nil {
+			r.Close()
+			return nil, nil, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			r.Close()
+			return nil, nil, fmt.Errorf("rewind %q: %w", name, err)
+		}
+		return info, r, nil
+	}
+
+	or, err := newOpener(aead, r, info.Size-headerSize)
+	if err != nil {
+		r.Close()
+		return nil, nil, fmt.Errorf("open %q: %w", name, err)
+	}
+
+	contentType, err := detectContentType(name, or)
+	if err != nil {
+		r.Close()
+		return nil, nil, fmt.Errorf("detect content type of %q: %w", name, err)
+	}
+
+	// The checksum the backend keeps is of the ciphertext.
+	decrypted := *info
+	decrypted.Size = or.size
+	decrypted.ContentType = contentType
+	decrypted.Checksum = ""
+
+	return &decrypted, or, nil
+}
+
+func (e *Encrypted) DownloadRange(ctx context.Context, name, bucket string, offset, length int64) (io.ReadCloser, error) {
+	// Only the object's metadata is wanted, the reader is closed unread. The
+	// stored size tells which chunk is the last, the chunks of a range
+	// cannot tell by themselves.
+	info, r, err := e.store.Download(ctx, name, bucket)
+	if err != nil {
+		return nil, err
+	}
+	r.Close()
+
+	hr, err := e.store.DownloadRange(ctx, name, bucket, 0, headerSize)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := io.ReadAll(hr)
+	hr.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read header of %q: %w", name, err)
+	}
+
+	aead, ok, err := e.open(header, name, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", name, err)
+	}
+
+	if !ok {
+		if err := e.checkPlaintext(name, info); err != nil {
+			return nil, err
+		}
+		return e.store.DownloadRange(ctx, name, bucket, offset, length)
+	}
+
+	lastChunk, _, err := chunks(info.Size - headerSize)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", name, err)
+	}
+
+	first := offset / chunkSize
+	if length <= 0 || first > lastChunk {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	last := min((offset+length-1)/chunkSize, lastChunk)
+	src, err := e.store.DownloadRange(ctx, name, bucket, headerSize+first*sealedChunkSize, (last-first+1)*sealedChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &chunkReader{aead: aead, src: src, index: first, end: last + 1, last: lastChunk}
+	if _, err := io.CopyN(io.Discard, cr, offset-first*chunkSize); err != nil {
+		src.Close()
+		return nil, fmt.Errorf("skip to offset %d of %q: %w", offset, name, err)
+	}
+
+	return readCloser{io.LimitReader(cr, length), src}, nil
+}
+
+func (e *Encrypted) Delete(ctx context.Context, name, bucket string) error {
+	return e.store.Delete(ctx, name, bucket)
+}
+
+// List reports sizes as stored, header and tags included.
+func (e *Encrypted) List(ctx context.Context, bucket string) ([]fs.ObjectInfo, error) {
+	return e.store.List(ctx, bucket)
+}
+
+// checkPlaintext fails unless an object without a header was stored before
+// encryption was turned on. Otherwise it was put there to be served in place
+// of what the server stored.
+func (e *Encrypted) checkPlaintext(name string, info *fs.ObjectInfo) error {
+	if info.Created.IsZero() || !info.Created.Before(e.plaintextBefore) {
+		return fmt.Errorf("%w: %q is not encrypted", fs.ErrMediaCorrupted, name)
+	}
+
+	return nil
+}
+
+// seal builds the header for the object name in bucket, encrypted under key.
+func (e *Encrypted) seal(key []byte, name, bucket string) ([]byte, error) {
+	prefix := make([]byte, prefixSize)
+	copy(prefix, magic)
+	prefix[len(magic)] = version
+	binary.BigEndian.PutUint32(prefix[len(magic)+1:], chunkSize)
+	copy(prefix[len(magic)+5:], e.keyId[:])
+
+	nonce := make([]byte, e.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append(make([]byte, 0, headerSize), prefix...), nonce...)
+	return e.master.Seal(header, nonce, key, additionalData(prefix, name, bucket)), nil
+}
+
+// additionalData binds a wrapped data key to the header's prefix and to the
+// object it was sealed for.
+func additionalData(prefix []byte, name, bucket string) []byte {
+	ad := make([]byte, 0, len(prefix)+4+len(bucket)+len(name))
+	ad = append(ad, prefix...)
+	ad = binary.BigEndian.AppendUint32(ad, uint32(len(bucket)))
+	ad = append(ad, bucket...)
+	return append(ad, name...)
+}
+
+// open unwraps the data key from the header of the object name in bucket. ok
+// is false if there is no header at all, the object is then stored in the
+// clear.
+func (e *Encrypted) open(header []byte, name, bucket string) (aead cipher.AEAD, ok bool, err error) {
+	if int64(len(header)) < headerSize || string(header[:len(magic)]) != magic {
+		return nil, false, nil
+	}
+
+	prefix := header[:prefixSize]
+	if v := prefix[len(magic)]; v != version {
+		return nil, false, fmt.Errorf("unsupported encryption version %d", v)
+	}
+
+	if size := binary.BigEndian.Uint32(prefix[len(magic)+1:]); size != chunkSize {
+		return nil, false, fmt.Errorf("unsupported chunk size %d", size)
+	}
+
+	if keyId := prefix[len(magic)+5:]; !bytes.Equal(keyId, e.keyId[:]) {
+		return nil, false, fmt.Errorf("encrypted under master key %x, this is %x", keyId, e.keyId)
+	}
+
+	nonce := header[prefixSize : prefixSize+e.master.NonceSize()]
+	key, err := e.master.Open(nil, nonce, header[prefixSize+len(nonce):headerSize], additionalData(prefix, name, bucket))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: data key failed authentication, or belongs to another object", fs.ErrMediaCorrupted)
+	}
+
+	aead, err = newGCM(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return aead, true, nil
+}
+
+const sealedChunkSize = chunkSize + tagSize
+
+// chunkNonce is unique per chunk under a data key that is never reused, and
+// marks the last chunk so a truncated object fails to open.
+func chunkNonce(index int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// sealer encrypts src chunk by chunk as it is read.
+type sealer struct {
+	aead  cipher.AEAD
+	src   *bufio.Reader
+	chunk []byte
+	buf   []byte
+	out   []byte
+	index int64
+	done  bool
+}
+
+func (s *sealer) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+func (s *sealer) next() error {
+	if s.chunk == nil {
+		s.chunk = make([]byte, chunkSize)
+		s.buf = make([]byte, 0, sealedChunkSize)
+	}
+
+	n, err := io.ReadFull(s.src, s.chunk)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		s.done = true
+	case err != nil:
+		return err
+	default:
+		// A full chunk is the last one if nothing follows it.
+		if _, err := s.src.Peek(1); errors.Is(err, io.EOF) {
+			s.done = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	s.out = s.aead.Seal(s.buf[:0], chunkNonce(s.index, s.done), s.chunk[:n], nil)
+	s.index++
+	return nil
+}
+
+// opener decrypts a whole object and can seek within it.
+type opener struct {
+	aead cipher.AEAD
+	src  io.ReadSeekCloser
+	// size is of the plaintext, sealed of everything after the header.
+	size   int64
+	sealed int64
+	last   int64
+	pos    int64
+	index  int64
+	chunk  []byte
+	buf    []byte
+}
+
+func newOpener(aead cipher.AEAD, src io.ReadSeekCloser, sealed int64) (*opener, error) {
+	last, size, err := chunks(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &opener{aead: aead, src: src, sealed: sealed, size: size, last: last, index: -1}, nil
+}
+
+// chunks works out the index of the last chunk and the size of the plaintext
+// from the size of everything after the header.
+func chunks(sealed int64) (last, size int64, err error) {
+	full, rest := sealed/sealedChunkSize, sealed%sealedChunkSize
+	if sealed <= 0 || (rest > 0 && rest < tagSize) {
+		return 0, 0, fmt.Errorf("%w: %d bytes is not a whole number of chunks", fs.ErrMediaCorrupted, sealed)
+	}
+
+	last, size = full-1, full*chunkSize
+	if rest > 0 {
+		last++
+		size += rest - tagSize
+	}
+
+	return last, size, nil
+}
+
+func (o *opener) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+
+	index := o.pos / chunkSize
+	if index != o.index {
+		if err := o.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, o.chunk[o.pos-index*chunkSize:])
+	o.pos += int64(n)
+	return n, nil
+}
+
+func (o *opener) load(index int64) error {
+	if _, err := o.src.Seek(headerSize+index*sealedChunkSize, io.SeekStart); err != nil {
+		return err
+	}
+
+	if o.buf == nil {
+		o.buf = make([]byte, sealedChunkSize)
+	}
+
+	sealed := o.buf[:min(sealedChunkSize, o.sealed-index*sealedChunkSize)]
+	if _, err := io.ReadFull(o.src, sealed); err != nil {
+		return fmt.Errorf("read chunk %d: %w", index, err)
+	}
+
+	chunk, err := o.aead.Open(o.chunk[:0], chunkNonce(index, index == o.last), sealed, nil)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d failed authentication", fs.ErrMediaCorrupted, index)
+	}
+
+	o.chunk, o.index = chunk, index
+	return nil
+}
+
+func (o *opener) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.pos + offset
+	case io.SeekEnd:
+		abs = o.size + offset
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+
+	o.pos = abs
+	return abs, nil
+}
+
+func (o *opener) Close() error {
+	return o.src.Close()
+}
+
+// chunkReader decrypts the chunks of a range in order. Only the chunk at
+// last, the object's last, opens as such, so an object cut short at a chunk
+// boundary fails to open.
+type chunkReader struct {
+	aead cipher.AEAD
+	src  io.Reader
+	// The range covers the chunks from index up to but not including end.
+	index int64
+	end   int64
+	last  int64
+	buf   []byte
+	out   []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.index == c.end {
+			return 0, io.EOF
+		}
+
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+func (c *chunkReader) next() error {
+	if c.buf == nil {
+		c.buf = make([]byte, sealedChunkSize)
+	}
+
+	// Only the last chunk may be short.
+	n, err := io.ReadFull(c.src, c.buf)
+	switch {
+	case errors.Is(err, io.EOF) || (errors.Is(err, io.ErrUnexpectedEOF) && c.index != c.last):
+		return fmt.Errorf("%w: object ends in chunk %d, which is not the last", fs.ErrMediaCorrupted, c.index)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+	case err != nil:
+		return fmt.Errorf("read chunk %d: %w", c.index, err)
+	}
+
+	chunk, err := c.aead.Open(nil, chunkNonce(c.index, c.index == c.last), c.buf[:n], nil)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d failed authentication", fs.ErrMediaCorrupted, c.index)
+	}
+
+	c.out = chunk
+	c.index++
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// detectContentType goes by the extension and otherwise sniffs the
+// plaintext, the backend could only have sniffed ciphertext.
+func detectContentType(name string, r io.ReadSeeker) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
@@ -0,0 +1,329 @@
+package encrypted_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/storage/encrypted"
+	"github.com/portbound/go-fs/internal/platform/storage/local"
+)
+
+const chunkSize = 64 << 10
+
+func newStore(t *testing.T, root string, key []byte) *encrypted.Encrypted {
+	t.Helper()
+	l, err := local.New(root)
+	if err != nil {
+		t.Fatalf("new local: %v", err)
+	}
+
+	e, err := encrypted.New(l, key)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	return e
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+
+	return b
+}
+
+func TestEncrypted_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "one byte", size: 1},
+		{name: "short of a chunk", size: chunkSize - 1},
+		{name: "one chunk", size: chunkSize},
+		{name: "just over a chunk", size: chunkSize + 1},
+		{name: "several chunks", size: 3*chunkSize + 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			root := t.TempDir()
+			e := newStore(t, root, randomBytes(t, encrypted.KeySize))
+			data := randomBytes(t, tt.size)
+
+			if err := e.Upload(ctx, "photo.jpg", "test_bucket", bytes.NewReader(data)); err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+
+			stored, err := os.ReadFile(filepath.Join(root, "test_bucket", "photo.jpg"))
+			if err != nil {
+				t.Fatalf("read object: %v", err)
+			}
+			// A few bytes turn up in random ciphertext by chance.
+			if tt.size >= 16 && bytes.Contains(stored, data) {
+				t.Error("stored object contains the plaintext")
+			}
+
+			info, r, err := e.Download(ctx, "photo.jpg", "test_bucket")
+			if err != nil {
+				t.Fatalf("download: %v", err)
+			}
+			defer r.Close()
+
+			if info.Size != int64(tt.size) || info.ContentType != "image/jpeg" {
+				t.Errorf("info = %+v, want size %d and image/jpeg", info, tt.size)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("download returned %d bytes that differ from the %d uploaded", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestEncrypted_Ranges(t *testing.T) {
+	ctx := context.Background()
+	e := newStore(t, t.TempDir(), randomBytes(t, encrypted.KeySize))
+	data := randomBytes(t, 3*chunkSize+5)
+	if err := e.Upload(ctx, "video.mp4", "test_bucket", bytes.NewReader(data)); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		offset, length int64
+	}{
+		{name: "start", offset: 0, length: 10},
+		{name: "within a chunk", offset: 100, length: 1000},
+		{name: "across a boundary", offset: chunkSize - 3, length: 6},
+		{name: "across chunks", offset: 10, length: 2*chunkSize + 10},
+		{name: "last full chunk", offset: 2 * chunkSize, length: chunkSize},
+		{name: "tail", offset: 3*chunkSize + 1, length: 4},
+		{name: "past the end", offset: 3 * chunkSize, length: chunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := data[tt.offset:min(tt.offset+tt.length, int64(len(data)))]
+
+			r, err := e.DownloadRange(ctx, "video.mp4", "test_bucket", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("download range: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("DownloadRange() = %d bytes, %v, want %d matching bytes", len(got), err, len(want))
+			}
+
+			_, rs, err := e.Download(ctx, "video.mp4", "test_bucket")
+			if err != nil {
+				t.Fatalf("download: %v", err)
+			}
+			defer rs.Close()
+			if _, err := rs.Seek(tt.offset, io.SeekStart); err != nil {
+				t.Fatalf("seek: %v", err)
+			}
+			got, err = io.ReadAll(io.LimitReader(rs, tt.length))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("Seek() and read = %d bytes, %v, want %d matching bytes", len(got), err, len(want))
+			}
+		})
+	}
+}
+
+func TestEncrypted_Tampering(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	key := randomBytes(t, encrypted.KeySize)
+	e := newStore(t, root, key)
+	data := randomBytes(t, 2*chunkSize)
+	if err := e.Upload(ctx, "photo.jpg", "test_bucket", bytes.NewReader(data)); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	path := filepath.Join(root, "test_bucket", "photo.jpg")
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read object: %v", err)
+	}
+
+	t.Run("wrong master key", func(t *testing.T) {
+		other := newStore(t, root, randomBytes(t, encrypted.KeySize))
+		if _, _, err := other.Download(ctx, "photo.jpg", "test_bucket"); err == nil {
+			t.Error("download with another master key succeeded")
+		}
+	})
+
+	tests := []struct {
+		name   string
+		modify func([]byte) []byte
+	}{
+		{name: "flipped byte", modify: func(b []byte) []byte { b[len(b)/2] ^= 1; return b }},
+		{name: "truncated", modify: func(b []byte) []byte { return b[:len(b)-chunkSize-16] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, tt.modify(bytes.Clone(stored)), 0o600); err != nil {
+				t.Fatalf("write object: %v", err)
+			}
+
+			_, r, err := e.Download(ctx, "photo.jpg", "test_bucket")
+			if err == nil {
+				_, err = io.ReadAll(r)
+				r.Close()
+			}
+			if !errors.Is(err, fs.ErrMediaCorrupted) {
+				t.Errorf("download err = %v, want %v", err, fs.ErrMediaCorrupted)
+			}
+
+			r2, err := e.DownloadRange(ctx, "photo.jpg", "test_bucket", 0, 2*chunkSize)
+			if err == nil {
+				_, err = io.ReadAll(r2)
+				r2.Close()
+			}
+			if !errors.Is(err, fs.ErrMediaCorrupted) {
+				t.Errorf("download range err = %v, want %v", err, fs.ErrMediaCorrupted)
+			}
+		})
+	}
+}
+
+func TestEncrypted_TruncatedRange(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	e := newStore(t, root, randomBytes(t, encrypted.KeySize))
+	if err := e.Upload(ctx, "photo.jpg", "test_bucket", bytes.NewReader(randomBytes(t, 3*chunkSize+5))); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	// Cut off after the second chunk, which leaves an object that looks
+	// whole to anyone going by its size.
+	path := filepath.Join(root, "test_bucket", "photo.jpg")
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read object: %v", err)
+	}
+	sealedChunkSize := chunkSize + 16
+	if err := os.WriteFile(path, stored[:len(stored)-(sealedChunkSize+5+16)], 0o600); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+
+	r, err := e.DownloadRange(ctx, "photo.jpg", "test_bucket", 0, 2*chunkSize)
+	if err == nil {
+		_, err = io.ReadAll(r)
+		r.Close()
+	}
+	if !errors.Is(err, fs.ErrMediaCorrupted) {
+		t.Errorf("download range of the truncated object err = %v, want %v", err, fs.ErrMediaCorrupted)
+	}
+}
+
+func TestEncrypted_Swapped(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	e := newStore(t, root, randomBytes(t, encrypted.KeySize))
+	for _, object := range []struct{ name, bucket string }{{"a.jpg", "bucket_a"}, {"b.jpg", "bucket_a"}, {"a.jpg", "bucket_b"}} {
+		if err := e.Upload(ctx, object.name, object.bucket, bytes.NewReader(randomBytes(t, 100))); err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+
+	// Ciphertext stored under another name or bucket is not that object's.
+	stored, err := os.ReadFile(filepath.Join(root, "bucket_a", "a.jpg"))
+	if err != nil {
+		t.Fatalf("read object: %v", err)
+	}
+	for _, path := range []string{filepath.Join(root, "bucket_a", "b.jpg"), filepath.Join(root, "bucket_b", "a.jpg")} {
+		if err := os.WriteFile(path, stored, 0o600); err != nil {
+			t.Fatalf("write object: %v", err)
+		}
+	}
+
+	for _, object := range []struct{ name, bucket string }{{"b.jpg", "bucket_a"}, {"a.jpg", "bucket_b"}} {
+		if _, _, err := e.Download(ctx, object.name, object.bucket); !errors.Is(err, fs.ErrMediaCorrupted) {
+			t.Errorf("download of %s/%s err = %v, want %v", object.bucket, object.name, err, fs.ErrMediaCorrupted)
+		}
+		if _, err := e.DownloadRange(ctx, object.name, object.bucket, 0, 10); !errors.Is(err, fs.ErrMediaCorrupted) {
+			t.Errorf("download range of %s/%s err = %v, want %v", object.bucket, object.name, err, fs.ErrMediaCorrupted)
+		}
+	}
+}
+
+func TestEncrypted_Unencrypted(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "test_bucket"), 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	path := filepath.Join(root, "test_bucket", "old.jpg")
+	if err := os.WriteFile(path, []byte("stored in the clear"), 0o600); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+	stored := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, stored, stored); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		plaintextBefore time.Time
+		wantErr         error
+	}{
+		{name: "encryption always on", wantErr: fs.ErrMediaCorrupted},
+		{name: "stored before encryption", plaintextBefore: stored.Add(time.Minute)},
+		{name: "stored after encryption", plaintextBefore: stored.Add(-time.Minute), wantErr: fs.ErrMediaCorrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newStore(t, root, randomBytes(t, encrypted.KeySize))
+			e.AllowPlaintextBefore(tt.plaintextBefore)
+
+			_, r, err := e.Download(ctx, "old.jpg", "test_bucket")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("download err = %v, want %v", err, tt.wantErr)
+			}
+			rr, rangeErr := e.DownloadRange(ctx, "old.jpg", "test_bucket", 10, 5)
+			if !errors.Is(rangeErr, tt.wantErr) {
+				t.Fatalf("download range err = %v, want %v", rangeErr, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || string(got) != "stored in the clear" {
+				t.Errorf("Download() = %q, %v", got, err)
+			}
+
+			got, err = io.ReadAll(rr)
+			rr.Close()
+			if err != nil || string(got) != "the c" {
+				t.Errorf("DownloadRange() = %q, %v, want %q", got, err, "the c")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	l, err := local.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new local: %v", err)
+	}
+	if _, err := encrypted.New(l, make([]byte, 16)); err == nil {
+		t.Error("New() accepted a 16 byte master key")
+	}
+}
@@ -2,73 +2,188 @@ package gcs
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/portbound/go-fs/internal/fs"
+	"google.golang.org/api/iterator"
 )
 
+// ErrBucketNotProvisioned is returned by Upload for a bucket Provision has not
+// created yet.
+var ErrBucketNotProvisioned = errors.New("bucket is not provisioned")
+
+// BucketConfig is what Provision applies to every bucket.
+type BucketConfig struct {
+	// Location is only used to create a bucket, it cannot be changed after.
+	Location     string
+	StorageClass string
+	Versioning   bool
+	// SoftDeleteRetention is how long deleted objects can still be restored,
+	// zero turns soft delete off.
+	SoftDeleteRetention time.Duration
+	Lifecycle           []LifecycleRule
+}
+
+// LifecycleRule moves objects to StorageClass once they are Days old. Without
+// a StorageClass it deletes versions Days after they were replaced instead.
+type LifecycleRule struct {
+	Days         int64
+	StorageClass string
+}
+
 type Gcs struct {
 	client    *storage.Client
 	projectID string
-	mu        sync.Mutex
+	bucket    BucketConfig
+
+	// ready holds the buckets known to exist.
+	mu    sync.RWMutex
+	ready map[string]bool
 }
 
-func New(projectID string) (*Gcs, error) {
+func New(projectID string, bucket BucketConfig) (*Gcs, error) {
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx, storage.WithJSONReads())
 	if err != nil {
 		return nil, err
 	}
-	return &Gcs{client: client, projectID: projectID, mu: sync.Mutex{}}, nil
+	return &Gcs{client: client, projectID: projectID, bucket: bucket, ready: make(map[string]bool)}, nil
 }
 
-func (g *Gcs) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+// Provision creates the bucket if it does not exist and brings its storage
+// class, versioning, soft delete and lifecycle in line with the config. It
+// fails for a bucket in another location, as that cannot be changed.
+func (g *Gcs) Provision(ctx context.Context, bucket string) error {
 	bkt := g.client.Bucket(bucket)
-	g.mu.Lock()
-	_, err := bkt.Attrs(ctx)
-	if err != nil {
-		if !errors.Is(err, storage.ErrBucketNotExist) {
-			g.mu.Unlock()
-			return fmt.Errorf("get bucket %q attrs: %w", bucket, err)
+	attrs, err := bkt.Attrs(ctx)
+	switch {
+	case errors.Is(err, storage.ErrBucketNotExist):
+		if err := bkt.Create(ctx, g.projectID, g.bucketAttrs()); err != nil {
+			return fmt.Errorf("create bucket %q: %w", bucket, err)
 		}
+	case err != nil:
+		return fmt.Errorf("get bucket %q attrs: %w", bucket, err)
+	case !strings.EqualFold(attrs.Location, g.bucket.Location):
+		return fmt.Errorf("bucket %q is in %s, not %s, and cannot be moved", bucket, attrs.Location, g.bucket.Location)
+	default:
+		if _, err := bkt.Update(ctx, g.bucketUpdate()); err != nil {
+			return fmt.Errorf("update bucket %q: %w", bucket, err)
+		}
+	}
 
-		attrs := &storage.BucketAttrs{
-			UniformBucketLevelAccess: storage.UniformBucketLevelAccess{
-				Enabled: true,
-			},
-			PublicAccessPrevention: 1,
-			Location:               "us-east4",
-			LocationType:           "Region",
+	g.mu.Lock()
+	g.ready[bucket] = true
+	g.mu.Unlock()
+
+	return nil
+}
+
+func (g *Gcs) bucketAttrs() *storage.BucketAttrs {
+	return &storage.BucketAttrs{
+		Location:          g.bucket.Location,
+		StorageClass:      g.bucket.StorageClass,
+		VersioningEnabled: g.bucket.Versioning,
+		SoftDeletePolicy:  &storage.SoftDeletePolicy{RetentionDuration: g.bucket.SoftDeleteRetention},
+		Lifecycle:         g.lifecycle(),
+		UniformBucketLevelAccess: storage.UniformBucketLevelAccess{
+			Enabled: true,
+		},
+		PublicAccessPrevention: storage.PublicAccessPreventionEnforced,
+	}
+}
+
+func (g *Gcs) bucketUpdate() storage.BucketAttrsToUpdate {
+	lifecycle := g.lifecycle()
+	return storage.BucketAttrsToUpdate{
+		StorageClass:      g.bucket.StorageClass,
+		VersioningEnabled: g.bucket.Versioning,
+		SoftDeletePolicy:  &storage.SoftDeletePolicy{RetentionDuration: g.bucket.SoftDeleteRetention},
+		Lifecycle:         &lifecycle,
+		UniformBucketLevelAccess: &storage.UniformBucketLevelAccess{
+			Enabled: true,
+		},
+		PublicAccessPrevention: storage.PublicAccessPreventionEnforced,
+	}
+}
+
+func (g *Gcs) lifecycle() storage.Lifecycle {
+	var lifecycle storage.Lifecycle
+	for _, r := range g.bucket.Lifecycle {
+		rule := storage.LifecycleRule{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: r.StorageClass},
+			Condition: storage.LifecycleCondition{AgeInDays: r.Days},
+		}
+		if r.StorageClass == "" {
+			rule = storage.LifecycleRule{
+				Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+				Condition: storage.LifecycleCondition{DaysSinceNoncurrentTime: r.Days, Liveness: storage.Archived},
+			}
 		}
+		lifecycle.Rules = append(lifecycle.Rules, rule)
+	}
 
-		if err := bkt.Create(ctx, g.projectID, attrs); err != nil {
-			g.mu.Unlock()
-			return fmt.Errorf("create bucket %q: %w", bucket, err)
+	return lifecycle
+}
+
+// checkBucket looks a bucket up once, after that it is taken to exist.
+func (g *Gcs) checkBucket(ctx context.Context, bucket string) error {
+	g.mu.RLock()
+	ready := g.ready[bucket]
+	g.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := g.client.Bucket(bucket).Attrs(ctx); err != nil {
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return fmt.Errorf("bucket %q: %w", bucket, ErrBucketNotProvisioned)
 		}
+		return fmt.Errorf("get bucket %q attrs: %w", bucket, err)
 	}
+
+	g.mu.Lock()
+	g.ready[bucket] = true
 	g.mu.Unlock()
 
-	obj := g.client.Bucket(bucket).Object(name)
+	return nil
+}
 
-	w := obj.NewWriter(ctx)
-	defer w.Close()
+func (g *Gcs) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	if err := g.checkBucket(ctx, bucket); err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, time.Second*180)
 	defer cancel()
 
+	w := g.client.Bucket(bucket).Object(name).NewWriter(ctx)
 	if _, err := io.Copy(w, src); err != nil {
+		// Cancelling the writer's context aborts the write, which is what
+		// the deprecated CloseWithError does.
+		cancel()
+		w.Close()
 		return fmt.Errorf("stream to bucket %q: %w", bucket, err)
 	}
 
+	// The object only exists once Close succeeds, it reports what went wrong
+	// with the write.
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("write %q to bucket %q: %w", name, bucket, err)
+	}
+
 	return nil
 }
 
-func (g *Gcs) Download(ctx context.Context, name string, bucket string) (*storage.ObjectAttrs, *storage.Reader, error) {
+func (g *Gcs) Download(ctx context.Context, name string, bucket string) (*fs.ObjectInfo, io.ReadSeekCloser, error) {
 	obj := g.client.Bucket(bucket).Object(name)
 
 	attrs, err := obj.Attrs(ctx)
@@ -79,20 +194,188 @@ func (g *Gcs) Download(ctx context.Context, name string, bucket string) (*storag
 		return nil, nil, fmt.Errorf("get file attrs: %w", err)
 	}
 
-	r, err := obj.NewReader(ctx)
+	info := &fs.ObjectInfo{
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		Created:     attrs.Created,
+		Checksum:    hex.EncodeToString(attrs.MD5),
+		ETag:        attrs.Etag,
+	}
+
+	r := &objectReader{
+		ctx:  ctx,
+		obj:  obj.Generation(attrs.Generation),
+		size: attrs.Size,
+	}
+
+	return info, r, nil
+}
+
+func (g *Gcs) DownloadRange(ctx context.Context, name, bucket string, offset, length int64) (io.ReadCloser, error) {
+	r, err := g.client.Bucket(bucket).Object(name).NewRangeReader(ctx, offset, length)
 	if err != nil {
-		return nil, nil, fmt.Errorf("new file reader: %w", err)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, fs.ErrMediaNotExist
+		}
+		return nil, fmt.Errorf("new range reader: %w", err)
 	}
 
-	return attrs, r, nil
+	return r, nil
 }
 
 func (g *Gcs) Delete(ctx context.Context, name string, bucket string) error {
 	obj := g.client.Bucket(bucket).Object(name)
 
 	if err := obj.Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return fs.ErrMediaNotExist
+		}
 		return err
 	}
 
 	return nil
 }
+
+// SignUpload signs a V4 URL for a PUT of the object. Storage checks the
+// length range header, so the client cannot store more or less than size,
+// and the generation match header, so the PUT cannot replace the object
+// once it exists.
+func (g *Gcs) SignUpload(ctx context.Context, name, bucket, contentType string, size int64, expires time.Time) (*fs.SignedRequest, error) {
+	if err := g.checkBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	lengthRange := fmt.Sprintf("%d,%d", size, size)
+	u, err := g.client.Bucket(bucket).SignedURL(name, &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      http.MethodPut,
+		ContentType: contentType,
+		Headers: []string{
+			"x-goog-content-length-range:" + lengthRange,
+			"x-goog-if-generation-match:0",
+		},
+		Expires: expires,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign upload of %q: %w", name, err)
+	}
+
+	return &fs.SignedRequest{
+		Method: http.MethodPut,
+		URL:    u,
+		Headers: map[string]string{
+			"Content-Type":                contentType,
+			"X-Goog-Content-Length-Range": lengthRange,
+			"X-Goog-If-Generation-Match":  "0",
+		},
+	}, nil
+}
+
+// SignDownload signs a V4 URL for a GET of the object.
+func (g *Gcs) SignDownload(ctx context.Context, name, bucket, contentType string, expires time.Time) (string, error) {
+	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: expires,
+	}
+	if contentType != "" {
+		opts.QueryParameters = url.Values{"response-content-type": {contentType}}
+	}
+
+	u, err := g.client.Bucket(bucket).SignedURL(name, opts)
+	if err != nil {
+		return "", fmt.Errorf("sign download of %q: %w", name, err)
+	}
+
+	return u, nil
+}
+
+// Locate signs a URL for ffmpeg to read the object from, it fetches only
+// the ranges it needs.
+func (g *Gcs) Locate(ctx context.Context, name, bucket string, expires time.Time) (string, error) {
+	return g.SignDownload(ctx, name, bucket, "", expires)
+}
+
+func (g *Gcs) List(ctx context.Context, bucket string) ([]fs.ObjectInfo, error) {
+	it := g.client.Bucket(bucket).Objects(ctx, nil)
+
+	var objects []fs.ObjectInfo
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return objects, nil
+		}
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return nil, fmt.Errorf("list bucket %q: %w", bucket, fs.ErrBucketNotExist)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list bucket %q: %w", bucket, err)
+		}
+
+		objects = append(objects, fs.ObjectInfo{Name: attrs.Name, Size: attrs.Size, Created: attrs.Created})
+	}
+}
+
+// objectReader opens a range reader lazily from the current offset so that
+// seeking does not require downloading the skipped bytes.
+type objectReader struct {
+	ctx    context.Context
+	obj    *storage.ObjectHandle
+	size   int64
+	offset int64
+	r      *storage.Reader
+}
+
+func (o *objectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.r == nil {
+		r, err := o.obj.NewRangeReader(o.ctx, o.offset, -1)
+		if err != nil {
+			return 0, fmt.Errorf("new range reader: %w", err)
+		}
+		o.r = r
+	}
+
+	n, err := o.r.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *objectReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.offset + offset
+	case io.SeekEnd:
+		abs = o.size + offset
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+
+	if abs != o.offset && o.r != nil {
+		if err := o.r.Close(); err != nil {
+			return 0, fmt.Errorf("close range reader: %w", err)
+		}
+		o.r = nil
+	}
+
+	o.offset = abs
+	return abs, nil
+}
+
+func (o *objectReader) Close() error {
+	if o.r == nil {
+		return nil
+	}
+
+	return o.r.Close()
+}
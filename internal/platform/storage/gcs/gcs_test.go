@@ -0,0 +1,61 @@
+package gcs
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestGcs_bucketAttrs(t *testing.T) {
+	g := &Gcs{bucket: BucketConfig{
+		Location:            "us-central1",
+		StorageClass:        "NEARLINE",
+		Versioning:          true,
+		SoftDeleteRetention: 14 * 24 * time.Hour,
+		Lifecycle: []LifecycleRule{
+			{Days: 365, StorageClass: "ARCHIVE"},
+			{Days: 30},
+		},
+	}}
+
+	attrs := g.bucketAttrs()
+	if attrs.Location != "us-central1" || attrs.StorageClass != "NEARLINE" || !attrs.VersioningEnabled {
+		t.Errorf("bucketAttrs() = %+v", attrs)
+	}
+	if attrs.SoftDeletePolicy.RetentionDuration != 14*24*time.Hour {
+		t.Errorf("soft delete retention = %v, want 14 days", attrs.SoftDeletePolicy.RetentionDuration)
+	}
+	if !attrs.UniformBucketLevelAccess.Enabled || attrs.PublicAccessPrevention != storage.PublicAccessPreventionEnforced {
+		t.Errorf("bucketAttrs() does not lock down access: %+v", attrs)
+	}
+
+	want := []storage.LifecycleRule{
+		{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "ARCHIVE"},
+			Condition: storage.LifecycleCondition{AgeInDays: 365},
+		},
+		{
+			Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+			Condition: storage.LifecycleCondition{DaysSinceNoncurrentTime: 30, Liveness: storage.Archived},
+		},
+	}
+	rules := attrs.Lifecycle.Rules
+	if len(rules) != len(want) {
+		t.Fatalf("lifecycle rules = %+v, want %+v", rules, want)
+	}
+	for i := range want {
+		if rules[i].Action != want[i].Action || rules[i].Condition.AgeInDays != want[i].Condition.AgeInDays ||
+			rules[i].Condition.DaysSinceNoncurrentTime != want[i].Condition.DaysSinceNoncurrentTime ||
+			rules[i].Condition.Liveness != want[i].Condition.Liveness {
+			t.Errorf("lifecycle rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	// Clearing the rules in the config has to clear them on the bucket.
+	g.bucket.Lifecycle = nil
+	update := g.bucketUpdate()
+	if update.Lifecycle == nil || len(update.Lifecycle.Rules) != 0 {
+		t.Errorf("bucketUpdate() lifecycle = %+v, want it cleared", update.Lifecycle)
+	}
+}
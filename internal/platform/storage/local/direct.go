@@ -0,0 +1,137 @@
+package local
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/http/response"
+)
+
+// directPath is where RegisterRoutes serves signed uploads.
+const directPath = "/direct/"
+
+// SetUploadKey sets the key uploads are signed with. It has to be the same
+// across restarts for signed uploads to outlive them, and until it is set
+// nothing is signed or accepted.
+func (l *Local) SetUploadKey(key []byte) {
+	l.secret = key
+}
+
+// SignUpload signs a URL for a PUT of the object to the server itself, see
+// RegisterRoutes. It carries no credentials, the signature is what allows it,
+// and it only creates the object.
+func (l *Local) SignUpload(ctx context.Context, name, bucket, contentType string, size int64, expires time.Time) (*fs.SignedRequest, error) {
+	if len(l.secret) == 0 {
+		return nil, errors.New("no upload key is set")
+	}
+
+	if _, err := l.path(name, bucket); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", hex.EncodeToString(l.sign(name, bucket, contentType, size, expires.Unix())))
+
+	return &fs.SignedRequest{
+		Method:  http.MethodPut,
+		URL:     directPath + url.PathEscape(bucket) + "/" + url.PathEscape(name) + "?" + query.Encode(),
+		Headers: map[string]string{"Content-Type": contentType},
+	}, nil
+}
+
+func (l *Local) sign(name, bucket, contentType string, size, expires int64) []byte {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(strings.Join([]string{
+		http.MethodPut,
+		bucket,
+		name,
+		contentType,
+		strconv.FormatInt(size, 10),
+		strconv.FormatInt(expires, 10),
+	}, "\n")))
+	return mac.Sum(nil)
+}
+
+// RegisterRoutes serves the uploads SignUpload signs. The routes have to be
+// reachable without logging in.
+func (l *Local) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("PUT "+directPath+"{bucket}/{name}", l.handleUpload)
+}
+
+func (l *Local) handleUpload(w http.ResponseWriter, r *http.Request) {
+	bucket, name := r.PathValue("bucket"), r.PathValue("name")
+	query := r.URL.Query()
+
+	size, sizeErr := strconv.ParseInt(query.Get("size"), 10, 64)
+	expires, expiresErr := strconv.ParseInt(query.Get("expires"), 10, 64)
+	signature, signatureErr := hex.DecodeString(query.Get("signature"))
+	if err := errors.Join(sizeErr, expiresErr, signatureErr); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("malformed upload url"))
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if len(l.secret) == 0 || !hmac.Equal(signature, l.sign(name, bucket, contentType, size, expires)) {
+		response.Error(w, http.StatusForbidden, errors.New("signature does not match the upload"))
+		return
+	}
+
+	if time.Now().Unix() > expires {
+		response.Error(w, http.StatusForbidden, errors.New("upload url has expired"))
+		return
+	}
+
+	if r.ContentLength < 0 {
+		response.Error(w, http.StatusLengthRequired, errors.New("Content-Length is required"))
+		return
+	}
+	if r.ContentLength != size {
+		response.Error(w, http.StatusBadRequest, fmt.Errorf("upload has to be %d bytes", size))
+		return
+	}
+
+	// The URL stays valid until it expires, so it must not be able to
+	// replace the object once the upload is finalized. Linking the temp
+	// file into place fails if anything is already there.
+	err := l.store(r.Context(), name, bucket, http.MaxBytesReader(w, r.Body, size), os.Link)
+	if errors.Is(err, os.ErrExist) {
+		response.Error(w, http.StatusConflict, fmt.Errorf("%q has already been uploaded", name))
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to store %q", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Locate returns the object's path on disk.
+func (l *Local) Locate(ctx context.Context, name, bucket string, expires time.Time) (string, error) {
+	path, err := l.path(name, bucket)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fs.ErrMediaNotExist
+		}
+		return "", fmt.Errorf("stat %q: %w", name, err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,190 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/storage/local"
+)
+
+func TestLocal_SignUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		expires     time.Duration
+		contentType string
+		body        string
+		tamper      func(string) string
+		wantStatus  int
+	}{
+		{name: "valid", expires: time.Minute, contentType: "image/png", body: "data", wantStatus: http.StatusOK},
+		{name: "other content type", expires: time.Minute, contentType: "text/html", body: "data", wantStatus: http.StatusForbidden},
+		{name: "expired", expires: -time.Minute, contentType: "image/png", body: "data", wantStatus: http.StatusForbidden},
+		{name: "too short", expires: time.Minute, contentType: "image/png", body: "dat", wantStatus: http.StatusBadRequest},
+		{name: "too long", expires: time.Minute, contentType: "image/png", body: "data!", wantStatus: http.StatusBadRequest},
+		{
+			name: "other size", expires: time.Minute, contentType: "image/png", body: "data!",
+			tamper:     func(u string) string { return strings.Replace(u, "size=4", "size=5", 1) },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "other object", expires: time.Minute, contentType: "image/png", body: "data",
+			tamper:     func(u string) string { return strings.Replace(u, "incoming-1", "incoming-2", 1) },
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			l, err := local.New(t.TempDir())
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+			l.SetUploadKey([]byte("upload key"))
+			mux := http.NewServeMux()
+			l.RegisterRoutes(mux)
+
+			signed, err := l.SignUpload(ctx, "incoming-1", "test_bucket", "image/png", 4, time.Now().Add(tt.expires))
+			if err != nil {
+				t.Fatalf("sign upload: %v", err)
+			}
+
+			url := signed.URL
+			if tt.tamper != nil {
+				url = tt.tamper(url)
+			}
+			req := httptest.NewRequest(signed.Method, url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			_, r, err := l.Download(ctx, "incoming-1", "test_bucket")
+			if err == nil {
+				r.Close()
+			}
+			if stored := err == nil; stored != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("download after upload: %v", err)
+			}
+		})
+	}
+}
+
+func TestLocal_SignUploadReplay(t *testing.T) {
+	ctx := context.Background()
+	l, err := local.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	l.SetUploadKey([]byte("upload key"))
+	mux := http.NewServeMux()
+	l.RegisterRoutes(mux)
+
+	signed, err := l.SignUpload(ctx, "incoming-1", "test_bucket", "image/png", 4, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("sign upload: %v", err)
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusConflict} {
+		req := httptest.NewRequest(signed.Method, signed.URL, strings.NewReader([]string{"data", "evil"}[i]))
+		req.Header.Set("Content-Type", "image/png")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("upload %d status = %d, want %d: %s", i+1, rec.Code, want, rec.Body)
+		}
+	}
+
+	_, r, err := l.Download(ctx, "incoming-1", "test_bucket")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(data) != "data" {
+		t.Errorf("object = %q after a replay, want %q", data, "data")
+	}
+
+	files, err := l.List(ctx, "test_bucket")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("list = %v, want only the object", files)
+	}
+}
+
+func TestLocal_UploadKey(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, err := local.New(root)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	if _, err := l.SignUpload(ctx, "incoming-1", "test_bucket", "image/png", 4, time.Now().Add(time.Minute)); err == nil {
+		t.Error("sign upload without a key succeeded")
+	}
+
+	l.SetUploadKey([]byte("upload key"))
+	signed, err := l.SignUpload(ctx, "incoming-1", "test_bucket", "image/png", 4, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("sign upload: %v", err)
+	}
+
+	// A restart brings up another Local with the same key, which takes the
+	// upload.
+	restarted, err := local.New(root)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	restarted.SetUploadKey([]byte("upload key"))
+	mux := http.NewServeMux()
+	restarted.RegisterRoutes(mux)
+
+	req := httptest.NewRequest(signed.Method, signed.URL, strings.NewReader("data"))
+	req.Header.Set("Content-Type", "image/png")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("upload after a restart status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestLocal_Locate(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, err := local.New(root)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	if _, err := l.Locate(ctx, "incoming-1", "bucket_a", time.Now().Add(time.Minute)); !errors.Is(err, fs.ErrMediaNotExist) {
+		t.Errorf("locate before the upload: got %v, want %v", err, fs.ErrMediaNotExist)
+	}
+
+	if err := l.Upload(ctx, "incoming-1", "bucket_a", strings.NewReader("data")); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	path, err := l.Locate(ctx, "incoming-1", "bucket_a", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("locate: %v", err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "data" {
+		t.Errorf("read %q = %q, %v, want the object", path, got, err)
+	}
+}
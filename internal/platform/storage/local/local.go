@@ -0,0 +1,242 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// tempPrefix starts the names of uploads still being written, which are not
+// objects yet.
+const tempPrefix = ".upload-"
+
+// Local stores objects on disk with one directory per bucket under root.
+type Local struct {
+	root string
+	// secret signs direct uploads, see SetUploadKey.
+	secret []byte
+}
+
+// New stores objects under root, which has to exist already. Creating it
+// would hide a disk that is not mounted behind an empty store.
+func New(root string) (*Local, error) {
+	if err := checkRoot(root); err != nil {
+		return nil, err
+	}
+
+	return &Local{root: root}, nil
+}
+
+func (l *Local) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	return l.store(ctx, name, bucket, src, os.Rename)
+}
+
+// store writes src to a temp file and then moves it into place with place,
+// os.Rename to replace whatever is there or os.Link to refuse to.
+func (l *Local) store(ctx context.Context, name, bucket string, src io.Reader, place func(oldpath, newpath string) error) error {
+	path, err := l.path(name, bucket)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("create bucket %q: %w", bucket, err)
+	}
+
+	// Write to a temp file in the same directory so a failed upload never
+	// leaves a partial object behind under its final name.
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("create temp file in bucket %q: %w", bucket, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		return fmt.Errorf("write to bucket %q: %w", bucket, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("sync %q: %w", name, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", name, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := place(tmp.Name(), path); err != nil {
+		return fmt.Errorf("move %q into bucket %q: %w", name, bucket, err)
+	}
+
+	return nil
+}
+
+func (l *Local) Download(ctx context.Context, name, bucket string) (*fs.ObjectInfo, io.ReadSeekCloser, error) {
+	path, err := l.path(name, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fs.ErrMediaNotExist
+		}
+		return nil, nil, fmt.Errorf("open %q: %w", name, err)
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("stat %q: %w", name, err)
+	}
+
+	contentType, err := detectContentType(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("detect content type of %q: %w", name, err)
+	}
+
+	info := &fs.ObjectInfo{
+		Size:        stat.Size(),
+		ContentType: contentType,
+		Created:     stat.ModTime(),
+		ETag:        fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()),
+	}
+
+	return info, f, nil
+}
+
+func (l *Local) DownloadRange(ctx context.Context, name, bucket string, offset, length int64) (io.ReadCloser, error) {
+	path, err := l.path(name, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fs.ErrMediaNotExist
+		}
+		return nil, fmt.Errorf("open %q: %w", name, err)
+	}
+
+	return sectionReadCloser{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, name, bucket string) error {
+	path, err := l.path(name, bucket)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fs.ErrMediaNotExist
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, bucket string) ([]fs.ObjectInfo, error) {
+	if !isPathElement(bucket) {
+		return nil, fmt.Errorf("invalid bucket name %q", bucket)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(l.root, bucket))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read bucket %q: %w", bucket, err)
+		}
+		// Buckets are created by their first upload, but the root going
+		// away means the disk did and says nothing about the bucket.
+		if err := checkRoot(l.root); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("read bucket %q: %w", bucket, fs.ErrBucketNotExist)
+	}
+
+	objects := make([]fs.ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			continue
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("stat %q: %w", entry.Name(), err)
+		}
+
+		objects = append(objects, fs.ObjectInfo{Name: entry.Name(), Size: stat.Size(), Created: stat.ModTime()})
+	}
+
+	return objects, nil
+}
+
+func (l *Local) path(name, bucket string) (string, error) {
+	if !isPathElement(bucket) {
+		return "", fmt.Errorf("invalid bucket name %q", bucket)
+	}
+
+	if !isPathElement(name) || strings.HasPrefix(name, tempPrefix) {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+
+	return filepath.Join(l.root, bucket, name), nil
+}
+
+func checkRoot(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("storage root %q: %w", root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("storage root %q is not a directory", root)
+	}
+	return nil
+}
+
+func isPathElement(s string) bool {
+	return s != "" && s != "." && s != ".." && filepath.Base(s) == s
+}
+
+func detectContentType(f *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(f.Name())); contentType != "" {
+		return contentType, nil
+	}
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
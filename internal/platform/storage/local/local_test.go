@@ -0,0 +1,172 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/storage/local"
+)
+
+func TestLocal_Upload(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  string
+		bucket  string
+		wantErr bool
+	}{
+		{name: "valid object", object: "yellow-circle.jpg", bucket: "test_bucket"},
+		{name: "nested object", object: "../escape.jpg", bucket: "test_bucket", wantErr: true},
+		{name: "nested bucket", object: "yellow-circle.jpg", bucket: "a/b", wantErr: true},
+		{name: "empty object", object: "", bucket: "test_bucket", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			l, err := local.New(root)
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+
+			err = l.Upload(context.Background(), tt.object, tt.bucket, strings.NewReader("data"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("upload: got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := os.ReadFile(filepath.Join(root, tt.bucket, tt.object))
+			if err != nil {
+				t.Fatalf("read object: %v", err)
+			}
+			if string(got) != "data" {
+				t.Errorf("object contents = %q, want %q", got, "data")
+			}
+
+			if err := l.Delete(context.Background(), tt.object, tt.bucket); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+
+			if err := l.Delete(context.Background(), tt.object, tt.bucket); !errors.Is(err, fs.ErrMediaNotExist) {
+				t.Errorf("second delete: got %v, want %v", err, fs.ErrMediaNotExist)
+			}
+		})
+	}
+}
+
+func TestLocal_Download(t *testing.T) {
+	tests := []struct {
+		name            string
+		object          string
+		data            string
+		wantContentType string
+		wantErr         error
+	}{
+		{name: "by extension", object: "photo.png", data: "not really a png", wantContentType: "image/png"},
+		{name: "sniffed", object: "noext", data: "\xff\xd8\xff\xe0", wantContentType: "image/jpeg"},
+		{name: "missing", object: "missing.jpg", wantErr: fs.ErrMediaNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := local.New(t.TempDir())
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+
+			if tt.wantErr == nil {
+				if err := l.Upload(context.Background(), tt.object, "test_bucket", strings.NewReader(tt.data)); err != nil {
+					t.Fatalf("upload: %v", err)
+				}
+			}
+
+			info, r, err := l.Download(context.Background(), tt.object, "test_bucket")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("download: got err %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			defer r.Close()
+
+			if info.Size != int64(len(tt.data)) {
+				t.Errorf("size = %d, want %d", info.Size, len(tt.data))
+			}
+			if !strings.HasPrefix(info.ContentType, tt.wantContentType) {
+				t.Errorf("content type = %q, want %q", info.ContentType, tt.wantContentType)
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("contents = %q, want %q", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestLocal_List(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, err := local.New(root)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	objects, err := l.List(ctx, "test_bucket")
+	if !errors.Is(err, fs.ErrBucketNotExist) {
+		t.Fatalf("list of a missing bucket = %v, %v, want %v", objects, err, fs.ErrBucketNotExist)
+	}
+
+	for _, name := range []string{"a.jpg", "thumb-a.jpg"} {
+		if err := l.Upload(ctx, name, "test_bucket", strings.NewReader("data")); err != nil {
+			t.Fatalf("upload %q: %v", name, err)
+		}
+	}
+
+	// An upload still being written is not an object yet.
+	if err := os.WriteFile(filepath.Join(root, "test_bucket", ".upload-123"), []byte("da"), 0o600); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	objects, err = l.List(ctx, "test_bucket")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Name != "a.jpg" || objects[1].Name != "thumb-a.jpg" || objects[0].Size != 4 || objects[0].Created.IsZero() {
+		t.Errorf("list = %+v, want a.jpg and thumb-a.jpg", objects)
+	}
+}
+
+func TestLocal_MissingRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "media")
+	if _, err := local.New(root); err == nil {
+		t.Fatal("new with a missing root succeeded")
+	}
+	if _, err := os.Stat(root); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("new created the root: %v", err)
+	}
+
+	if err := os.Mkdir(root, 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	l, err := local.New(root)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	// The disk going away is not the same as an empty bucket.
+	if err := os.Remove(root); err != nil {
+		t.Fatalf("remove root: %v", err)
+	}
+	if _, err := l.List(context.Background(), "test_bucket"); err == nil || errors.Is(err, fs.ErrBucketNotExist) {
+		t.Errorf("list with the root gone err = %v, want it to fail on the root", err)
+	}
+}
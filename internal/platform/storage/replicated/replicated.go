@@ -0,0 +1,319 @@
+package replicated
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// Replicated writes every object to all of its stores and reads it from the
+// first one that has it. The first store is the primary: only a failure there
+// fails a write or a delete, a secondary that misses one is queued for repair
+// instead.
+type Replicated struct {
+	stores []fs.MediaStore
+
+	mu      sync.Mutex
+	pending map[repair]struct{}
+}
+
+// repair is one object a replica fell behind on.
+type repair struct {
+	replica int
+	name    string
+	bucket  string
+	// deleted is set when the replica missed a delete rather than a write.
+	deleted bool
+}
+
+func New(primary fs.MediaStore, secondaries ...fs.MediaStore) *Replicated {
+	return &Replicated{
+		stores:  append([]fs.MediaStore{primary}, secondaries...),
+		pending: make(map[repair]struct{}),
+	}
+}
+
+func (r *Replicated) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	// The object is kept on disk on its way to the primary so the
+	// secondaries can be written from the copy.
+	tmp, err := os.CreateTemp("", "replica-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := r.stores[0].Upload(ctx, name, bucket, io.TeeReader(src, tmp)); err != nil {
+		return err
+	}
+
+	for i := 1; i < len(r.stores); i++ {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("rewind temp file: %w", err)
+		}
+
+		if err := r.stores[i].Upload(ctx, name, bucket, tmp); err != nil {
+			r.enqueue(repair{replica: i, name: name, bucket: bucket})
+		}
+	}
+
+	return nil
+}
+
+func (r *Replicated) Download(ctx context.Context, name, bucket string) (*fs.ObjectInfo, io.ReadSeekCloser, error) {
+	var info *fs.ObjectInfo
+	var reader io.ReadSeekCloser
+	err := r.read(name, bucket, func(s fs.MediaStore) error {
+		var err error
+		info, reader, err = s.Download(ctx, name, bucket)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, reader, nil
+}
+
+func (r *Replicated) DownloadRange(ctx context.Context, name, bucket string, offset, length int64) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := r.read(name, bucket, func(s fs.MediaStore) error {
+		var err error
+		reader, err = s.DownloadRange(ctx, name, bucket, offset, length)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// read tries each store in turn until one succeeds. Stores found to be
+// missing the object on the way are queued for repair.
+func (r *Replicated) read(name, bucket string, fn func(fs.MediaStore) error) error {
+	var missing []int
+	var errs error
+	for i, s := range r.stores {
+		err := fn(s)
+		if err == nil {
+			for _, m := range missing {
+				r.enqueue(repair{replica: m, name: name, bucket: bucket})
+			}
+			return nil
+		}
+
+		if errors.Is(err, fs.ErrMediaNotExist) {
+			missing = append(missing, i)
+			continue
+		}
+		errs = errors.Join(errs, fmt.Errorf("replica %d: %w", i, err))
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	return fs.ErrMediaNotExist
+}
+
+func (r *Replicated) Delete(ctx context.Context, name, bucket string) error {
+	err := r.stores[0].Delete(ctx, name, bucket)
+	if err != nil && !errors.Is(err, fs.ErrMediaNotExist) {
+		return err
+	}
+	found := err == nil
+
+	for i := 1; i < len(r.stores); i++ {
+		err := r.stores[i].Delete(ctx, name, bucket)
+		switch {
+		case err == nil:
+			found = true
+		case !errors.Is(err, fs.ErrMediaNotExist):
+			r.enqueue(repair{replica: i, name: name, bucket: bucket, deleted: true})
+		}
+	}
+
+	if !found {
+		return fs.ErrMediaNotExist
+	}
+
+	return nil
+}
+
+// List merges what every store holds, so an object only a secondary still
+// has is not taken for lost. It fails if any store cannot be listed, and with
+// ErrBucketNotExist only if none of them has the bucket.
+func (r *Replicated) List(ctx context.Context, bucket string) ([]fs.ObjectInfo, error) {
+	var objects []fs.ObjectInfo
+	seen := make(map[string]bool)
+	missing := 0
+	for i, s := range r.stores {
+		list, err := s.List(ctx, bucket)
+		if errors.Is(err, fs.ErrBucketNotExist) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list replica %d: %w", i, err)
+		}
+
+		for _, o := range list {
+			if !seen[o.Name] {
+				seen[o.Name] = true
+				objects = append(objects, o)
+			}
+		}
+	}
+
+	if missing == len(r.stores) {
+		return nil, fmt.Errorf("list bucket %q: %w", bucket, fs.ErrBucketNotExist)
+	}
+
+	return objects, nil
+}
+
+// Resync compares every secondary's copy of the bucket with the primary's,
+// which catches up on repairs a restart lost. The primary is taken as right:
+// what a secondary is missing is queued to be copied and what only it holds
+// is queued to be deleted, as a delete it missed. It reports how many repairs
+// it queued.
+func (r *Replicated) Resync(ctx context.Context, bucket string) (int, error) {
+	// A primary that never took a write for the bucket has yet to create it.
+	primary, err := r.stores[0].List(ctx, bucket)
+	if err != nil && !errors.Is(err, fs.ErrBucketNotExist) {
+		return 0, fmt.Errorf("list primary: %w", err)
+	}
+
+	held := make(map[string]bool, len(primary))
+	for _, o := range primary {
+		held[o.Name] = true
+	}
+
+	var queued int
+	var errs error
+	for i := 1; i < len(r.stores); i++ {
+		list, err := r.stores[i].List(ctx, bucket)
+		if err != nil && !errors.Is(err, fs.ErrBucketNotExist) {
+			errs = errors.Join(errs, fmt.Errorf("list replica %d: %w", i, err))
+			continue
+		}
+
+		// A primary with nothing in the bucket is more likely not mounted
+		// than emptied, nothing is deleted on its word.
+		if len(held) == 0 && len(list) > 0 {
+			errs = errors.Join(errs, fmt.Errorf("replica %d holds %d objects the primary does not have any of, not deleting them", i, len(list)))
+			continue
+		}
+
+		secondary := make(map[string]bool, len(list))
+		for _, o := range list {
+			secondary[o.Name] = true
+			if !held[o.Name] {
+				r.enqueue(repair{replica: i, name: o.Name, bucket: bucket, deleted: true})
+				queued++
+			}
+		}
+
+		for name := range held {
+			if !secondary[name] {
+				r.enqueue(repair{replica: i, name: name, bucket: bucket})
+				queued++
+			}
+		}
+	}
+
+	return queued, errs
+}
+
+// Pending reports how many repairs are queued.
+func (r *Replicated) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.pending)
+}
+
+// Repair works through the queue once. Repairs that fail stay queued for the
+// next call, which is the caller's to schedule. It reports how many are left.
+func (r *Replicated) Repair(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	queue := make([]repair, 0, len(r.pending))
+	for p := range r.pending {
+		queue = append(queue, p)
+	}
+	r.mu.Unlock()
+
+	var errs error
+	for _, p := range queue {
+		if err := ctx.Err(); err != nil {
+			return r.Pending(), errors.Join(errs, err)
+		}
+
+		if err := r.repair(ctx, p); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("repair %q in bucket %q on replica %d: %w", p.name, p.bucket, p.replica, err))
+			continue
+		}
+
+		r.mu.Lock()
+		delete(r.pending, p)
+		r.mu.Unlock()
+	}
+
+	return r.Pending(), errs
+}
+
+func (r *Replicated) repair(ctx context.Context, p repair) error {
+	if p.deleted {
+		// The name may have been written again since, objects are named by
+		// content so the replica's copy is then the right one.
+		_, reader, err := r.stores[0].Download(ctx, p.name, p.bucket)
+		if err == nil {
+			reader.Close()
+			return nil
+		}
+		if !errors.Is(err, fs.ErrMediaNotExist) {
+			return fmt.Errorf("check primary: %w", err)
+		}
+
+		if err := r.stores[p.replica].Delete(ctx, p.name, p.bucket); err != nil && !errors.Is(err, fs.ErrMediaNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	// Copy from the first other store that has the object. If none does it
+	// was deleted since, and there is nothing left to catch up on.
+	var errs error
+	for i, s := range r.stores {
+		if i == p.replica {
+			continue
+		}
+
+		_, reader, err := s.Download(ctx, p.name, p.bucket)
+		if err != nil {
+			if !errors.Is(err, fs.ErrMediaNotExist) {
+				errs = errors.Join(errs, fmt.Errorf("read replica %d: %w", i, err))
+			}
+			continue
+		}
+
+		err = r.stores[p.replica].Upload(ctx, p.name, p.bucket, reader)
+		reader.Close()
+		return err
+	}
+
+	return errs
+}
+
+func (r *Replicated) enqueue(p repair) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending[p] = struct{}{}
+}
@@ -0,0 +1,261 @@
+package replicated_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/storage/local"
+	"github.com/portbound/go-fs/internal/platform/storage/replicated"
+)
+
+// flakyStore fails every write and delete while down.
+type flakyStore struct {
+	*local.Local
+	down bool
+}
+
+func (f *flakyStore) Upload(ctx context.Context, name, bucket string, src io.Reader) error {
+	if f.down {
+		return errors.New("replica down")
+	}
+	return f.Local.Upload(ctx, name, bucket, src)
+}
+
+func (f *flakyStore) Delete(ctx context.Context, name, bucket string) error {
+	if f.down {
+		return errors.New("replica down")
+	}
+	return f.Local.Delete(ctx, name, bucket)
+}
+
+type replicas struct {
+	*replicated.Replicated
+	roots     []string
+	secondary *flakyStore
+}
+
+func newReplicas(t *testing.T) *replicas {
+	t.Helper()
+	r := &replicas{roots: []string{t.TempDir(), t.TempDir()}}
+	primary, err := local.New(r.roots[0])
+	if err != nil {
+		t.Fatalf("new primary: %v", err)
+	}
+	secondary, err := local.New(r.roots[1])
+	if err != nil {
+		t.Fatalf("new secondary: %v", err)
+	}
+	r.secondary = &flakyStore{Local: secondary}
+	r.Replicated = replicated.New(primary, r.secondary)
+
+	return r
+}
+
+// has reports which replicas hold the object.
+func (r *replicas) has(name string) []bool {
+	held := make([]bool, len(r.roots))
+	for i, root := range r.roots {
+		_, err := os.Stat(filepath.Join(root, "test_bucket", name))
+		held[i] = err == nil
+	}
+	return held
+}
+
+func read(t *testing.T, r *replicas, name string) string {
+	t.Helper()
+	_, reader, err := r.Download(context.Background(), name, "test_bucket")
+	if err != nil {
+		t.Fatalf("Download(%s): %v", name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestReplicated_Upload(t *testing.T) {
+	ctx := context.Background()
+	r := newReplicas(t)
+
+	if err := r.Upload(ctx, "a.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload(): %v", err)
+	}
+	if got := r.has("a.jpg"); !slices.Equal(got, []bool{true, true}) {
+		t.Errorf("a.jpg on replicas %v, want both", got)
+	}
+
+	// A secondary that is down does not fail the upload, it falls behind.
+	r.secondary.down = true
+	if err := r.Upload(ctx, "b.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload() with a secondary down: %v", err)
+	}
+	if r.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", r.Pending())
+	}
+	if n, err := r.Repair(ctx); err == nil || n != 1 {
+		t.Errorf("Repair() while down = %d, %v, want the repair kept", n, err)
+	}
+
+	r.secondary.down = false
+	if n, err := r.Repair(ctx); err != nil || n != 0 {
+		t.Fatalf("Repair() = %d, %v, want 0", n, err)
+	}
+	if got := r.has("b.jpg"); !slices.Equal(got, []bool{true, true}) {
+		t.Errorf("b.jpg on replicas %v after repair, want both", got)
+	}
+}
+
+func TestReplicated_Failover(t *testing.T) {
+	ctx := context.Background()
+	r := newReplicas(t)
+	if err := r.Upload(ctx, "a.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload(): %v", err)
+	}
+
+	// The primary loses its copy.
+	if err := os.Remove(filepath.Join(r.roots[0], "test_bucket", "a.jpg")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	if got := read(t, r, "a.jpg"); got != "photo" {
+		t.Errorf("Download() = %q, want %q", got, "photo")
+	}
+	reader, err := r.DownloadRange(ctx, "a.jpg", "test_bucket", 1, 3)
+	if err != nil {
+		t.Fatalf("DownloadRange(): %v", err)
+	}
+	if got, _ := io.ReadAll(reader); string(got) != "hot" {
+		t.Errorf("DownloadRange() = %q, want %q", got, "hot")
+	}
+	reader.Close()
+
+	objects, err := r.List(ctx, "test_bucket")
+	if err != nil || len(objects) != 1 || objects[0].Name != "a.jpg" {
+		t.Errorf("List() = %v, %v, want a.jpg from the secondary", objects, err)
+	}
+
+	if _, err := r.Repair(ctx); err != nil {
+		t.Fatalf("Repair(): %v", err)
+	}
+	if got := r.has("a.jpg"); !slices.Equal(got, []bool{true, true}) {
+		t.Errorf("a.jpg on replicas %v after repair, want both", got)
+	}
+
+	if _, _, err := r.Download(ctx, "missing.jpg", "test_bucket"); !errors.Is(err, fs.ErrMediaNotExist) {
+		t.Errorf("Download() of a missing object err = %v, want %v", err, fs.ErrMediaNotExist)
+	}
+}
+
+func TestReplicated_Delete(t *testing.T) {
+	ctx := context.Background()
+	r := newReplicas(t)
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if err := r.Upload(ctx, name, "test_bucket", strings.NewReader("photo")); err != nil {
+			t.Fatalf("Upload(%s): %v", name, err)
+		}
+	}
+
+	if err := r.Delete(ctx, "a.jpg", "test_bucket"); err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+	if got := r.has("a.jpg"); !slices.Equal(got, []bool{false, false}) {
+		t.Errorf("a.jpg on replicas %v after delete, want neither", got)
+	}
+	if err := r.Delete(ctx, "a.jpg", "test_bucket"); !errors.Is(err, fs.ErrMediaNotExist) {
+		t.Errorf("second Delete() err = %v, want %v", err, fs.ErrMediaNotExist)
+	}
+
+	// A missed delete is caught up on by the repair.
+	r.secondary.down = true
+	if err := r.Delete(ctx, "b.jpg", "test_bucket"); err != nil {
+		t.Fatalf("Delete() with a secondary down: %v", err)
+	}
+	r.secondary.down = false
+	if _, err := r.Repair(ctx); err != nil {
+		t.Fatalf("Repair(): %v", err)
+	}
+	if got := r.has("b.jpg"); !slices.Equal(got, []bool{false, false}) {
+		t.Errorf("b.jpg on replicas %v after repairing the delete, want neither", got)
+	}
+
+	// b.jpg is written again before the missed delete is repaired, the
+	// repair must not take the new copy.
+	r.secondary.down = true
+	if err := r.Upload(ctx, "b.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload(): %v", err)
+	}
+	if err := r.Delete(ctx, "b.jpg", "test_bucket"); err != nil {
+		t.Fatalf("Delete() with a secondary down: %v", err)
+	}
+	r.secondary.down = false
+	if err := r.Upload(ctx, "b.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload() again: %v", err)
+	}
+	if _, err := r.Repair(ctx); err != nil {
+		t.Fatalf("Repair(): %v", err)
+	}
+	if got := r.has("b.jpg"); !slices.Equal(got, []bool{true, true}) {
+		t.Errorf("b.jpg on replicas %v after repairing a stale delete, want both", got)
+	}
+}
+
+func TestReplicated_Resync(t *testing.T) {
+	ctx := context.Background()
+	r := newReplicas(t)
+	if err := r.Upload(ctx, "a.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload(): %v", err)
+	}
+
+	// The secondary lost an object and missed a delete, with no repair
+	// queued for either.
+	primary, _ := local.New(r.roots[0])
+	if err := primary.Upload(ctx, "only-primary.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload() to primary: %v", err)
+	}
+	if err := r.secondary.Local.Upload(ctx, "only-secondary.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload() to secondary: %v", err)
+	}
+
+	n, err := r.Resync(ctx, "test_bucket")
+	if err != nil || n != 2 {
+		t.Fatalf("Resync() = %d, %v, want 2", n, err)
+	}
+	if _, err := r.Repair(ctx); err != nil {
+		t.Fatalf("Repair(): %v", err)
+	}
+	for name, want := range map[string][]bool{
+		"a.jpg":              {true, true},
+		"only-primary.jpg":   {true, true},
+		"only-secondary.jpg": {false, false},
+	} {
+		if got := r.has(name); !slices.Equal(got, want) {
+			t.Errorf("%s on replicas %v after resync, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReplicated_ResyncEmptyPrimary(t *testing.T) {
+	ctx := context.Background()
+	r := newReplicas(t)
+	if err := r.secondary.Local.Upload(ctx, "a.jpg", "test_bucket", strings.NewReader("photo")); err != nil {
+		t.Fatalf("Upload() to secondary: %v", err)
+	}
+
+	// An empty primary may just not be mounted, the secondary is left be.
+	if n, err := r.Resync(ctx, "test_bucket"); err == nil || n != 0 {
+		t.Errorf("Resync() = %d, %v, want it to refuse", n, err)
+	}
+	if got := r.has("a.jpg"); !slices.Equal(got, []bool{false, true}) {
+		t.Errorf("a.jpg on replicas %v, want it kept on the secondary", got)
+	}
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/portbound/go-fs/internal/config"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/storage/encrypted"
+	"github.com/portbound/go-fs/internal/platform/storage/gcs"
+	"github.com/portbound/go-fs/internal/platform/storage/local"
+	"github.com/portbound/go-fs/internal/platform/storage/replicated"
+)
+
+// Store is the configured MediaStore.
+type Store struct {
+	fs.MediaStore
+	// Replicas is nil unless STORAGE_REPLICAS is set, its repair queue is the
+	// caller's to drain.
+	Replicas *replicated.Replicated
+	// Direct is nil unless clients can upload to the backend as is, which
+	// rules out replicas and encryption as those need the bytes to go through
+	// the server.
+	Direct fs.DirectStore
+	// Signer is nil unless the backend signs download URLs and holds the
+	// objects as is, for the same reasons as Direct.
+	Signer fs.DownloadSigner
+
+	provisioners []provisioner
+}
+
+// provisioner is a backend whose buckets have to be set up before use.
+type provisioner interface {
+	Provision(ctx context.Context, bucket string) error
+}
+
+// Provision sets up the bucket on every backend that needs it, see
+// gcs.Gcs.Provision. It is safe to run again, and how changed bucket settings
+// are applied to existing buckets.
+func (s *Store) Provision(ctx context.Context, bucket string) error {
+	var errs error
+	for _, p := range s.provisioners {
+		errs = errors.Join(errs, p.Provision(ctx, bucket))
+	}
+
+	return errs
+}
+
+// routeRegisterer is a backend that serves requests of its own.
+type routeRegisterer interface {
+	RegisterRoutes(mux *http.ServeMux)
+}
+
+// RegisterRoutes serves what the direct upload backend needs served, such as
+// the local backend's signed uploads.
+func (s *Store) RegisterRoutes(mux *http.ServeMux) {
+	if r, ok := s.Direct.(routeRegisterer); ok {
+		r.RegisterRoutes(mux)
+	}
+}
+
+// uploadKeySetter is a direct upload backend that signs uploads itself.
+type uploadKeySetter interface {
+	SetUploadKey(key []byte)
+}
+
+// SetUploadKey sets the key the direct upload backend signs uploads with, if
+// it signs them itself rather than with the provider's credentials.
+func (s *Store) SetUploadKey(key []byte) {
+	if u, ok := s.Direct.(uploadKeySetter); ok {
+		u.SetUploadKey(key)
+	}
+}
+
+func Open(cfg config.Storage) (*Store, error) {
+	bucket, err := bucketConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := open(cfg.StorageBackend, cfg.GCSProjectId, cfg.LocalStorageRoot, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := cfg.Replicas()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{MediaStore: media}
+	if p, ok := media.(provisioner); ok {
+		s.provisioners = append(s.provisioners, p)
+	}
+	if len(replicas) > 0 {
+		secondaries := make([]fs.MediaStore, 0, len(replicas))
+		for _, r := range replicas {
+			secondary, err := open(r.Backend, r.Location, r.Location, bucket)
+			if err != nil {
+				return nil, fmt.Errorf("open %s replica %q: %w", r.Backend, r.Location, err)
+			}
+			if p, ok := secondary.(provisioner); ok {
+				s.provisioners = append(s.provisioners, p)
+			}
+			secondaries = append(secondaries, secondary)
+		}
+
+		s.Replicas = replicated.New(media, secondaries...)
+		s.MediaStore = s.Replicas
+	}
+
+	key, err := cfg.EncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Encryption goes on top so every replica only holds ciphertext.
+	if key != nil {
+		e, err := encrypted.New(s.MediaStore, key)
+		if err != nil {
+			return nil, err
+		}
+		e.AllowPlaintextBefore(cfg.StorageEncryptionPlaintextBefore)
+		s.MediaStore = e
+	}
+
+	if len(replicas) == 0 && key == nil {
+		s.Direct, _ = media.(fs.DirectStore)
+		s.Signer, _ = media.(fs.DownloadSigner)
+	}
+
+	return s, nil
+}
+
+func bucketConfig(cfg config.Storage) (gcs.BucketConfig, error) {
+	rules, err := cfg.LifecycleRules()
+	if err != nil {
+		return gcs.BucketConfig{}, err
+	}
+
+	bucket := gcs.BucketConfig{
+		Location:            cfg.GCSBucketLocation,
+		StorageClass:        cfg.GCSStorageClass,
+		Versioning:          cfg.GCSVersioning,
+		SoftDeleteRetention: cfg.GCSSoftDeleteRetention,
+	}
+	for _, r := range rules {
+		bucket.Lifecycle = append(bucket.Lifecycle, gcs.LifecycleRule{Days: r.Days, StorageClass: r.StorageClass})
+	}
+
+	return bucket, nil
+}
+
+func open(backend, projectID, root string, bucket gcs.BucketConfig) (fs.MediaStore, error) {
+	switch backend {
+	case "gcs":
+		return gcs.New(projectID, bucket)
+	case "local":
+		return local.New(root)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", backend)
+	}
+}
@@ -0,0 +1,117 @@
+package tag
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/portbound/go-fs/internal/auth"
+	"github.com/portbound/go-fs/internal/platform/http/response"
+	"github.com/portbound/go-fs/internal/user"
+	"github.com/portbound/portlog"
+)
+
+type Handler struct {
+	service *Service
+	logger  *portlog.PortLog
+}
+
+func NewHandler(s *Service, l *portlog.PortLog) *Handler {
+	return &Handler{service: s, logger: l}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /tags", h.handleListTags)
+	mux.HandleFunc("DELETE /tags/{name}", h.handleDeleteTag)
+	mux.HandleFunc("POST /files/tags", h.handleUpdateTags)
+	mux.HandleFunc("GET /files/{id}/tags", h.handleGetFileTags)
+}
+
+func (h *Handler) handleListTags(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	tags, err := h.service.List(r.Context(), requester.Id)
+	if err != nil {
+		h.logger.Error("failed to list tags", err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, errors.New("failed to list tags"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tags)
+}
+
+func (h *Handler) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	if err := h.service.Delete(r.Context(), requester.Id, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("tag not found: %q", name))
+			return
+		}
+
+		h.logger.Error("failed to delete tag", err, "tag", name, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to delete tag %q", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUpdateTags applies {"file_ids": [...], "add": [...], "remove": [...]}
+// to every listed file in one transaction.
+func (h *Handler) handleUpdateTags(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		FileIds []string `json:"file_ids"`
+		Add     []string `json:"add"`
+		Remove  []string `json:"remove"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	request := UpdateRequest{
+		UserId:  requester.Id,
+		FileIds: body.FileIds,
+		Add:     body.Add,
+		Remove:  body.Remove,
+	}
+
+	if err := h.service.Update(r.Context(), request); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, errors.New("one or more files not found"))
+			return
+		}
+
+		if errors.Is(err, ErrInvalidTag) || errors.Is(err, ErrInvalidRequest) {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		h.logger.Error("failed to update tags", err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, errors.New("failed to update tags"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleGetFileTags(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PathValue("id")
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	tags, err := h.service.FileTags(r.Context(), fileId, requester.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, fmt.Errorf("file not found for id: %q", fileId))
+			return
+		}
+
+		h.logger.Error("failed to get file tags", err, "fileId", fileId, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, fmt.Errorf("failed to get tags for file %q", fileId))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, tags)
+}
@@ -0,0 +1,124 @@
+package tag
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxNameLength      = 64
+	maxTagsPerRequest  = 50
+	maxFilesPerRequest = 500
+)
+
+type Service struct {
+	store Store
+}
+
+func NewService(s Store) *Service {
+	return &Service{store: s}
+}
+
+func (s *Service) Update(ctx context.Context, request UpdateRequest) error {
+	if len(request.FileIds) == 0 || len(request.FileIds) > maxFilesPerRequest {
+		return fmt.Errorf("%w: between 1 and %d files are required", ErrInvalidRequest, maxFilesPerRequest)
+	}
+
+	add, err := normalizeAll(request.Add)
+	if err != nil {
+		return err
+	}
+
+	remove, err := normalizeAll(request.Remove)
+	if err != nil {
+		return err
+	}
+
+	if len(add) == 0 && len(remove) == 0 {
+		return fmt.Errorf("%w: no tags to add or remove", ErrInvalidRequest)
+	}
+
+	if len(add)+len(remove) > maxTagsPerRequest {
+		return fmt.Errorf("%w: at most %d tags can change at once", ErrInvalidRequest, maxTagsPerRequest)
+	}
+
+	for _, name := range add {
+		if slices.Contains(remove, name) {
+			return fmt.Errorf("%w: %q is both added and removed", ErrInvalidRequest, name)
+		}
+	}
+
+	fileIds := slices.Clone(request.FileIds)
+	slices.Sort(fileIds)
+
+	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.store.TagFiles(dbCtx, request.UserId, slices.Compact(fileIds), add, remove)
+}
+
+func (s *Service) List(ctx context.Context, userId string) ([]Tag, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tags, err := s.store.GetTags(dbCtx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags == nil {
+		tags = []Tag{}
+	}
+
+	return tags, nil
+}
+
+func (s *Service) FileTags(ctx context.Context, fileId, userId string) ([]string, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tags, err := s.store.GetFileTags(dbCtx, fileId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return tags, nil
+}
+
+func (s *Service) Delete(ctx context.Context, userId, name string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return s.store.DeleteTag(dbCtx, userId, Normalize(name))
+}
+
+// normalizeAll normalizes and dedupes names and rejects any that would not
+// survive a round trip through the tag: filter.
+func normalizeAll(names []string) ([]string, error) {
+	var normalized []string
+	for _, name := range names {
+		n := Normalize(name)
+		if n == "" || utf8.RuneCountInString(n) > maxNameLength {
+			return nil, fmt.Errorf("%w: %q must be between 1 and %d characters", ErrInvalidTag, name, maxNameLength)
+		}
+
+		if strings.ContainsRune(n, '"') || strings.ContainsFunc(n, unicode.IsControl) {
+			return nil, fmt.Errorf("%w: %q contains a quote or control character", ErrInvalidTag, name)
+		}
+
+		if !slices.Contains(normalized, n) {
+			normalized = append(normalized, n)
+		}
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,72 @@
+package tag
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mockStore struct {
+	fileIds, add, remove []string
+}
+
+func (m *mockStore) TagFiles(ctx context.Context, userId string, fileIds, add, remove []string) error {
+	m.fileIds, m.add, m.remove = fileIds, add, remove
+	return nil
+}
+
+func (m *mockStore) GetTags(ctx context.Context, userId string) ([]Tag, error) {
+	return nil, nil
+}
+
+func (m *mockStore) GetFileTags(ctx context.Context, fileId, userId string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockStore) DeleteTag(ctx context.Context, userId, name string) error {
+	return nil
+}
+
+func TestService_Update(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    UpdateRequest
+		wantErr    error
+		wantAdd    []string
+		wantRemove []string
+		wantFiles  []string
+	}{
+		{
+			name:       "normalizes and dedupes",
+			request:    UpdateRequest{FileIds: []string{"f2", "f1", "f2"}, Add: []string{" Beach ", "beach", "Road   Trip"}, Remove: []string{"OLD"}},
+			wantAdd:    []string{"beach", "road trip"},
+			wantRemove: []string{"old"},
+			wantFiles:  []string{"f1", "f2"},
+		},
+		{name: "no files", request: UpdateRequest{Add: []string{"beach"}}, wantErr: ErrInvalidRequest},
+		{name: "no tags", request: UpdateRequest{FileIds: []string{"f1"}}, wantErr: ErrInvalidRequest},
+		{name: "blank tag", request: UpdateRequest{FileIds: []string{"f1"}, Add: []string{"  "}}, wantErr: ErrInvalidTag},
+		{name: "quote", request: UpdateRequest{FileIds: []string{"f1"}, Add: []string{`say "cheese"`}}, wantErr: ErrInvalidTag},
+		{name: "too long", request: UpdateRequest{FileIds: []string{"f1"}, Add: []string{strings.Repeat("a", maxNameLength+1)}}, wantErr: ErrInvalidTag},
+		{name: "add and remove", request: UpdateRequest{FileIds: []string{"f1"}, Add: []string{"Beach"}, Remove: []string{"beach"}}, wantErr: ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{}
+			err := NewService(store).Update(context.Background(), tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(store.add, tt.wantAdd) || !reflect.DeepEqual(store.remove, tt.wantRemove) || !reflect.DeepEqual(store.fileIds, tt.wantFiles) {
+				t.Errorf("TagFiles(%v, %v, %v), want (%v, %v, %v)", store.fileIds, store.add, store.remove, tt.wantFiles, tt.wantAdd, tt.wantRemove)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package tag
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+type Tag struct {
+	Name string `json:"name"`
+	// Count is how many files carry the tag.
+	Count int64 `json:"count"`
+}
+
+type Store interface {
+	// TagFiles adds and removes tags on every file in fileIds at once. Tags
+	// that are added are created as needed and tags left on no file are
+	// deleted. It fails with sql.ErrNoRows if a file is not the user's.
+	TagFiles(ctx context.Context, userId string, fileIds, add, remove []string) error
+	// GetTags returns the user's tags ordered by name.
+	GetTags(ctx context.Context, userId string) ([]Tag, error)
+	// GetFileTags fails with sql.ErrNoRows if the file is not the user's.
+	GetFileTags(ctx context.Context, fileId, userId string) ([]string, error)
+	// DeleteTag removes a tag from every file, sql.ErrNoRows if it does not
+	// exist.
+	DeleteTag(ctx context.Context, userId, name string) error
+}
+
+type UpdateRequest struct {
+	UserId  string
+	FileIds []string
+	Add     []string
+	Remove  []string
+}
+
+var (
+	ErrInvalidTag     = errors.New("invalid tag")
+	ErrInvalidRequest = errors.New("invalid tag request")
+)
+
+// Normalize folds a tag to the form it is stored and matched in: lower case
+// with runs of whitespace collapsed to one space, so "Road  Trip" and
+// "road trip" are the same tag.
+func Normalize(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
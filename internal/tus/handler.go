@@ -0,0 +1,219 @@
+package tus
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/portbound/go-fs/internal/auth"
+	"github.com/portbound/go-fs/internal/fs"
+	"github.com/portbound/go-fs/internal/platform/http/response"
+	"github.com/portbound/go-fs/internal/user"
+	"github.com/portbound/portlog"
+)
+
+type Handler struct {
+	service *Service
+	logger  *portlog.PortLog
+}
+
+func NewHandler(s *Service, l *portlog.PortLog) *Handler {
+	return &Handler{service: s, logger: l}
+}
+
+// RegisterRoutes serves the core protocol along with the creation, expiration
+// and termination extensions.
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("OPTIONS /uploads", h.handleOptions)
+	mux.HandleFunc("POST /uploads", h.tus(h.handleCreate))
+	mux.HandleFunc("HEAD /uploads/{id}", h.tus(h.handleHead))
+	mux.HandleFunc("PATCH /uploads/{id}", h.tus(h.handlePatch))
+	mux.HandleFunc("DELETE /uploads/{id}", h.tus(h.handleTerminate))
+}
+
+func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", Version)
+	w.Header().Set("Tus-Version", Version)
+	w.Header().Set("Tus-Extension", "creation,expiration,termination")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.service.MaxSize(), 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tus turns away clients speaking another version of the protocol.
+func (h *Handler) tus(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", Version)
+		if r.Header.Get("Tus-Resumable") != Version {
+			w.Header().Set("Tus-Version", Version)
+			response.Error(w, http.StatusPreconditionFailed, fmt.Errorf("only tus %s is supported", Version))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleCreate takes Upload-Length and Upload-Metadata with the filename and
+// filetype, and answers with the new upload's Location.
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		response.Error(w, http.StatusBadRequest, errors.New("Upload-Defer-Length is not supported"))
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid Upload-Length"))
+		return
+	}
+
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	u, err := h.service.Create(r.Context(), CreateRequest{
+		UserId:      requester.Id,
+		Bucket:      requester.Bucket,
+		Filename:    first(metadata, "filename", "name"),
+		ContentType: first(metadata, "filetype", "type"),
+		Length:      length,
+	})
+	if err != nil {
+		h.error(w, err, "failed to create upload", requester.Id)
+		return
+	}
+
+	// The mux may sit under a prefix, the Location has to include it.
+	base := r.URL.Path
+	if uri, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		base = uri.Path
+	}
+
+	w.Header().Set("Location", path.Join(base, u.Id))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) handleHead(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	u, err := h.service.Get(r.Context(), r.PathValue("id"), requester.Id)
+	if err != nil {
+		h.error(w, err, "failed to get upload", requester.Id)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) handlePatch(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		response.Error(w, http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/offset+octet-stream"))
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid Upload-Offset"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	u, err := h.service.Append(r.Context(), AppendRequest{
+		Id:     r.PathValue("id"),
+		UserId: requester.Id,
+		Offset: offset,
+		Size:   r.ContentLength,
+		Reader: r.Body,
+	})
+	if u != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+		if u.Offset < u.Length {
+			w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+		}
+	}
+	if err != nil {
+		h.error(w, err, "failed to append to upload", requester.Id)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleTerminate(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	if err := h.service.Terminate(r.Context(), r.PathValue("id"), requester.Id); err != nil {
+		h.error(w, err, "failed to terminate upload", requester.Id)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) error(w http.ResponseWriter, err error, msg, userId string) {
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidUpload):
+		response.Error(w, http.StatusBadRequest, err)
+	case errors.Is(err, ErrUploadTooLarge):
+		response.Error(w, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, ErrOffsetMismatch), errors.Is(err, fs.ErrFileExists):
+		response.Error(w, http.StatusConflict, err)
+	case errors.Is(err, ErrUploadLocked):
+		response.Error(w, http.StatusLocked, err)
+	case errors.Is(err, fs.ErrUnsupportedFileType):
+		response.Error(w, http.StatusUnsupportedMediaType, err)
+	case errors.Is(err, fs.ErrInvalidMedia):
+		response.Error(w, http.StatusUnprocessableEntity, err)
+	default:
+		h.logger.Error(msg, err, "userId", userId)
+		response.Error(w, http.StatusInternalServerError, errors.New(msg))
+	}
+}
+
+// parseMetadata decodes Upload-Metadata, comma separated pairs of a key and a
+// base64 encoded value, which may be left out.
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q", key)
+		}
+		metadata[key] = string(decoded)
+	}
+
+	return metadata, nil
+}
+
+// first returns the first of keys set in the metadata, clients disagree on
+// what to call the filename and type.
+func first(metadata map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v := metadata[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
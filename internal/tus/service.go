@@ -0,0 +1,353 @@
+package tus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// Each upload is staged as <id>.info, the Upload as JSON, and <id>.bin, the
+// bytes received so far.
+const (
+	infoExt = ".info"
+	dataExt = ".bin"
+)
+
+type Service struct {
+	uploader Uploader
+	dir      string
+	maxSize  int64
+	// expiry is how long an upload is kept after its last chunk.
+	expiry time.Duration
+
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func NewService(uploader Uploader, dir string, maxSize int64, expiry time.Duration) (*Service, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create upload dir: %w", err)
+	}
+
+	return &Service{
+		uploader: uploader,
+		dir:      dir,
+		maxSize:  maxSize,
+		expiry:   expiry,
+		locked:   make(map[string]bool),
+	}, nil
+}
+
+// MaxSize is the largest upload Create accepts.
+func (s *Service) MaxSize() int64 {
+	return s.maxSize
+}
+
+func (s *Service) Create(ctx context.Context, request CreateRequest) (*Upload, error) {
+	filename := filepath.Base(request.Filename)
+	if request.Filename == "" || filename == "." || filename == string(filepath.Separator) {
+		return nil, fmt.Errorf("%w: a filename is required", ErrInvalidUpload)
+	}
+
+	if request.Length <= 0 {
+		return nil, fmt.Errorf("%w: length must be positive", ErrInvalidUpload)
+	}
+
+	if request.Length > s.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes is more than %d", ErrUploadTooLarge, request.Length, s.maxSize)
+	}
+
+	// Turn away what the file service would only refuse once it is all in.
+	fileType := strings.Split(request.ContentType, "/")[0]
+	if fileType != "image" && fileType != "video" {
+		return nil, fs.ErrUnsupportedFileType
+	}
+
+	u := &Upload{
+		Id:          uuid.New().String(),
+		UserId:      request.UserId,
+		Bucket:      request.Bucket,
+		Filename:    filename,
+		ContentType: request.ContentType,
+		Length:      request.Length,
+		ExpiresAt:   time.Now().Add(s.expiry).UTC(),
+	}
+
+	// The info goes first, so however far this gets the upload expires.
+	if err := s.writeInfo(u); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(s.path(u.Id, dataExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		s.remove(u.Id)
+		return nil, fmt.Errorf("create upload file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		s.remove(u.Id)
+		return nil, fmt.Errorf("create upload file: %w", err)
+	}
+
+	return u, nil
+}
+
+func (s *Service) Get(ctx context.Context, id, userId string) (*Upload, error) {
+	return s.get(id, userId)
+}
+
+type AppendRequest struct {
+	Id     string
+	UserId string
+	// Offset is where the client thinks the upload stands, it has to match.
+	Offset int64
+	// Size is the chunk's length, or -1 if it is not known up front.
+	Size   int64
+	Reader io.Reader
+}
+
+// Append adds a chunk to the upload. Whatever part of the chunk arrives is
+// kept even if the connection drops, the client resumes from the new offset.
+// Once the upload is complete it is handed to the file service and removed;
+// if that fails for a reason other than the file itself, it stays complete and
+// an empty chunk at its end tries again.
+func (s *Service) Append(ctx context.Context, request AppendRequest) (*Upload, error) {
+	if !s.lock(request.Id) {
+		return nil, ErrUploadLocked
+	}
+	defer s.unlock(request.Id)
+
+	u, err := s.get(request.Id, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Offset != u.Offset {
+		return nil, fmt.Errorf("%w: upload is at %d, not %d", ErrOffsetMismatch, u.Offset, request.Offset)
+	}
+
+	if request.Size > u.Length-u.Offset {
+		return nil, fmt.Errorf("%w: chunk runs past the upload's length", ErrUploadTooLarge)
+	}
+
+	if u.Offset < u.Length {
+		f, err := os.OpenFile(s.path(u.Id, dataExt), os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return nil, fmt.Errorf("open upload file: %w", err)
+		}
+
+		n, copyErr := io.Copy(f, io.LimitReader(request.Reader, u.Length-u.Offset))
+		closeErr := f.Close()
+		u.Offset += n
+		u.ExpiresAt = time.Now().Add(s.expiry).UTC()
+		if err := s.writeInfo(u); err != nil {
+			return nil, err
+		}
+
+		if err := errors.Join(copyErr, closeErr); err != nil {
+			return u, fmt.Errorf("write chunk: %w", err)
+		}
+	}
+
+	if u.Offset < u.Length {
+		return u, nil
+	}
+
+	if err := s.finish(ctx, u); err != nil {
+		return u, err
+	}
+
+	return u, nil
+}
+
+func (s *Service) finish(ctx context.Context, u *Upload) error {
+	f, err := os.Open(s.path(u.Id, dataExt))
+	if err != nil {
+		return fmt.Errorf("open upload file: %w", err)
+	}
+
+	requests := make(chan fs.UploadRequest, 1)
+	requests <- fs.UploadRequest{
+		Reader:      f,
+		Filename:    u.Filename,
+		ContentType: u.ContentType,
+		UserId:      u.UserId,
+		Bucket:      u.Bucket,
+	}
+	close(requests)
+
+	result := <-s.uploader.Upload(ctx, requests)
+	if result.Err != nil {
+		// Sending it again would not change the answer.
+		if refused(result.Err) {
+			s.remove(u.Id)
+		}
+		return result.Err
+	}
+
+	u.FileId = result.Id
+	s.remove(u.Id)
+
+	return nil
+}
+
+// refused tells whether the file service turned the file itself down, as
+// opposed to failing to store it.
+func refused(err error) bool {
+	return errors.Is(err, fs.ErrFileExists) ||
+		errors.Is(err, fs.ErrUnsupportedFileType) ||
+		errors.Is(err, fs.ErrInvalidMedia) ||
+		errors.Is(err, fs.ErrInvalidVaultUpload)
+}
+
+// Terminate removes an upload the client gave up on.
+func (s *Service) Terminate(ctx context.Context, id, userId string) error {
+	if !s.lock(id) {
+		return ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	if _, err := s.get(id, userId); err != nil {
+		return err
+	}
+
+	s.remove(id)
+	return nil
+}
+
+// Expire removes the uploads that expired before now and reports how many it
+// removed.
+func (s *Service) Expire(ctx context.Context, now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read upload dir: %w", err)
+	}
+
+	var expired int
+	var errs error
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return expired, errors.Join(errs, err)
+		}
+
+		name := entry.Name()
+		id := strings.TrimSuffix(name, infoExt)
+		if filepath.Ext(name) != infoExt || !s.lock(id) {
+			continue
+		}
+
+		u, err := s.readInfo(id)
+		if err != nil {
+			errs = errors.Join(errs, err)
+		} else if u.ExpiresAt.Before(now) {
+			s.remove(id)
+			expired++
+		}
+
+		s.unlock(id)
+	}
+
+	return expired, errs
+}
+
+func (s *Service) get(id, userId string) (*Upload, error) {
+	u, err := s.readInfo(id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	if u.UserId != userId || u.ExpiresAt.Before(time.Now()) {
+		return nil, ErrUploadNotFound
+	}
+
+	stat, err := os.Stat(s.path(id, dataExt))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("stat upload file: %w", err)
+	}
+	u.Offset = stat.Size()
+
+	return u, nil
+}
+
+func (s *Service) readInfo(id string) (*Upload, error) {
+	// Ids end up in paths, only ever take the ones Create hands out.
+	if parsed, err := uuid.Parse(id); err != nil || parsed.String() != id {
+		return nil, os.ErrNotExist
+	}
+
+	data, err := os.ReadFile(s.path(id, infoExt))
+	if err != nil {
+		return nil, err
+	}
+
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, fmt.Errorf("decode upload %q: %w", id, err)
+	}
+
+	return &u, nil
+}
+
+// writeInfo replaces the info file whole, so a crash cannot leave half of
+// one behind.
+func (s *Service) writeInfo(u *Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("encode upload: %w", err)
+	}
+
+	tmp := s.path(u.Id, infoExt+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write upload info: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path(u.Id, infoExt)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write upload info: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) remove(id string) {
+	os.Remove(s.path(id, dataExt))
+	os.Remove(s.path(id, infoExt))
+}
+
+func (s *Service) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+func (s *Service) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locked[id] {
+		return false
+	}
+	s.locked[id] = true
+
+	return true
+}
+
+func (s *Service) unlock(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.locked, id)
+}
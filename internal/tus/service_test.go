@@ -0,0 +1,248 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+type mockUploader struct {
+	received []fs.UploadRequest
+	data     string
+	err      error
+}
+
+func (m *mockUploader) Upload(ctx context.Context, requests <-chan fs.UploadRequest) <-chan fs.UploadResult {
+	results := make(chan fs.UploadResult, 1)
+	for request := range requests {
+		data, _ := io.ReadAll(request.Reader)
+		request.Reader.Close()
+		m.received = append(m.received, request)
+		m.data = string(data)
+
+		if m.err != nil {
+			results <- fs.UploadResult{Filename: request.Filename, Err: m.err}
+			continue
+		}
+		results <- fs.UploadResult{Filename: request.Filename, Id: "file-1"}
+	}
+	close(results)
+
+	return results
+}
+
+func newService(t *testing.T, uploader Uploader) *Service {
+	t.Helper()
+	s, err := NewService(uploader, t.TempDir(), 1<<20, time.Hour)
+	if err != nil {
+		t.Fatalf("NewService(): %v", err)
+	}
+	return s
+}
+
+func create(t *testing.T, s *Service, length int64) *Upload {
+	t.Helper()
+	u, err := s.Create(context.Background(), CreateRequest{UserId: "u1", Bucket: "bucket-a", Filename: "clip.mp4", ContentType: "video/mp4", Length: length})
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	return u
+}
+
+func TestService_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		request CreateRequest
+		wantErr error
+	}{
+		{name: "valid", request: CreateRequest{Filename: "dir/clip.mp4", ContentType: "video/mp4", Length: 10}},
+		{name: "no filename", request: CreateRequest{ContentType: "video/mp4", Length: 10}, wantErr: ErrInvalidUpload},
+		{name: "zero length", request: CreateRequest{Filename: "clip.mp4", ContentType: "video/mp4"}, wantErr: ErrInvalidUpload},
+		{name: "too large", request: CreateRequest{Filename: "clip.mp4", ContentType: "video/mp4", Length: 1<<20 + 1}, wantErr: ErrUploadTooLarge},
+		{name: "unsupported type", request: CreateRequest{Filename: "notes.txt", ContentType: "text/plain", Length: 10}, wantErr: fs.ErrUnsupportedFileType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newService(t, &mockUploader{})
+			tt.request.UserId = "u1"
+
+			u, err := s.Create(context.Background(), tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if u.Filename != "clip.mp4" {
+				t.Errorf("Filename = %q, want the base name", u.Filename)
+			}
+			got, err := s.Get(context.Background(), u.Id, "u1")
+			if err != nil || got.Offset != 0 || got.Length != 10 {
+				t.Errorf("Get() = %+v, %v, want an empty upload of 10 bytes", got, err)
+			}
+		})
+	}
+}
+
+func TestService_Append(t *testing.T) {
+	ctx := context.Background()
+	uploader := &mockUploader{}
+	s := newService(t, uploader)
+	u := create(t, s, 10)
+
+	if _, err := s.Get(ctx, u.Id, "u2"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Get() by another user err = %v, want %v", err, ErrUploadNotFound)
+	}
+	if _, err := s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Offset: 0, Size: 11, Reader: strings.NewReader("0123456789a")}); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("Append() past the length err = %v, want %v", err, ErrUploadTooLarge)
+	}
+
+	// The connection drops after four bytes, they are kept.
+	dropped := io.MultiReader(strings.NewReader("0123"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	got, err := s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Offset: 0, Size: -1, Reader: dropped})
+	if err == nil || got == nil || got.Offset != 4 {
+		t.Fatalf("Append() of a dropped chunk = %+v, %v, want offset 4 and an error", got, err)
+	}
+
+	if _, err := s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Offset: 0, Size: 4, Reader: strings.NewReader("0123")}); !errors.Is(err, ErrOffsetMismatch) {
+		t.Errorf("Append() at a stale offset err = %v, want %v", err, ErrOffsetMismatch)
+	}
+
+	got, err = s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Offset: 4, Size: 3, Reader: strings.NewReader("456")})
+	if err != nil || got.Offset != 7 || len(uploader.received) != 0 {
+		t.Fatalf("Append() = %+v, %v, want offset 7 and nothing uploaded", got, err)
+	}
+
+	got, err = s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Offset: 7, Size: 3, Reader: strings.NewReader("789")})
+	if err != nil || got.Offset != 10 || got.FileId != "file-1" {
+		t.Fatalf("Append() of the last chunk = %+v, %v, want it uploaded", got, err)
+	}
+	if len(uploader.received) != 1 || uploader.data != "0123456789" {
+		t.Fatalf("uploaded %d files with %q, want one with all chunks", len(uploader.received), uploader.data)
+	}
+	if r := uploader.received[0]; r.Filename != "clip.mp4" || r.ContentType != "video/mp4" || r.UserId != "u1" || r.Bucket != "bucket-a" {
+		t.Errorf("upload request = %+v", r)
+	}
+
+	if _, err := s.Get(ctx, u.Id, "u1"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Get() after completion err = %v, want %v", err, ErrUploadNotFound)
+	}
+}
+
+func TestService_AppendUploadFails(t *testing.T) {
+	ctx := context.Background()
+
+	// A failure on the way to storage keeps the upload for another try.
+	uploader := &mockUploader{err: errors.New("storage is down")}
+	s := newService(t, uploader)
+	u := create(t, s, 4)
+
+	if _, err := s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Size: 4, Reader: strings.NewReader("0123")}); err == nil {
+		t.Fatal("Append() err = nil, want the upload's error")
+	}
+
+	uploader.err = nil
+	got, err := s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Offset: 4, Size: 0, Reader: strings.NewReader("")})
+	if err != nil || got.FileId != "file-1" || uploader.data != "0123" {
+		t.Fatalf("Append() to retry = %+v, %v, want it uploaded", got, err)
+	}
+
+	// One the file service refuses is gone.
+	for _, refusal := range []error{fs.ErrFileExists, fmt.Errorf("%w: probe media: exit status 1", fs.ErrInvalidMedia)} {
+		uploader.err = refusal
+		u = create(t, s, 4)
+		if _, err := s.Append(ctx, AppendRequest{Id: u.Id, UserId: "u1", Size: 4, Reader: strings.NewReader("0123")}); !errors.Is(err, refusal) {
+			t.Fatalf("Append() err = %v, want %v", err, refusal)
+		}
+		if _, err := s.Get(ctx, u.Id, "u1"); !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("Get() after a refused upload err = %v, want %v", err, ErrUploadNotFound)
+		}
+		if _, err := os.Stat(s.path(u.Id, dataExt)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("refused upload's data is still there: %v", err)
+		}
+	}
+}
+
+func TestService_Terminate(t *testing.T) {
+	ctx := context.Background()
+	s := newService(t, &mockUploader{})
+	u := create(t, s, 10)
+
+	if err := s.Terminate(ctx, u.Id, "u2"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Terminate() by another user err = %v, want %v", err, ErrUploadNotFound)
+	}
+
+	s.lock(u.Id)
+	if err := s.Terminate(ctx, u.Id, "u1"); !errors.Is(err, ErrUploadLocked) {
+		t.Errorf("Terminate() while locked err = %v, want %v", err, ErrUploadLocked)
+	}
+	s.unlock(u.Id)
+
+	if err := s.Terminate(ctx, u.Id, "u1"); err != nil {
+		t.Fatalf("Terminate(): %v", err)
+	}
+	if entries, _ := os.ReadDir(s.dir); len(entries) != 0 {
+		t.Errorf("upload dir holds %d files after Terminate(), want none", len(entries))
+	}
+	if _, err := s.Get(ctx, "../../etc/passwd", "u1"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Get() of a path err = %v, want %v", err, ErrUploadNotFound)
+	}
+}
+
+func TestService_Expire(t *testing.T) {
+	ctx := context.Background()
+	s := newService(t, &mockUploader{})
+	stale := create(t, s, 10)
+	fresh := create(t, s, 10)
+
+	stale.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := s.writeInfo(stale); err != nil {
+		t.Fatalf("writeInfo(): %v", err)
+	}
+	if _, err := s.Get(ctx, stale.Id, "u1"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Get() of an expired upload err = %v, want %v", err, ErrUploadNotFound)
+	}
+
+	n, err := s.Expire(ctx, time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("Expire() = %d, %v, want 1", n, err)
+	}
+	if _, err := os.Stat(s.path(stale.Id, dataExt)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired upload's data is still there: %v", err)
+	}
+	if _, err := s.Get(ctx, fresh.Id, "u1"); err != nil {
+		t.Errorf("Get() of a fresh upload: %v", err)
+	}
+
+	// Appending pushes the expiry back.
+	got, err := s.Append(ctx, AppendRequest{Id: fresh.Id, UserId: "u1", Size: 1, Reader: strings.NewReader("0")})
+	if err != nil || !got.ExpiresAt.After(fresh.ExpiresAt) {
+		t.Errorf("Append() = %+v, %v, want the expiry pushed back from %v", got, err, fresh.ExpiresAt)
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	got, err := parseMetadata("filename Y2xpcC5tcDQ=, filetype dmlkZW8vbXA0,is_confidential")
+	if err != nil {
+		t.Fatalf("parseMetadata(): %v", err)
+	}
+	if got["filename"] != "clip.mp4" || got["filetype"] != "video/mp4" {
+		t.Errorf("parseMetadata() = %v", got)
+	}
+	if _, ok := got["is_confidential"]; !ok {
+		t.Errorf("parseMetadata() dropped a key without a value: %v", got)
+	}
+
+	if _, err := parseMetadata("filename not-base64!"); err == nil {
+		t.Error("parseMetadata() of a bad value err = nil")
+	}
+}
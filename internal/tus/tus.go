@@ -0,0 +1,50 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/portbound/go-fs/internal/fs"
+)
+
+// Version is the tus protocol version spoken, see https://tus.io/protocols/resumable-upload.
+const Version = "1.0.0"
+
+// Upload is a file being sent in chunks. It is staged on disk until all
+// Length bytes are in, then handed to the file service as one upload.
+type Upload struct {
+	Id          string    `json:"id"`
+	UserId      string    `json:"user_id"`
+	Bucket      string    `json:"bucket"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Length      int64     `json:"length"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// Offset is how many bytes are staged, it is the staged file's size.
+	Offset int64 `json:"-"`
+	// FileId is the new file's id once the upload is complete.
+	FileId string `json:"-"`
+}
+
+// Uploader is the file service, which takes completed uploads.
+type Uploader interface {
+	Upload(ctx context.Context, requests <-chan fs.UploadRequest) <-chan fs.UploadResult
+}
+
+type CreateRequest struct {
+	UserId      string
+	Bucket      string
+	Filename    string
+	ContentType string
+	Length      int64
+}
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrInvalidUpload  = errors.New("invalid upload")
+	ErrUploadTooLarge = errors.New("upload is too large")
+	ErrOffsetMismatch = errors.New("offset does not match the upload")
+	// ErrUploadLocked is returned while another request writes to the upload.
+	ErrUploadLocked = errors.New("upload is locked by another request")
+)
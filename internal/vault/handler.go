@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/portbound/go-fs/internal/auth"
+	"github.com/portbound/go-fs/internal/platform/http/response"
+	"github.com/portbound/go-fs/internal/user"
+	"github.com/portbound/portlog"
+)
+
+type Handler struct {
+	service *Service
+	logger  *portlog.PortLog
+}
+
+func NewHandler(s *Service, l *portlog.PortLog) *Handler {
+	return &Handler{service: s, logger: l}
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /vault", h.handleGetVault)
+	mux.HandleFunc("POST /vault", h.handleCreateVault)
+	mux.HandleFunc("PUT /vault", h.handleRewrapVault)
+}
+
+func (h *Handler) handleGetVault(w http.ResponseWriter, r *http.Request) {
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	v, err := h.service.Get(r.Context(), requester.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, errors.New("vault is not set up"))
+			return
+		}
+
+		h.logger.Error("failed to get vault", err, "userId", requester.Id)
+		response.Error(w, http.StatusInternalServerError, errors.New("failed to get vault"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, v)
+}
+
+// handleCreateVault takes {"kdf", "kdf_params", "wrapped_key"} to set up the
+// vault.
+func (h *Handler) handleCreateVault(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		KDF        string          `json:"kdf"`
+		KDFParams  json.RawMessage `json:"kdf_params"`
+		WrappedKey string          `json:"wrapped_key"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	v, err := h.service.Create(r.Context(), SaveRequest{
+		UserId:     requester.Id,
+		KDF:        body.KDF,
+		KDFParams:  body.KDFParams,
+		WrappedKey: body.WrappedKey,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidVault):
+			response.Error(w, http.StatusBadRequest, err)
+		case errors.Is(err, ErrVaultExists):
+			response.Error(w, http.StatusConflict, err)
+		default:
+			h.logger.Error("failed to create vault", err, "userId", requester.Id)
+			response.Error(w, http.StatusInternalServerError, errors.New("failed to create vault"))
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, v)
+}
+
+// handleRewrapVault takes {"kdf", "kdf_params", "wrapped_key",
+// "previous_wrapped_key"} to rewrap the vault key under a new passphrase.
+func (h *Handler) handleRewrapVault(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		KDF                string          `json:"kdf"`
+		KDFParams          json.RawMessage `json:"kdf_params"`
+		WrappedKey         string          `json:"wrapped_key"`
+		PreviousWrappedKey string          `json:"previous_wrapped_key"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	requester := r.Context().Value(auth.RequesterKey).(*user.User)
+	v, err := h.service.Rewrap(r.Context(), RewrapRequest{
+		SaveRequest: SaveRequest{
+			UserId:     requester.Id,
+			KDF:        body.KDF,
+			KDFParams:  body.KDFParams,
+			WrappedKey: body.WrappedKey,
+		},
+		PreviousWrappedKey: body.PreviousWrappedKey,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidVault):
+			response.Error(w, http.StatusBadRequest, err)
+		case errors.Is(err, ErrVaultChanged):
+			response.Error(w, http.StatusConflict, err)
+		case errors.Is(err, sql.ErrNoRows):
+			response.Error(w, http.StatusNotFound, errors.New("vault is not set up"))
+		default:
+			h.logger.Error("failed to rewrap vault", err, "userId", requester.Id)
+			response.Error(w, http.StatusInternalServerError, errors.New("failed to rewrap vault"))
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, v)
+}
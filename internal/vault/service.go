@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	maxKDFParamsLength = 1 << 10
+	// A wrapped 256 bit key is 40 bytes with AES key wrap and 60 with
+	// AES-GCM, the bounds leave room for other schemes.
+	minWrappedKeySize = 32
+	maxWrappedKeySize = 512
+)
+
+type Service struct {
+	store Store
+}
+
+func NewService(s Store) *Service {
+	return &Service{store: s}
+}
+
+func (s *Service) Get(ctx context.Context, userId string) (*Vault, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return s.store.GetVault(dbCtx, userId)
+}
+
+// Create sets up the user's vault. It fails with ErrVaultExists if there is
+// one already, replacing its key would leave the files wrapped with it
+// undecryptable.
+func (s *Service) Create(ctx context.Context, request SaveRequest) (*Vault, error) {
+	params, err := validate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	v := &Vault{
+		UserId:     request.UserId,
+		KDF:        request.KDF,
+		KDFParams:  params,
+		WrappedKey: request.WrappedKey,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := s.store.CreateVault(dbCtx, v); err != nil {
+		return nil, err
+	}
+
+	return s.store.GetVault(dbCtx, request.UserId)
+}
+
+// Rewrap replaces the vault's key material when the passphrase changes. The
+// server cannot check that the new WrappedKey still holds the same vault key,
+// but it only takes it in place of the one the client unwrapped, so two
+// devices cannot overwrite each other's change. It fails with ErrVaultChanged
+// if the vault was rewrapped since, and sql.ErrNoRows if there is no vault.
+func (s *Service) Rewrap(ctx context.Context, request RewrapRequest) (*Vault, error) {
+	params, err := validate(request.SaveRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{
+		UserId:     request.UserId,
+		KDF:        request.KDF,
+		KDFParams:  params,
+		WrappedKey: request.WrappedKey,
+		UpdatedAt:  time.Now().UTC(),
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := s.store.RewrapVault(dbCtx, v, request.PreviousWrappedKey); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		// Either there is no vault or its key is another one by now.
+		if _, err := s.store.GetVault(dbCtx, request.UserId); err != nil {
+			return nil, err
+		}
+		return nil, ErrVaultChanged
+	}
+
+	return s.store.GetVault(dbCtx, request.UserId)
+}
+
+// validate checks the key material and returns the trimmed KDF parameters.
+func validate(request SaveRequest) (json.RawMessage, error) {
+	switch request.KDF {
+	case KDFArgon2id, KDFPBKDF2:
+	default:
+		return nil, fmt.Errorf("%w: unsupported kdf %q", ErrInvalidVault, request.KDF)
+	}
+
+	params := bytes.TrimSpace(request.KDFParams)
+	if len(params) > maxKDFParamsLength || !json.Valid(params) || params[0] != '{' {
+		return nil, fmt.Errorf("%w: kdf_params must be a JSON object of at most %d bytes", ErrInvalidVault, maxKDFParamsLength)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(request.WrappedKey)
+	if err != nil || len(key) < minWrappedKeySize || len(key) > maxWrappedKeySize {
+		return nil, fmt.Errorf("%w: wrapped_key must be base64 of %d to %d bytes", ErrInvalidVault, minWrappedKeySize, maxWrappedKeySize)
+	}
+
+	return params, nil
+}
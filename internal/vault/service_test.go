@@ -0,0 +1,129 @@
+package vault
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type mockStore struct {
+	vaults map[string]*Vault
+}
+
+func (m *mockStore) GetVault(ctx context.Context, userId string) (*Vault, error) {
+	v, ok := m.vaults[userId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return v, nil
+}
+
+func (m *mockStore) CreateVault(ctx context.Context, v *Vault) error {
+	if _, ok := m.vaults[v.UserId]; ok {
+		return ErrVaultExists
+	}
+	m.vaults[v.UserId] = v
+	return nil
+}
+
+func (m *mockStore) RewrapVault(ctx context.Context, v *Vault, previousWrappedKey string) error {
+	old, ok := m.vaults[v.UserId]
+	if !ok || old.WrappedKey != previousWrappedKey {
+		return sql.ErrNoRows
+	}
+	v.CreatedAt = old.CreatedAt
+	m.vaults[v.UserId] = v
+	return nil
+}
+
+func TestService_Create(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 60))
+	params := json.RawMessage(`{"salt": "c2FsdA==", "memory": 65536, "iterations": 3}`)
+
+	tests := []struct {
+		name    string
+		request SaveRequest
+		wantErr bool
+	}{
+		{name: "argon2id", request: SaveRequest{KDF: KDFArgon2id, KDFParams: params, WrappedKey: key}},
+		{name: "pbkdf2", request: SaveRequest{KDF: KDFPBKDF2, KDFParams: json.RawMessage(`{"salt": "c2FsdA==", "iterations": 600000}`), WrappedKey: key}},
+		{name: "unknown kdf", request: SaveRequest{KDF: "md5", KDFParams: params, WrappedKey: key}, wantErr: true},
+		{name: "params not an object", request: SaveRequest{KDF: KDFArgon2id, KDFParams: json.RawMessage(`[1, 2]`), WrappedKey: key}, wantErr: true},
+		{name: "params missing", request: SaveRequest{KDF: KDFArgon2id, WrappedKey: key}, wantErr: true},
+		{name: "params too long", request: SaveRequest{KDF: KDFArgon2id, KDFParams: json.RawMessage(`{"salt": "` + strings.Repeat("a", maxKDFParamsLength) + `"}`), WrappedKey: key}, wantErr: true},
+		{name: "key not base64", request: SaveRequest{KDF: KDFArgon2id, KDFParams: params, WrappedKey: "not base64!"}, wantErr: true},
+		{name: "key too short", request: SaveRequest{KDF: KDFArgon2id, KDFParams: params, WrappedKey: base64.StdEncoding.EncodeToString(make([]byte, 16))}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(&mockStore{vaults: map[string]*Vault{}})
+			tt.request.UserId = "u1"
+
+			v, err := s.Create(context.Background(), tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Create() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVault) {
+					t.Errorf("Create() err = %v, want %v", err, ErrInvalidVault)
+				}
+				return
+			}
+			if v.KDF != tt.request.KDF || v.WrappedKey != key {
+				t.Errorf("Create() = %+v", v)
+			}
+		})
+	}
+}
+
+func TestService_Rewrap(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&mockStore{vaults: map[string]*Vault{}})
+	oldKey := base64.StdEncoding.EncodeToString(make([]byte, 40))
+	request := SaveRequest{
+		UserId:     "u1",
+		KDF:        KDFArgon2id,
+		KDFParams:  json.RawMessage(`{"salt": "c2FsdA=="}`),
+		WrappedKey: oldKey,
+	}
+
+	rewrap := RewrapRequest{SaveRequest: request, PreviousWrappedKey: oldKey}
+	rewrap.WrappedKey = base64.StdEncoding.EncodeToString(make([]byte, 60))
+	if _, err := s.Rewrap(ctx, rewrap); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Rewrap() before setup err = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	first, err := s.Create(ctx, request)
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	created := first.CreatedAt
+
+	// Another device setting the vault up again would lock out every file
+	// wrapped with the first key.
+	if _, err := s.Create(ctx, request); !errors.Is(err, ErrVaultExists) {
+		t.Errorf("second Create() err = %v, want %v", err, ErrVaultExists)
+	}
+
+	second, err := s.Rewrap(ctx, rewrap)
+	if err != nil {
+		t.Fatalf("Rewrap(): %v", err)
+	}
+	if !second.CreatedAt.Equal(created) || second.WrappedKey != rewrap.WrappedKey {
+		t.Errorf("Rewrap() = %+v, want the new key and CreatedAt %v", second, created)
+	}
+
+	// A rewrap based on the key that was just replaced is refused.
+	stale := rewrap
+	stale.WrappedKey = base64.StdEncoding.EncodeToString(make([]byte, 48))
+	if _, err := s.Rewrap(ctx, stale); !errors.Is(err, ErrVaultChanged) {
+		t.Errorf("stale Rewrap() err = %v, want %v", err, ErrVaultChanged)
+	}
+	if v, _ := s.Get(ctx, "u1"); v.WrappedKey != rewrap.WrappedKey {
+		t.Errorf("stale Rewrap() replaced the key with %q", v.WrappedKey)
+	}
+}
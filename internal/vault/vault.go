@@ -0,0 +1,64 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Vault is what a client needs to get from the user's passphrase back to the
+// key their vault files are wrapped with. Neither the passphrase nor any key
+// reaches the server in the clear: the client derives a key from the
+// passphrase with KDF and KDFParams and uses it to unwrap WrappedKey.
+type Vault struct {
+	UserId string `json:"-"`
+	// KDF is one of the supported key derivation functions, KDFParams its
+	// salt and cost as the client chose them.
+	KDF       string          `json:"kdf"`
+	KDFParams json.RawMessage `json:"kdf_params"`
+	// WrappedKey is the base64 encoded vault key, encrypted under the key
+	// derived from the passphrase.
+	WrappedKey string    `json:"wrapped_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type Store interface {
+	// GetVault fails with sql.ErrNoRows if the user has no vault.
+	GetVault(ctx context.Context, userId string) (*Vault, error)
+	// CreateVault sets up the user's vault, ErrVaultExists if they already
+	// have one.
+	CreateVault(ctx context.Context, v *Vault) error
+	// RewrapVault replaces the key material and UpdatedAt of the user's
+	// vault, as long as its WrappedKey is still previousWrappedKey. It fails
+	// with sql.ErrNoRows otherwise.
+	RewrapVault(ctx context.Context, v *Vault, previousWrappedKey string) error
+}
+
+type SaveRequest struct {
+	UserId     string
+	KDF        string
+	KDFParams  json.RawMessage
+	WrappedKey string
+}
+
+type RewrapRequest struct {
+	SaveRequest
+	// PreviousWrappedKey is the WrappedKey the client unwrapped the vault key
+	// from, the rewrap only goes through if it is still the current one.
+	PreviousWrappedKey string
+}
+
+const (
+	KDFArgon2id = "argon2id"
+	KDFPBKDF2   = "pbkdf2-sha256"
+)
+
+var (
+	ErrInvalidVault = errors.New("invalid vault")
+	ErrVaultExists  = errors.New("vault already exists")
+	// ErrVaultChanged is returned when a rewrap is based on a key that has
+	// since been replaced, the client has to start over from the current one.
+	ErrVaultChanged = errors.New("vault was changed since it was read")
+)
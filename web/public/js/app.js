@@ -4,6 +4,15 @@ document.addEventListener("alpine:init", () => {
 		filesByDate: {},
 		selectedFile: null,
 		isLoading: true,
+		// Filters
+		query: "",
+		mediaType: "",
+		sortBy: "taken",
+		// Pagination
+		pageSize: 100,
+		nextCursor: "",
+		totalFiles: 0,
+		isLoadingMore: false,
 		// Modals
 		showPopover: false,
 		showFullscreenImage: false,
@@ -24,6 +33,7 @@ document.addEventListener("alpine:init", () => {
 		// Initialization
 		init() {
 			this.fetchFiles();
+			window.addEventListener("scroll", () => this.maybeLoadMore(), { passive: true });
 		},
 
 		// API Fetch Wrapper
@@ -56,16 +66,11 @@ document.addEventListener("alpine:init", () => {
 		async fetchFiles() {
 			this.isLoading = true;
 			try {
-				const response = await this.authedFetch("/files");
-				if (!response.ok) throw new Error("Failed to fetch files.");
-
-				const data = (await response.json()) || [];
-				if (Array.isArray(data)) {
-					this.filesByDate = this.groupFilesByDate(data);
-					Object.values(this.filesByDate)
-						.flat()
-						.forEach((file) => this.loadThumbnail(file));
-				}
+				const page = await this.fetchPage("");
+				this.filesByDate = this.groupFilesByDate(page.files);
+				this.nextCursor = page.next_cursor || "";
+				this.totalFiles = page.total;
+				page.files.forEach((file) => this.loadThumbnail(file));
 			} catch (error) {
 				if (error.message !== "Unauthorized") {
 					this.addToast("Error fetching files: " + error.message);
@@ -73,11 +78,60 @@ document.addEventListener("alpine:init", () => {
 			} finally {
 				this.isLoading = false;
 			}
+			this.$nextTick(() => this.maybeLoadMore());
 		},
 
-		groupFilesByDate(files) {
+		async loadMoreFiles() {
+			if (!this.nextCursor || this.isLoadingMore) return;
+
+			this.isLoadingMore = true;
+			try {
+				const page = await this.fetchPage(this.nextCursor);
+				this.filesByDate = this.groupFilesByDate(page.files, { ...this.filesByDate });
+				this.nextCursor = page.next_cursor || "";
+				this.totalFiles = page.total;
+				page.files.forEach((file) => this.loadThumbnail(file));
+			} catch (error) {
+				if (error.message !== "Unauthorized") {
+					this.addToast("Error fetching files: " + error.message);
+				}
+				this.nextCursor = "";
+			} finally {
+				this.isLoadingMore = false;
+			}
+			this.$nextTick(() => this.maybeLoadMore());
+		},
+
+		async fetchPage(cursor) {
+			const params = new URLSearchParams({ limit: this.pageSize, sort: this.sortBy });
+			const query = [this.query.trim(), this.mediaType && `type:${this.mediaType}`].filter(Boolean).join(" ");
+			if (query) params.set("q", query);
+			if (cursor) params.set("cursor", cursor);
+
+			const response = await this.authedFetch(`/files?${params}`);
+			if (response.status === 400) {
+				const errorData = await response.json();
+				throw new Error(errorData.error || "Invalid filter.");
+			}
+			if (!response.ok) throw new Error("Failed to fetch files.");
+
+			const page = await response.json();
+			return { ...page, files: page.files || [] };
+		},
+
+		// Keeps fetching while the bottom of the gallery is within a screen of
+		// the viewport, which also fills tall screens on the first load.
+		maybeLoadMore() {
+			if (this.isLoading) return;
+			if (window.innerHeight + window.scrollY >= document.body.offsetHeight - window.innerHeight) {
+				this.loadMoreFiles();
+			}
+		},
+
+		groupFilesByDate(files, groups = {}) {
 			return files.reduce((acc, file) => {
-				const uploadDate = new Date(file.uploadDate); // This Date object represents the UTC instant
+				const timestamp = this.sortBy === "uploaded" ? file.uploaded_at : file.taken_at || file.uploaded_at;
+				const uploadDate = new Date(timestamp); // This Date object represents the UTC instant
 				// Format this Date object to a local YYYY-MM-DD string for grouping
 				const date = uploadDate.toLocaleDateString('en-CA', { // 'en-CA' locale ensures YYYY-MM-DD format
 					year: 'numeric',
@@ -86,20 +140,23 @@ document.addEventListener("alpine:init", () => {
 				});
 
 				if (!acc[date]) acc[date] = [];
+				file.name = file.filename;
+				file.type = file.content_type;
+				file.uploadDate = file.uploaded_at;
 				file.thumbnailUrl = ""; // Placeholder
 				file.fullUrl = ""; // Placeholder
 				acc[date].push(file);
 				return acc;
-			}, {});
+			}, groups);
 		},
 
 		async loadThumbnail(file) {
 			if (file.thumbnailUrl) return;
 			try {
-				const response = await this.authedFetch(`/files/${file.thumbId}`);
+				const response = await this.authedFetch(`/files/${file.id}/thumbnail/url`);
 				if (!response.ok) throw new Error("Thumbnail fetch failed");
-				const blob = await response.blob();
-				file.thumbnailUrl = URL.createObjectURL(blob);
+				const signed = await response.json();
+				file.thumbnailUrl = signed.url;
 			} catch (error) {
 				console.error(`Failed to load thumbnail for ${file.name}:`, error);
 				this.addToast(`Could not load thumbnail for ${file.name}`);
@@ -113,10 +170,10 @@ document.addEventListener("alpine:init", () => {
 
 			if (!file.fullUrl) {
 				try {
-					const response = await this.authedFetch(`/files/${file.id}`);
+					const response = await this.authedFetch(`/files/${file.id}/url`);
 					if (!response.ok) throw new Error("Full media fetch failed");
-					const blob = await response.blob();
-					this.selectedFile.fullUrl = URL.createObjectURL(blob);
+					const signed = await response.json();
+					this.selectedFile.fullUrl = signed.url;
 				} catch (error) {
 					this.addToast(`Could not load preview for ${file.name}`);
 				}
@@ -158,7 +215,7 @@ document.addEventListener("alpine:init", () => {
 		},
 
 		async deleteFile(file) {
-			if (!confirm("Are you sure you want to delete this file? This action cannot be undone.")) return;
+			if (!confirm("Move this file to the trash? It can be restored until it is purged.")) return;
 
 			try {
 				const response = await this.authedFetch(`/files/${file.id}`, { method: "DELETE" });
@@ -173,7 +230,7 @@ document.addEventListener("alpine:init", () => {
 				}
 				this.showPopover = false;
 				this.selectedFile = null;
-				this.addToast(`"${file.name}" deleted successfully.`, "success");
+				this.addToast(`"${file.name}" moved to the trash.`, "success");
 			} catch (error) {
 				this.addToast(error.message);
 			}
@@ -205,9 +262,11 @@ document.addEventListener("alpine:init", () => {
 						: `${this.filesToUpload.length} files uploaded successfully!`;
 					this.addToast(message, "success");
 				} else if (response.status === 207) {
-					// Multi-Status
-					const errorText = await response.text();
-					this.addToast(`Some files failed to upload: ${errorText}`);
+					// Multi-Status, one entry per file
+					const results = await response.json();
+					const failed = results.filter((result) => result.error);
+					const errorText = failed.map((result) => `${result.filename} (${result.error.message})`).join(", ");
+					this.addToast(`${failed.length} of ${results.length} files failed to upload: ${errorText}`);
 				} else {
 					const errorData = await response.json();
 					throw new Error(errorData.error || "Upload failed");